@@ -27,28 +27,92 @@ export const createSession = ():Promise<Session>=>{
 
 export const sendMessage = (message:Message):ReadableStream<Message> => {
 
+    return chatStream('/chat', {
+        message: message.content,
+        session: message.session,
+        attachments: message.attachments
+    })
+}
+
+// 修改用户消息并重新生成回复,原消息保留为另一个版本
+export const editMessage = (session: string, id: string, content: string): ReadableStream<Message> => {
+
+    return chatStream('/message/edit', {session, id, content})
+}
+
+// 为同一条用户消息重新生成回复
+export const regenerateMessage = (session: string, id: string): ReadableStream<Message> => {
+
+    return chatStream('/message/regenerate', {session, id})
+}
+
+// 切换到包含指定消息的分支,返回切换后的消息和各消息的版本
+export const switchBranch = (session: string, id: string): Promise<{messages: Message[], branches: Record<string, string[]>}> => {
+
+    return fetch("/api/message/switch", {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json',
+        },
+        body: JSON.stringify({session, id}),
+    }).then(res => res.json()).then(data => {
+        if (data.code === 200 && data.data) {
+            return data.data
+        }
+        throw data.message
+    })
+}
+
+// 流式接口的地址,使用 127.0.0.1 避免 localhost 解析为 ::1
+const streamBaseUrl = 'http://127.0.0.1:9980'
+
+// 本次启动的令牌,访问流式接口时需要带上,只能通过 Wails 获取
+let launchToken: Promise<string> | null = null
+
+const getLaunchToken = (): Promise<string> => {
+
+    if (!launchToken) {
+        launchToken = fetch("/api/app/token", {
+            method: 'POST',
+        }).then(res => res.json()).then(data => {
+            if (data.code === 200 && data.data) {
+                return data.data as string
+            }
+            throw data.message
+        }).catch(err => {
+            launchToken = null
+            throw err
+        })
+    }
+    return launchToken
+}
+
+const chatStream = (path: string, body: any): ReadableStream<Message> => {
+
     return new ReadableStream({
         start(controller) {
 
-            fetchEventSource('http://localhost:9980/chat', {
+            getLaunchToken().then(token => fetchEventSource(streamBaseUrl + path, {
                 method: 'POST',
                 headers: {
                     'Content-Type': 'application/json',
+                    'X-Launch-Token': token,
                 },
                 openWhenHidden: false,
-                body: JSON.stringify({
-                    message: message.content,
-                    session: message.session
-                }),
+                body: JSON.stringify(body),
                 onmessage(event) {
                     try {
-                        const message = JSON.parse(event.data) as Message
-                            controller.enqueue(message)
+                        const data = JSON.parse(event.data)
+                        // 错误事件的 error 为 {code, message}
+                        if (data.error) {
+                            data.error = data.error.message
+                        }
+                        controller.enqueue(data as Message)
                     }catch ( e){
                         console.log( e)
                     }
                 },
-            }).then(r  =>{
+            })).then(r  =>{
                 controller.close();
             }).catch( err=>{
                 controller.enqueue({
@@ -59,4 +123,104 @@ export const sendMessage = (message:Message):ReadableStream<Message> => {
 
         },
     });
-}
\ No newline at end of file
+}
+
+// 上传文件到知识库
+export const uploadDocuments = (files: File[]): Promise<any[]> => {
+
+    const form = new FormData()
+    files.forEach(file => form.append("file", file))
+
+    return fetch("/api/knowledge/upload", {
+        method: 'POST',
+        body: form,
+    }).then(res => res.json()).then(data => {
+        if (data.code === 200 && data.data) {
+            return data.data
+        }
+        throw data.message
+    })
+}
+
+// 修改会话标题
+export const renameSession = (id: string, title: string): Promise<Session> => {
+
+    return fetch("/api/session/rename", {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json',
+        },
+        body: JSON.stringify({id, title}),
+    }).then(res => res.json()).then(data => {
+        if (data.code === 200 && data.data) {
+            return data.data as Session
+        }
+        throw data.message
+    })
+}
+
+// 导出会话,format 为 markdown、html 或 json,ids 为空时导出全部会话
+export const exportSessions = (format: 'markdown' | 'html' | 'json', ids: string[] = []): Promise<Blob> => {
+
+    return fetch("/api/session/export", {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json',
+        },
+        body: JSON.stringify({ids, format}),
+    }).then(res => {
+        if (res.ok) {
+            return res.blob()
+        }
+        return res.json().then(data => {
+            throw data.message
+        })
+    })
+}
+
+// 导入导出的 json 或 ChatGPT 的 conversations.json
+export const importSessions = (file: File): Promise<{sessions: number, messages: number, attachments: number, skipped: number}> => {
+
+    const form = new FormData()
+    form.append("file", file)
+
+    return fetch("/api/session/import", {
+        method: 'POST',
+        body: form,
+    }).then(res => res.json()).then(data => {
+        if (data.code === 200 && data.data) {
+            return data.data
+        }
+        throw data.message
+    })
+}
+
+// 存储的加密状态,locked 为 true 时其他接口返回 423,需要先解锁
+export const vaultStatus = (): Promise<{enabled: boolean, locked: boolean, source?: 'passphrase' | 'keyring'}> => {
+
+    return fetch("/api/vault/status", {
+        method: 'POST',
+    }).then(res => res.json()).then(data => {
+        if (data.code === 200 && data.data) {
+            return data.data
+        }
+        throw data.message
+    })
+}
+
+// 使用口令解锁加密的存储
+export const unlockVault = (passphrase: string): Promise<{enabled: boolean, locked: boolean, source?: 'passphrase' | 'keyring'}> => {
+
+    return fetch("/api/vault/unlock", {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json',
+        },
+        body: JSON.stringify({passphrase}),
+    }).then(res => res.json()).then(data => {
+        if (data.code === 200 && data.data) {
+            return data.data
+        }
+        throw data.message
+    })
+}
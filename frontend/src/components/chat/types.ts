@@ -2,6 +2,34 @@ export interface MessageMeta{
     isThinking?:boolean
     isThinkContentColspan?:boolean
 }
+// 知识库引用,index 对应回复中的 [n]
+export interface Citation{
+    index: number;
+    document: string;
+    name: string;
+    chunk: number;
+    content: string;
+    score: number;
+}
+// 已超出的预算
+export interface Budget{
+    period: 'daily' | 'monthly';
+    limit: number;
+    spent: number;
+    currency: string;
+}
+// 消息的附件,内容通过 /api/attachment/{id} 获取
+export interface Attachment{
+    id?: string;
+    kind: 'image' | 'screenshot' | 'file';
+    name?: string;
+    mime_type?: string;
+    size?: number;
+    // 发送时的 base64 或 data URL,与 path 二选一
+    data?: string;
+    // 发送时的本地文件路径
+    path?: string;
+}
 export interface Message{
     id: string;
     content: string;
@@ -9,7 +37,22 @@ export interface Message{
     role: 'system' | 'user' | 'assistant';
     request_id: string;
     session:string;
+    seq?: number;
+    // 分支中的上一条消息
+    parent_id?: string;
+    model?: string;
+    created_time?: number;
+    prompt_tokens?: number;
+    completion_tokens?: number;
+    reasoning_tokens?: number;
+    // 开始生成到第一个 token 的毫秒数
+    first_token_latency?: number;
+    // 生成总耗时(毫秒)
+    latency?: number;
     error?:string;
+    attachments?:Attachment[];
+    citations?:Citation[];
+    budget?:Budget;
     meta?:MessageMeta
 }
 
@@ -17,5 +60,11 @@ export interface Message{
 export interface Session{
     id: string;
     title:string;
+    created_at?: number;
+    updated_at?: number;
+    last_message_preview?: string;
+    pinned?: boolean;
+    archived?: boolean;
+    deleted_at?: number;
     messages: Message[];
 }
\ No newline at end of file
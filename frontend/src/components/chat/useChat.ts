@@ -1,9 +1,18 @@
 import {ref} from "vue";
 import {Message, Session} from "./types";
 import {createSession as _createSession, sendMessage} from './api'
+import {Events} from "@wailsio/runtime";
 
 const session = ref<Session|null>(null)
 
+// 后台生成或手动修改会话标题后同步到当前会话
+Events.On("session:title", (event: any) => {
+    const data = event.data as {id: string, title: string}
+    if (session.value && data && session.value.id === data.id) {
+        session.value.title = data.title
+    }
+})
+
 export function useChat() {
 
     const createSession = async ()=>{
@@ -53,6 +62,12 @@ export function useChat() {
                         assistantMessage.error = value.error
                         break;
                     }
+                    if(value.citations){
+                        assistantMessage.citations = value.citations
+                    }
+                    if(value.budget){
+                        assistantMessage.budget = value.budget
+                    }
                     if(value.reason_content){
                         if(!assistantMessage.meta.isThinking){
                            assistantMessage.meta.isThinking = true
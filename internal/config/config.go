@@ -2,18 +2,29 @@ package config
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"sync"
 
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/spf13/viper"
 )
 
 // Config 应用程序配置结构
 type Config struct {
-	App    AppConfig    `json:"app"`
-	Log    LogConfig    `json:"log"`
-	Window WindowConfig `json:"window"`
-	mu     sync.RWMutex
+	App       AppConfig       `json:"app"`
+	Log       LogConfig       `json:"log"`
+	Window    WindowConfig    `json:"window"`
+	Models    ModelConfig     `json:"models"`
+	Tools     ToolsConfig     `json:"tools"`
+	MCP       MCPConfig       `json:"mcp"`
+	Gateway   GatewayConfig   `json:"gateway"`
+	Memory    MemoryConfig    `json:"memory"`
+	Knowledge KnowledgeConfig `json:"knowledge"`
+	Trash     TrashConfig     `json:"trash"`
+	Usage     UsageConfig     `json:"usage"`
+	Storage   StorageConfig   `json:"storage"`
+	mu        sync.RWMutex
 }
 
 // AppConfig 应用程序基本配置
@@ -30,7 +41,7 @@ type WindowConfig struct {
 // LogConfig 日志配置
 type LogConfig struct {
 	Level      string `json:"level"`       // debug, info, warn, error
-	OutputFile string `json:"output_file"` // 日志文件路径
+	OutputFile string `json:"output_file"` // 日志文件路径,相对路径相对于数据目录
 	MaxSize    int    `json:"max_size"`    // 最大文件大小(MB)
 	MaxBackups int    `json:"max_backups"` // 最大备份数量
 	MaxAge     int    `json:"max_age"`     // 最大保存天数
@@ -60,6 +71,85 @@ func DefaultConfig() *Config {
 			MaxAge:     30,
 			Compress:   true,
 		},
+		Models: ModelConfig{
+			Default: "deepseek-reasoner",
+			Providers: []ProviderConfig{
+				{
+					Name:      "deepseek",
+					Provider:  DeepSeek,
+					APIKeyEnv: "DEEPSEEK_API_KEY",
+					Enabled:   true,
+					Models: []ChatModelDefine{
+						{Model: "deepseek-chat", Provider: DeepSeek, ContextWindow: 131072},
+						{Model: "deepseek-reasoner", Provider: DeepSeek, SupportThinking: true, ContextWindow: 131072},
+					},
+				},
+				{
+					Name:      "qwen",
+					Provider:  Qwen,
+					APIKeyEnv: "DASHSCOPE_API_KEY",
+					Enabled:   false,
+					Models: []ChatModelDefine{
+						{Model: "qwen-plus", Provider: Qwen, ContextWindow: 131072},
+						{Model: "qwen-vl-plus", Provider: Qwen, IsMultimodal: true, ContextWindow: 32768},
+					},
+					Embeddings: []EmbeddingModelDefine{
+						{Model: "text-embedding-v3", Dimensions: 1024},
+					},
+				},
+				{
+					Name:     "ollama",
+					Provider: Ollama,
+					Enabled:  false,
+					Models:   []ChatModelDefine{},
+				},
+			},
+		},
+		Tools: ToolsConfig{
+			DefaultPolicy: ToolPolicyAsk,
+			Policies: map[string]ToolPolicy{
+				"current_time": ToolPolicyAuto,
+				"calculator":   ToolPolicyAuto,
+			},
+			Builtin: BuiltinToolsConfig{
+				DateTime:      true,
+				Calculator:    true,
+				AllowedDirs:   []string{},
+				ShellTimeout:  30,
+				HTTPAllowlist: []string{},
+			},
+		},
+		MCP: MCPConfig{
+			Servers: []MCPServerConfig{},
+		},
+		Gateway: GatewayConfig{
+			Enabled: false,
+		},
+		Memory: MemoryConfig{
+			Enabled:     true,
+			TopK:        5,
+			MinScore:    0.3,
+			AutoExtract: true,
+		},
+		Knowledge: KnowledgeConfig{
+			Enabled:      true,
+			TopK:         4,
+			MinScore:     0.3,
+			ChunkSize:    800,
+			ChunkOverlap: 100,
+			MaxFileSize:  20,
+		},
+		Trash: TrashConfig{
+			RetentionDays: 30,
+		},
+		Usage: UsageConfig{
+			Currency:     "CNY",
+			BudgetAction: BudgetActionWarn,
+		},
+		Storage: StorageConfig{
+			Backend: StorageSQLite,
+			Dir:     ".",
+		},
 	}
 }
 
@@ -117,7 +207,7 @@ func loadConfig(cfgPath string) (*Config, error) {
 
 	// 解析配置到结构体
 	cfg := &Config{}
-	if err := v.Unmarshal(cfg); err != nil {
+	if err := v.Unmarshal(cfg, decodeWithJSONTag); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
@@ -129,6 +219,11 @@ func (c *Config) Save(cfgPath string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	// 确保配置目录存在
+	if err := os.MkdirAll(filepath.Dir(cfgPath), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
 	if v == nil {
 		// 如果 viper 未初始化,使用临时实例
 		tmpViper := viper.New()
@@ -157,7 +252,8 @@ func (c *Config) Save(cfgPath string) error {
 	// 同步配置到 viper
 	syncToViper(v, c)
 
-	// 写入配置文件
+	// 写入配置文件,并将 viper 绑定到该路径,后续 Update 会写回同一文件
+	v.SetConfigFile(cfgPath)
 	if err := v.WriteConfig(); err != nil {
 		return fmt.Errorf("failed to write config: %w", err)
 	}
@@ -180,7 +276,7 @@ func (c *Config) Reload() error {
 	}
 
 	// 解析到结构体
-	if err := v.Unmarshal(c); err != nil {
+	if err := v.Unmarshal(c, decodeWithJSONTag); err != nil {
 		return fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
@@ -231,6 +327,79 @@ func (c *Config) GetLog() LogConfig {
 	return c.Log
 }
 
+// GetModels 获取模型配置
+func (c *Config) GetModels() ModelConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Models
+}
+
+// GetTools 获取工具配置
+func (c *Config) GetTools() ToolsConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Tools
+}
+
+// GetMCP 获取 MCP 配置
+func (c *Config) GetMCP() MCPConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.MCP
+}
+
+// GetGateway 获取 OpenAI 兼容接口配置
+func (c *Config) GetGateway() GatewayConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Gateway
+}
+
+// GetMemory 获取长期记忆配置
+func (c *Config) GetMemory() MemoryConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Memory
+}
+
+// GetKnowledge 获取知识库配置
+func (c *Config) GetKnowledge() KnowledgeConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Knowledge
+}
+
+// GetTrash 获取回收站配置
+func (c *Config) GetTrash() TrashConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Trash
+}
+
+// GetUsage 获取用量统计和预算配置
+func (c *Config) GetUsage() UsageConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Usage
+}
+
+// GetStorage 获取数据存储配置
+func (c *Config) GetStorage() StorageConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Storage
+}
+
+// SetToolPolicy 设置工具的调用策略
+func (c *Config) SetToolPolicy(name string, policy ToolPolicy) error {
+	return c.Update(func(cfg *Config) {
+		if cfg.Tools.Policies == nil {
+			cfg.Tools.Policies = make(map[string]ToolPolicy)
+		}
+		cfg.Tools.Policies[name] = policy
+	})
+}
+
 // SetAppLanguage 设置应用语言
 func (c *Config) SetAppLanguage(language string) error {
 	return c.Update(func(cfg *Config) {
@@ -268,6 +437,52 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("log.max_backups", defaultCfg.Log.MaxBackups)
 	v.SetDefault("log.max_age", defaultCfg.Log.MaxAge)
 	v.SetDefault("log.compress", defaultCfg.Log.Compress)
+
+	// Models 默认值
+	v.SetDefault("models.default", defaultCfg.Models.Default)
+	v.SetDefault("models.default_embedding", defaultCfg.Models.DefaultEmbedding)
+	v.SetDefault("models.title_model", defaultCfg.Models.TitleModel)
+	v.SetDefault("models.providers", defaultCfg.Models.Providers)
+
+	// Tools 默认值
+	v.SetDefault("tools.default_policy", defaultCfg.Tools.DefaultPolicy)
+	v.SetDefault("tools.policies", defaultCfg.Tools.Policies)
+	v.SetDefault("tools.builtin", defaultCfg.Tools.Builtin)
+
+	// MCP 默认值
+	v.SetDefault("mcp.servers", defaultCfg.MCP.Servers)
+
+	// Gateway 默认值
+	v.SetDefault("gateway.enabled", defaultCfg.Gateway.Enabled)
+	v.SetDefault("gateway.api_key", defaultCfg.Gateway.APIKey)
+
+	// Memory 默认值
+	v.SetDefault("memory.enabled", defaultCfg.Memory.Enabled)
+	v.SetDefault("memory.embedding", defaultCfg.Memory.Embedding)
+	v.SetDefault("memory.top_k", defaultCfg.Memory.TopK)
+	v.SetDefault("memory.min_score", defaultCfg.Memory.MinScore)
+	v.SetDefault("memory.auto_extract", defaultCfg.Memory.AutoExtract)
+
+	// Knowledge 默认值
+	v.SetDefault("knowledge.enabled", defaultCfg.Knowledge.Enabled)
+	v.SetDefault("knowledge.embedding", defaultCfg.Knowledge.Embedding)
+	v.SetDefault("knowledge.top_k", defaultCfg.Knowledge.TopK)
+	v.SetDefault("knowledge.min_score", defaultCfg.Knowledge.MinScore)
+	v.SetDefault("knowledge.chunk_size", defaultCfg.Knowledge.ChunkSize)
+	v.SetDefault("knowledge.chunk_overlap", defaultCfg.Knowledge.ChunkOverlap)
+	v.SetDefault("knowledge.max_file_size", defaultCfg.Knowledge.MaxFileSize)
+
+	// Trash 默认值
+	v.SetDefault("trash.retention_days", defaultCfg.Trash.RetentionDays)
+
+	// Usage 默认值
+	v.SetDefault("usage.currency", defaultCfg.Usage.Currency)
+	v.SetDefault("usage.daily_budget", defaultCfg.Usage.DailyBudget)
+	v.SetDefault("usage.monthly_budget", defaultCfg.Usage.MonthlyBudget)
+	v.SetDefault("usage.budget_action", defaultCfg.Usage.BudgetAction)
+	// Storage 默认值
+	v.SetDefault("storage.backend", defaultCfg.Storage.Backend)
+	v.SetDefault("storage.dir", defaultCfg.Storage.Dir)
 }
 
 // syncToViper 将配置同步到 viper
@@ -284,6 +499,57 @@ func syncToViper(v *viper.Viper, cfg *Config) {
 	v.Set("log.max_backups", cfg.Log.MaxBackups)
 	v.Set("log.max_age", cfg.Log.MaxAge)
 	v.Set("log.compress", cfg.Log.Compress)
+
+	// Models 配置
+	v.Set("models.default", cfg.Models.Default)
+	v.Set("models.default_embedding", cfg.Models.DefaultEmbedding)
+	v.Set("models.title_model", cfg.Models.TitleModel)
+	v.Set("models.providers", cfg.Models.Providers)
+
+	// Tools 配置
+	v.Set("tools.default_policy", cfg.Tools.DefaultPolicy)
+	v.Set("tools.policies", cfg.Tools.Policies)
+	v.Set("tools.builtin", cfg.Tools.Builtin)
+
+	// MCP 配置
+	v.Set("mcp.servers", cfg.MCP.Servers)
+
+	// Gateway 配置
+	v.Set("gateway.enabled", cfg.Gateway.Enabled)
+	v.Set("gateway.api_key", cfg.Gateway.APIKey)
+
+	// Memory 配置
+	v.Set("memory.enabled", cfg.Memory.Enabled)
+	v.Set("memory.embedding", cfg.Memory.Embedding)
+	v.Set("memory.top_k", cfg.Memory.TopK)
+	v.Set("memory.min_score", cfg.Memory.MinScore)
+	v.Set("memory.auto_extract", cfg.Memory.AutoExtract)
+
+	// Knowledge 配置
+	v.Set("knowledge.enabled", cfg.Knowledge.Enabled)
+	v.Set("knowledge.embedding", cfg.Knowledge.Embedding)
+	v.Set("knowledge.top_k", cfg.Knowledge.TopK)
+	v.Set("knowledge.min_score", cfg.Knowledge.MinScore)
+	v.Set("knowledge.chunk_size", cfg.Knowledge.ChunkSize)
+	v.Set("knowledge.chunk_overlap", cfg.Knowledge.ChunkOverlap)
+	v.Set("knowledge.max_file_size", cfg.Knowledge.MaxFileSize)
+
+	// Trash 配置
+	v.Set("trash.retention_days", cfg.Trash.RetentionDays)
+
+	// Usage 配置
+	v.Set("usage.currency", cfg.Usage.Currency)
+	v.Set("usage.daily_budget", cfg.Usage.DailyBudget)
+	v.Set("usage.monthly_budget", cfg.Usage.MonthlyBudget)
+	v.Set("usage.budget_action", cfg.Usage.BudgetAction)
+	// Storage 配置
+	v.Set("storage.backend", cfg.Storage.Backend)
+	v.Set("storage.dir", cfg.Storage.Dir)
+}
+
+// decodeWithJSONTag 使用 json tag 解析配置,与写入配置文件时的字段名保持一致
+func decodeWithJSONTag(dc *mapstructure.DecoderConfig) {
+	dc.TagName = "json"
 }
 
 // GetViper 获取 viper 实例 (用于高级用法)
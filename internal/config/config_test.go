@@ -111,3 +111,55 @@ func TestSetMethods(t *testing.T) {
 	}
 
 }
+
+func TestModelsSaveAndLoad(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfgPath := filepath.Join(tmpDir, "config.json")
+
+	cfg := DefaultConfig()
+	cfg.Models.Default = "qwen-plus"
+	cfg.Models.Providers[1].APIKey = "sk-test"
+	cfg.Models.Providers[1].Enabled = true
+
+	if err := cfg.Save(cfgPath); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	loadedCfg, err := loadConfig(cfgPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	models := loadedCfg.GetModels()
+	if models.Default != "qwen-plus" {
+		t.Errorf("Expected default model 'qwen-plus', got '%s'", models.Default)
+	}
+	if len(models.Providers) != len(cfg.Models.Providers) {
+		t.Fatalf("Expected %d providers, got %d", len(cfg.Models.Providers), len(models.Providers))
+	}
+	if p := models.Providers[1]; p.APIKey != "sk-test" || !p.Enabled || p.Provider != Qwen {
+		t.Errorf("Unexpected provider after reload: %+v", p)
+	}
+}
+
+func TestToolPolicy(t *testing.T) {
+	tools := ToolsConfig{
+		DefaultPolicy: ToolPolicyAuto,
+		Policies:      map[string]ToolPolicy{"read_file": ToolPolicyDeny},
+	}
+	if p := tools.Policy("read_file"); p != ToolPolicyDeny {
+		t.Errorf("Expected deny, got %s", p)
+	}
+	if p := tools.Policy("calculator"); p != ToolPolicyAuto {
+		t.Errorf("Expected the default policy, got %s", p)
+	}
+
+	// 执行命令不能设为自动执行
+	if p := tools.Policy(ShellToolName); p != ToolPolicyAsk {
+		t.Errorf("Expected run_shell to require confirmation, got %s", p)
+	}
+	tools.Policies[ShellToolName] = ToolPolicyDeny
+	if p := tools.Policy(ShellToolName); p != ToolPolicyDeny {
+		t.Errorf("Expected run_shell to be denied, got %s", p)
+	}
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// EnvDataDir 指定数据目录的环境变量
+const EnvDataDir = "REMO_DATA_DIR"
+
+// ConfigFile 数据目录中配置文件的相对路径
+const ConfigFile = "config/app.json"
+
+// legacyData 旧版本保存在工作目录中的数据
+var legacyData = []string{ConfigFile, "clover.db", "remo.db", "remo.db-wal", "remo.db-shm", "backups"}
+
+// DataDir 返回数据目录并确保其存在:flag 指定的目录优先,其次是环境变量 EnvDataDir,
+// 否则使用系统的用户数据目录,Windows 为 %AppData%\Remo,macOS 为 ~/Library/Application Support/Remo,
+// 其他系统为 $XDG_DATA_HOME/remo,未设置 XDG_DATA_HOME 时为 ~/.local/share/remo
+func DataDir(flagValue string) (string, error) {
+	dir := flagValue
+	if dir == "" {
+		dir = os.Getenv(EnvDataDir)
+	}
+	if dir == "" {
+		var err error
+		if dir, err = defaultDataDir(); err != nil {
+			return "", err
+		}
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create data directory: %w", err)
+	}
+	return dir, nil
+}
+
+func defaultDataDir() (string, error) {
+	switch runtime.GOOS {
+	case "windows":
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			return filepath.Join(appData, "Remo"), nil
+		}
+	case "darwin":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, "Library", "Application Support", "Remo"), nil
+	default:
+		if xdg := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(xdg) {
+			return filepath.Join(xdg, "remo"), nil
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(home, "AppData", "Roaming", "Remo"), nil
+	}
+	return filepath.Join(home, ".local", "share", "remo"), nil
+}
+
+// ResolvePath 将相对路径解析为数据目录下的路径,绝对路径保持不变
+func ResolvePath(dataDir string, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dataDir, path)
+}
+
+// AdoptLegacyData 数据目录中还没有配置文件时,将旧版本保存在 workDir 中的配置和数据库复制到数据目录,
+// workDir 中的原文件保留不删除。返回复制的文件或目录
+func AdoptLegacyData(dataDir string, workDir string) ([]string, error) {
+	if same, _ := sameDir(dataDir, workDir); same {
+		return nil, nil
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, ConfigFile)); !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	var adopted []string
+	for _, name := range legacyData {
+		src := filepath.Join(workDir, name)
+		info, err := os.Stat(src)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return adopted, err
+		}
+
+		dst := filepath.Join(dataDir, name)
+		if info.IsDir() {
+			err = os.CopyFS(dst, os.DirFS(src))
+		} else {
+			err = copyFile(dst, src)
+		}
+		if err != nil {
+			return adopted, fmt.Errorf("failed to copy %s to the data directory: %w", name, err)
+		}
+		adopted = append(adopted, name)
+	}
+	return adopted, nil
+}
+
+func sameDir(a string, b string) (bool, error) {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	bi, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	return os.SameFile(ai, bi), nil
+}
+
+func copyFile(dst string, src string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0600)
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestDataDir(t *testing.T) {
+	flagDir := filepath.Join(t.TempDir(), "flag")
+	envDir := filepath.Join(t.TempDir(), "env")
+	t.Setenv(EnvDataDir, envDir)
+
+	if dir, err := DataDir(flagDir); err != nil || dir != flagDir {
+		t.Errorf("Expected the flag to take precedence, got %q %v", dir, err)
+	}
+	if dir, err := DataDir(""); err != nil || dir != envDir {
+		t.Errorf("Expected the environment variable to be used, got %q %v", dir, err)
+	}
+	if _, err := os.Stat(envDir); err != nil {
+		t.Errorf("Expected the data directory to be created: %v", err)
+	}
+
+	if runtime.GOOS == "linux" {
+		xdg := t.TempDir()
+		t.Setenv(EnvDataDir, "")
+		t.Setenv("XDG_DATA_HOME", xdg)
+		if dir, _ := DataDir(""); dir != filepath.Join(xdg, "remo") {
+			t.Errorf("Expected the XDG data directory, got %q", dir)
+		}
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	dataDir := t.TempDir()
+	if got := ResolvePath(dataDir, "logs/app.log"); got != filepath.Join(dataDir, "logs", "app.log") {
+		t.Errorf("Unexpected path %q", got)
+	}
+	abs := filepath.Join(t.TempDir(), "app.log")
+	if got := ResolvePath(dataDir, abs); got != abs {
+		t.Errorf("Expected absolute paths to be kept, got %q", got)
+	}
+}
+
+func TestAdoptLegacyData(t *testing.T) {
+	workDir, dataDir := t.TempDir(), t.TempDir()
+	os.MkdirAll(filepath.Join(workDir, "config"), 0755)
+	os.WriteFile(filepath.Join(workDir, ConfigFile), []byte(`{"app":{}}`), 0644)
+	os.MkdirAll(filepath.Join(workDir, "clover.db"), 0755)
+	os.WriteFile(filepath.Join(workDir, "clover.db", "000001.vlog"), []byte("data"), 0644)
+
+	adopted, err := AdoptLegacyData(dataDir, workDir)
+	if err != nil || len(adopted) != 2 {
+		t.Fatalf("Expected the config and clover database to be copied, got %v %v", adopted, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dataDir, "clover.db", "000001.vlog")); string(data) != "data" {
+		t.Errorf("Expected the database files to be copied, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, ConfigFile)); err != nil {
+		t.Errorf("Expected the original files to be kept: %v", err)
+	}
+
+	// 数据目录中已有配置时不再复制
+	if adopted, err := AdoptLegacyData(dataDir, workDir); err != nil || len(adopted) != 0 {
+		t.Errorf("Expected nothing to be copied, got %v %v", adopted, err)
+	}
+	if adopted, err := AdoptLegacyData(workDir, workDir); err != nil || len(adopted) != 0 {
+		t.Errorf("Expected nothing to be copied into the same directory, got %v %v", adopted, err)
+	}
+}
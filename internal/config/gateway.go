@@ -0,0 +1,7 @@
+package config
+
+// GatewayConfig OpenAI 兼容接口配置
+type GatewayConfig struct {
+	Enabled bool   `json:"enabled"` // 默认关闭
+	APIKey  string `json:"api_key"` // 请求需要携带 Authorization: Bearer <api_key>,未设置时拒绝所有请求
+}
@@ -0,0 +1,12 @@
+package config
+
+// KnowledgeConfig 本地知识库配置
+type KnowledgeConfig struct {
+	Enabled      bool    `json:"enabled"`
+	Embedding    string  `json:"embedding"`     // 向量模型,为空使用默认向量模型,不可用时只使用关键词检索
+	TopK         int     `json:"top_k"`         // 每轮注入的片段数
+	MinScore     float64 `json:"min_score"`     // 向量相似度低于该值且不包含关键词的片段不会被使用
+	ChunkSize    int     `json:"chunk_size"`    // 片段长度(字符)
+	ChunkOverlap int     `json:"chunk_overlap"` // 相邻片段重叠的长度(字符)
+	MaxFileSize  int     `json:"max_file_size"` // 单个文件的最大大小(MB)
+}
@@ -0,0 +1,27 @@
+package config
+
+// MCPTransport MCP 服务器的传输方式
+type MCPTransport string
+
+const (
+	MCPTransportStdio MCPTransport = "stdio" // 启动本地进程,通过标准输入输出通信
+	MCPTransportHTTP  MCPTransport = "http"  // Streamable HTTP
+)
+
+// MCPConfig MCP 配置
+type MCPConfig struct {
+	Servers []MCPServerConfig `json:"servers"`
+}
+
+// MCPServerConfig MCP 服务器配置
+type MCPServerConfig struct {
+	Name      string            `json:"name"`      // 服务器名称,唯一
+	Transport MCPTransport      `json:"transport"` // stdio, http
+	Command   string            `json:"command"`   // stdio: 启动命令
+	Args      []string          `json:"args"`      // stdio: 命令参数
+	Env       map[string]string `json:"env"`       // stdio: 额外的环境变量
+	URL       string            `json:"url"`       // http: 服务地址
+	Headers   map[string]string `json:"headers"`   // http: 额外的请求头
+	Timeout   int               `json:"timeout"`   // 连接和调用超时时间(秒),0 使用默认值
+	Enabled   bool              `json:"enabled"`
+}
@@ -0,0 +1,10 @@
+package config
+
+// MemoryConfig 长期记忆配置
+type MemoryConfig struct {
+	Enabled     bool    `json:"enabled"`
+	Embedding   string  `json:"embedding"`    // 向量模型,为空使用默认向量模型
+	TopK        int     `json:"top_k"`        // 每轮检索的记忆数
+	MinScore    float64 `json:"min_score"`    // 相似度低于该值的记忆不会被使用
+	AutoExtract bool    `json:"auto_extract"` // 每轮对话结束后自动提取记忆
+}
@@ -5,8 +5,10 @@ import "fmt"
 type Provider string
 
 const (
-	Qwen   Provider = "qwen"
-	Ollama Provider = "ollama"
+	DeepSeek Provider = "deepseek"
+	Qwen     Provider = "qwen"
+	Ollama   Provider = "ollama"
+	OpenAI   Provider = "openai" //任意 OpenAI 兼容接口
 )
 
 type ChatModelDefine struct {
@@ -14,6 +16,36 @@ type ChatModelDefine struct {
 	Provider        Provider `json:"provider"`
 	SupportThinking bool     `json:"support_thinking"` //是否支持思考
 	IsMultimodal    bool     `json:"is_multimodal"`    //是否是多模态模型
+	ContextWindow   int      `json:"context_window"`   //上下文长度(token),0 使用默认值
+	InputPrice      float64  `json:"input_price"`      //每百万输入 token 的价格
+	OutputPrice     float64  `json:"output_price"`     //每百万输出 token 的价格
+}
+
+// EmbeddingModelDefine 向量模型定义
+type EmbeddingModelDefine struct {
+	Model      string `json:"model"`
+	Dimensions int    `json:"dimensions"` // 向量维度,0 使用模型默认值
+}
+
+// ProviderConfig 模型供应商配置
+type ProviderConfig struct {
+	Name      string            `json:"name"`        // 供应商名称,唯一,可用于 "name/model" 形式引用模型
+	Provider  Provider          `json:"provider"`    // 供应商类型
+	BaseURL   string            `json:"base_url"`    // 接口地址,为空则使用默认地址
+	APIKey    string            `json:"api_key"`     // API Key
+	APIKeyEnv string            `json:"api_key_env"` // 从环境变量读取 API Key,APIKey 为空时生效
+	Enabled   bool              `json:"enabled"`     // 是否启用
+	Models    []ChatModelDefine `json:"models"`      // 该供应商下可用的模型
+	// 该供应商下可用的向量模型,仅支持 OpenAI 兼容接口的供应商
+	Embeddings []EmbeddingModelDefine `json:"embeddings"`
+}
+
+// ModelConfig 模型配置
+type ModelConfig struct {
+	Default          string           `json:"default"`           // 默认模型
+	DefaultEmbedding string           `json:"default_embedding"` // 默认向量模型,为空时使用第一个可用的向量模型
+	TitleModel       string           `json:"title_model"`       // 生成会话标题的模型,为空时使用会话的模型
+	Providers        []ProviderConfig `json:"providers"`         // 模型供应商
 }
 
 //func GetDefaultModels(provider Provider) ([]ChatModelDefine, error) {
@@ -24,11 +56,17 @@ func GetDefaultEndpoint(provider Provider) (string, error) {
 
 	switch provider {
 
+	case DeepSeek:
+		return "https://api.deepseek.com/beta", nil
+
 	case Qwen:
 		return "https://dashscope.aliyuncs.com/compatible-mode/v1", nil
 
 	case Ollama:
-		return "https://localhost:11434", nil
+		return "http://localhost:11434/v1", nil
+
+	case OpenAI:
+		return "https://api.openai.com/v1", nil
 	}
 
 	return "", fmt.Errorf("unknown provider: %s", provider)
@@ -0,0 +1,15 @@
+package config
+
+// StorageBackend 会话数据的存储后端
+type StorageBackend string
+
+const (
+	StorageSQLite StorageBackend = "sqlite" // SQLite,第一次使用时迁移 clover 中已有的会话
+	StorageClover StorageBackend = "clover"
+)
+
+// StorageConfig 数据存储配置,长期记忆、知识库和用量记录仍保存在 clover 中
+type StorageConfig struct {
+	Backend StorageBackend `json:"backend"` // 会话、消息、附件和断点的存储后端
+	Dir     string         `json:"dir"`     // clover.db 和 remo.db 所在的目录,相对路径相对于数据目录
+}
@@ -0,0 +1,48 @@
+package config
+
+// ToolPolicy 工具调用策略
+type ToolPolicy string
+
+const (
+	ToolPolicyAuto ToolPolicy = "auto" // 直接执行
+	ToolPolicyAsk  ToolPolicy = "ask"  // 执行前需要用户确认
+	ToolPolicyDeny ToolPolicy = "deny" // 禁止使用
+)
+
+// ToolsConfig 工具配置
+type ToolsConfig struct {
+	DefaultPolicy ToolPolicy            `json:"default_policy"` // 未单独配置的工具使用的策略
+	Policies      map[string]ToolPolicy `json:"policies"`       // 工具名 -> 策略
+	Builtin       BuiltinToolsConfig    `json:"builtin"`        // 内置工具
+}
+
+// BuiltinToolsConfig 内置工具配置
+type BuiltinToolsConfig struct {
+	ReadFile      bool     `json:"read_file"`      // 读取文件
+	ListDir       bool     `json:"list_dir"`       // 列出目录
+	Shell         bool     `json:"shell"`          // 执行命令
+	HTTPFetch     bool     `json:"http_fetch"`     // 获取网页
+	DateTime      bool     `json:"datetime"`       // 当前时间
+	Calculator    bool     `json:"calculator"`     // 计算器
+	AllowedDirs   []string `json:"allowed_dirs"`   // 文件和命令工具允许访问的目录
+	ShellTimeout  int      `json:"shell_timeout"`  // 命令超时时间(秒)
+	HTTPAllowlist []string `json:"http_allowlist"` // 允许访问的域名,支持 *.example.com
+}
+
+// ShellToolName 执行命令的内置工具名
+//
+// 命令在沙箱中运行,只能写入 allowed_dirs,不能读取家目录中的其他文件,不能访问网络;
+// 没有可用沙箱的平台不提供该工具。命令仍能读取系统文件,因此该工具最多只能设为 ask,不能自动执行
+const ShellToolName = "run_shell"
+
+// Policy 获取工具的调用策略
+func (t ToolsConfig) Policy(name string) ToolPolicy {
+	p := t.DefaultPolicy
+	if v, ok := t.Policies[name]; ok && v != "" {
+		p = v
+	}
+	if p == "" || p == ToolPolicyAuto && name == ShellToolName {
+		return ToolPolicyAsk
+	}
+	return p
+}
@@ -0,0 +1,6 @@
+package config
+
+// TrashConfig 回收站配置
+type TrashConfig struct {
+	RetentionDays int `json:"retention_days"` // 会话在回收站中保留的天数,超过后彻底删除,小于等于 0 表示不自动清空
+}
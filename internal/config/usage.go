@@ -0,0 +1,17 @@
+package config
+
+// BudgetAction 超出预算时的处理方式
+type BudgetAction string
+
+const (
+	BudgetActionWarn  BudgetAction = "warn"  // 发送提醒后继续对话
+	BudgetActionBlock BudgetAction = "block" // 拒绝新的对话
+)
+
+// UsageConfig 用量统计和预算配置,模型价格在 ChatModelDefine 中配置
+type UsageConfig struct {
+	Currency      string       `json:"currency"`       // 价格和预算的货币单位,仅用于显示
+	DailyBudget   float64      `json:"daily_budget"`   // 每日预算,0 表示不限制
+	MonthlyBudget float64      `json:"monthly_budget"` // 每月预算,0 表示不限制
+	BudgetAction  BudgetAction `json:"budget_action"`
+}
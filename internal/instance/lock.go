@@ -0,0 +1,45 @@
+// Package instance 保证同一数据目录只被一个应用实例使用
+package instance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// LockFile 数据目录中锁文件的文件名,内容为持有锁的进程 ID
+const LockFile = "remo.lock"
+
+// ErrLocked 数据目录已被其他实例使用
+var ErrLocked = errors.New("data directory is used by another instance")
+
+// Lock 数据目录的锁,进程退出时由系统释放
+type Lock struct {
+	f *os.File
+}
+
+// Acquire 锁定数据目录,已被其他实例锁定时返回 ErrLocked
+func Acquire(dataDir string) (*Lock, error) {
+	f, err := lockFile(filepath.Join(dataDir, LockFile))
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+	}
+	return &Lock{f: f}, nil
+}
+
+// Release 释放锁
+func (l *Lock) Release() error {
+	return l.f.Close()
+}
+
+// ID 返回数据目录对应的实例 ID,用于将第二个实例的启动转发给使用同一数据目录的第一个实例
+func ID(dataDir string) string {
+	sum := sha256.Sum256([]byte(dataDir))
+	return "com.antnohuabei.remo." + hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,43 @@
+package instance
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestAcquire(t *testing.T) {
+	dir := t.TempDir()
+	lock, err := Acquire(dir)
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, LockFile)); string(data) != strconv.Itoa(os.Getpid()) {
+		t.Errorf("Expected the lock file to contain the pid, got %q", data)
+	}
+
+	if _, err := Acquire(dir); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected ErrLocked, got %v", err)
+	}
+	// 其他数据目录不受影响
+	other, err := Acquire(t.TempDir())
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	other.Release()
+
+	lock.Release()
+	lock, err = Acquire(dir)
+	if err != nil {
+		t.Fatalf("Expected the lock to be released, got %v", err)
+	}
+	lock.Release()
+}
+
+func TestID(t *testing.T) {
+	if ID("/a") != ID("/a") || ID("/a") == ID("/b") {
+		t.Error("Expected the id to depend only on the data directory")
+	}
+}
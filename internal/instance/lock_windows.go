@@ -0,0 +1,27 @@
+package instance
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// errSharingViolation 文件已被其他进程以不允许共享写入的方式打开
+const errSharingViolation syscall.Errno = 32
+
+// lockFile 以不允许其他进程写入的共享模式打开文件,文件句柄关闭前其他实例无法再次打开
+func lockFile(path string) (*os.File, error) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	h, err := syscall.CreateFile(name, syscall.GENERIC_READ|syscall.GENERIC_WRITE, syscall.FILE_SHARE_READ,
+		nil, syscall.OPEN_ALWAYS, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		if errors.Is(err, errSharingViolation) {
+			return nil, ErrLocked
+		}
+		return nil, err
+	}
+	return os.NewFile(uintptr(h), path), nil
+}
@@ -26,11 +26,11 @@ type Config struct {
 	Compress   bool   // 是否压缩旧日志
 }
 
-// DefaultConfig 返回默认日志配置
+// DefaultConfig 返回默认日志配置,只输出到控制台。
+// 应用启动时按配置将日志写入数据目录,避免未初始化时在工作目录中创建日志文件
 func DefaultConfig() *Config {
 	return &Config{
 		Level:      "info",
-		OutputFile: "logs/app.log",
 		MaxSize:    10,
 		MaxBackups: 5,
 		MaxAge:     30,
@@ -2,10 +2,13 @@ package main
 
 import (
 	"embed"
+	"flag"
 	"github.com/AntNoHuabei/Remo/pkg/services"
 	"log"
 	"unsafe"
 
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/AntNoHuabei/Remo/internal/instance"
 	"github.com/AntNoHuabei/Remo/internal/mousehook"
 	"github.com/wailsapp/wails/v3/pkg/events"
 
@@ -25,6 +28,13 @@ var assets embed.FS
 // logs any error that might occur.
 func main() {
 
+	dataDirFlag := flag.String("data-dir", "", "数据目录,默认为系统的用户数据目录,也可以通过环境变量 "+config.EnvDataDir+" 指定")
+	flag.Parse()
+	dataDir, err := config.DataDir(*dataDirFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Create a new Wails application by providing the necessary options.
 	// Variables 'Name' and 'Description' are for application metadata.
 	// 'Assets' configures the asset server with the 'FS' variable pointing to the frontend files.
@@ -35,9 +45,16 @@ func main() {
 		Description: "一个基于Wails的AI悬浮球应用",
 		Services: []application.Service{
 			application.NewService(&services.MouseEventService{}),
-			application.NewServiceWithOptions(services.NewGinService(), application.ServiceOptions{
-				Route: "/api",
-			}),
+		},
+		// 使用同一数据目录的第二个实例启动时,激活第一个实例的窗口后退出
+		SingleInstance: &application.SingleInstanceOptions{
+			UniqueID: instance.ID(dataDir),
+			OnSecondInstanceLaunch: func(data application.SecondInstanceData) {
+				if w, ok := application.Get().Window.GetByName("WinMain"); ok {
+					w.Show()
+					w.Focus()
+				}
+			},
 		},
 		Assets: application.AssetOptions{
 			Handler: application.AssetFileServerFS(assets),
@@ -117,6 +134,16 @@ func main() {
 		},
 	})
 
+	// 锁定数据目录后再打开数据库,避免两个进程同时写入同一数据库
+	lock, err := instance.Acquire(dataDir)
+	if err != nil {
+		log.Fatalf("failed to lock data directory %s: %v", dataDir, err)
+	}
+	defer lock.Release()
+	app.RegisterService(application.NewServiceWithOptions(services.NewGinService(dataDir), application.ServiceOptions{
+		Route: "/api",
+	}))
+
 	// Create a new window with the necessary options.
 	// 'Title' is the title of the window.
 	// 'Mac' options tailor the window when running on macOS.
@@ -181,7 +208,7 @@ func main() {
 	systray.SetMenu(trayMenu)
 
 	// Run the application. This blocks until the application has been exited.
-	err := app.Run()
+	err = app.Run()
 
 	// If an error occurred while running the application, log it and exit.
 	if err != nil {
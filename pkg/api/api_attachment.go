@@ -0,0 +1,39 @@
+package api
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+
+	"github.com/AntNoHuabei/Remo/pkg/api/request"
+	"github.com/AntNoHuabei/Remo/pkg/chat"
+	"github.com/gin-gonic/gin"
+)
+
+// AttachmentGet 返回消息附件的内容,用于在对话中显示图片和下载文件
+func AttachmentGet(c *gin.Context) {
+
+	a, err := chat.GetAttachment(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, Fail(err.Error()))
+		return
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": a.Name}))
+	c.Data(http.StatusOK, a.MimeType, a.Data)
+}
+
+// newAttachments 将请求中的附件转换为消息附件
+func newAttachments(attachments []request.Attachment) ([]*chat.Attachment, error) {
+	output := make([]*chat.Attachment, 0, len(attachments))
+	for _, a := range attachments {
+		if a.Data == "" {
+			return nil, fmt.Errorf("attachment %q has no data", a.Name)
+		}
+		attachment, err := chat.DecodeAttachment(a.Kind, a.Name, a.MimeType, a.Data)
+		if err != nil {
+			return nil, err
+		}
+		output = append(output, attachment)
+	}
+	return output, nil
+}
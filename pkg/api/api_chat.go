@@ -1,38 +1,140 @@
 package api
 
 import (
+	"errors"
 	"github.com/AntNoHuabei/Remo/pkg/api/request"
 	"github.com/AntNoHuabei/Remo/pkg/api/response"
 	"github.com/AntNoHuabei/Remo/pkg/chat"
+	"github.com/AntNoHuabei/Remo/pkg/usage"
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"strconv"
 )
 
 func Chat(ctx *gin.Context) {
 
-	sendError := func(err error, requestId string) {
-		ctx.SSEvent("error", response.ChatResponse{Error: err, RequestID: requestId})
-		ctx.Writer.Flush()
+	sendError := func(code string, err error, requestId string) {
+		writeEvent(ctx, response.NewError(requestId, code, err))
 		ctx.Abort()
 	}
 
 	var req request.ChatRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		sendError(err, req.RequestId)
+		sendError(response.ErrorInvalidRequest, err, req.RequestId)
 		return
 	}
-	agent, err := chat.NewContinuousAgent(ctx)
+	attachments, err := newAttachments(req.Attachments)
 	if err != nil {
-		sendError(err, req.RequestId)
+		sendError(response.ErrorInvalidRequest, err, req.RequestId)
+		return
+	}
+	agent, err := chat.NewContinuousAgent(ctx, req.Model)
+	if err != nil {
+		sendError(response.ErrorInvalidRequest, err, req.RequestId)
 		return
 	}
 
 	err = agent.Recover(ctx, req.Session)
 	if err != nil {
-		sendError(err, req.RequestId)
+		sendError(response.ErrorInvalidRequest, err, req.RequestId)
 		return
 	}
 
+	startStream(ctx)
+
+	// 客户端断开只停止推送,生成继续进行,可以通过 ChatStream 重新连接
+	output, err := agent.Chat(ctx.Request.Context(), &chat.Message{
+		Content:     req.Message,
+		Role:        "user",
+		Session:     req.Session,
+		RequestId:   req.RequestId,
+		Attachments: attachments,
+	})
+	if err != nil {
+		sendError(errorCode(err, response.ErrorGenerationFailed), err, req.RequestId)
+		return
+	}
+	writeStream(ctx, output)
+}
+
+// ChatConfirm 确认或拒绝等待中的工具调用,并以 SSE 返回后续生成
+func ChatConfirm(ctx *gin.Context) {
+
+	sendError := func(code string, err error, requestId string) {
+		writeEvent(ctx, response.NewError(requestId, code, err))
+		ctx.Abort()
+	}
+
+	var req request.ChatConfirmRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		sendError(response.ErrorInvalidRequest, err, req.RequestId)
+		return
+	}
+	agent, err := chat.NewContinuousAgent(ctx, req.Model)
+	if err != nil {
+		sendError(response.ErrorInvalidRequest, err, req.RequestId)
+		return
+	}
+
+	err = agent.Recover(ctx, req.Session)
+	if err != nil {
+		sendError(response.ErrorInvalidRequest, err, req.RequestId)
+		return
+	}
+
+	startStream(ctx)
+
+	output, err := agent.Confirm(ctx.Request.Context(), req.RequestId, req.InterruptId, &chat.ToolDecision{
+		Approved: req.Approved,
+		Reason:   req.Reason,
+	})
+	if err != nil {
+		sendError(errorCode(err, response.ErrorGenerationFailed), err, req.RequestId)
+		return
+	}
+	writeStream(ctx, output)
+}
+
+// ChatStream 重新连接请求的事件流,回放 Last-Event-ID 之后的事件后继续推送
+func ChatStream(ctx *gin.Context) {
+
+	lastEventId := -1
+	id := ctx.GetHeader("Last-Event-ID")
+	if id == "" {
+		id = ctx.Query("last_event_id")
+	}
+	if id != "" {
+		n, err := strconv.Atoi(id)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, Fail("invalid Last-Event-ID: "+id))
+			return
+		}
+		lastEventId = n
+	}
+
+	output, err := chat.Subscribe(ctx.Request.Context(), ctx.Param("request_id"), lastEventId)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, Fail(err.Error()))
+		return
+	}
+
+	startStream(ctx)
+	writeStream(ctx, output)
+}
+
+// errorCode 超出预算时返回 budget_exceeded,模型不支持附件时返回 not_multimodal,否则返回 code
+func errorCode(err error, code string) string {
+	if errors.Is(err, usage.ErrBudgetExceeded) {
+		return response.ErrorBudgetExceeded
+	}
+	if errors.Is(err, chat.ErrNotMultimodal) {
+		return response.ErrorNotMultimodal
+	}
+	return code
+}
+
+func startStream(ctx *gin.Context) {
 	ctx.Status(http.StatusOK)
 	ctx.Header("Content-Type", "text/event-stream")
 	ctx.Header("Cache-Control", "no-cache")
@@ -40,20 +142,36 @@ func Chat(ctx *gin.Context) {
 	ctx.Header("Transfer-Encoding", "chunked")
 
 	ctx.Writer.Flush()
+}
+
+func writeStream(ctx *gin.Context, output <-chan response.ChatResponse) {
+	for res := range output {
+		writeEvent(ctx, res)
+	}
+}
 
-	output, err := agent.Chat(ctx, &chat.Message{
-		Content:   req.Message,
-		Role:      "user",
-		Session:   req.Session,
-		RequestId: req.RequestId,
+// writeEvent 以 SSE 发送一个事件,事件序号作为 id,客户端可据此续传
+func writeEvent(ctx *gin.Context, res response.ChatResponse) {
+	ctx.Render(-1, sse.Event{
+		Id:    strconv.Itoa(res.Index),
+		Event: res.Event,
+		Data:  res,
 	})
+	ctx.Writer.Flush()
+}
+
+func ChatAbort(c *gin.Context) {
+
+	var req request.ChatAbortRequest
+	err := c.ShouldBindJSON(&req)
 	if err != nil {
-		sendError(err, req.RequestId)
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
 		return
 	}
-	for res := range output {
 
-		ctx.SSEvent("message", res)
-		ctx.Writer.Flush()
+	if err := chat.Abort(req.RequestId); err != nil {
+		c.JSON(http.StatusNotFound, Fail(err.Error()))
+	} else {
+		c.JSON(http.StatusOK, Success(nil))
 	}
 }
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/AntNoHuabei/Remo/pkg/api/request"
+	"github.com/AntNoHuabei/Remo/pkg/chat"
+	"github.com/AntNoHuabei/Remo/pkg/knowledge"
+	"github.com/gin-gonic/gin"
+)
+
+// KnowledgeUpload 上传文件到知识库,multipart 表单的 file 字段可以有多个文件
+func KnowledgeUpload(c *gin.Context) {
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+		return
+	}
+	files := form.File["file"]
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, Fail("no file uploaded"))
+		return
+	}
+
+	output := make([]*knowledge.Document, 0, len(files))
+	for _, fh := range files {
+		f, err := fh.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Fail(err.Error()))
+			return
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Fail(err.Error()))
+			return
+		}
+
+		doc, err := knowledge.Get().Add(c.Request.Context(), fh.Filename, data)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Fail(err.Error()))
+			return
+		}
+		output = append(output, doc)
+	}
+	c.JSON(http.StatusOK, Success(output))
+}
+
+func KnowledgeList(c *gin.Context) {
+
+	docs, err := knowledge.Get().List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Fail(err.Error()))
+	} else {
+		c.JSON(http.StatusOK, Success(docs))
+	}
+}
+
+func KnowledgeDelete(c *gin.Context) {
+
+	var req request.KnowledgeDeleteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+		return
+	}
+
+	if err := knowledge.Get().Delete(req.Id); err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+	} else {
+		c.JSON(http.StatusOK, Success(nil))
+	}
+}
+
+// KnowledgeSearch 检索知识库,返回格式与对话中的引用事件一致
+func KnowledgeSearch(c *gin.Context) {
+
+	var req request.KnowledgeSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+		return
+	}
+
+	results, err := knowledge.Get().Search(c.Request.Context(), req.Query, req.TopK)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Fail(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, Success(chat.Citations(results)))
+}
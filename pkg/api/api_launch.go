@@ -0,0 +1,34 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LaunchTokenHeader 通过 TCP 监听访问流式对话接口时携带启动令牌的请求头
+const LaunchTokenHeader = "X-Launch-Token"
+
+// launchToken 每次启动随机生成,只能通过 Wails 获取,本机的其他进程和浏览器中的网页无法得到
+var launchToken = newLaunchToken()
+
+func newLaunchToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// LaunchToken 返回本次启动的令牌,前端访问 TCP 监听上的接口时放在 LaunchTokenHeader 中
+func LaunchToken(c *gin.Context) {
+	c.JSON(http.StatusOK, Success(launchToken))
+}
+
+// ValidLaunchToken 判断 token 是否为本次启动的令牌
+func ValidLaunchToken(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(launchToken)) == 1
+}
@@ -0,0 +1,14 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/AntNoHuabei/Remo/pkg/mcp"
+	"github.com/gin-gonic/gin"
+)
+
+// MCPServers 返回 MCP 服务器的连接状态及其提供的工具和提示词
+func MCPServers(c *gin.Context) {
+
+	c.JSON(http.StatusOK, Success(mcp.Get().Servers(c.Request.Context())))
+}
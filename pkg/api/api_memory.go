@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/AntNoHuabei/Remo/pkg/api/request"
+	"github.com/AntNoHuabei/Remo/pkg/api/response"
+	"github.com/AntNoHuabei/Remo/pkg/memory"
+	"github.com/gin-gonic/gin"
+)
+
+// MemoryList 返回所有长期记忆,最近更新的在前
+func MemoryList(c *gin.Context) {
+
+	memories, err := memory.Get().List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Fail(err.Error()))
+		return
+	}
+
+	output := make([]response.Memory, 0, len(memories))
+	for _, m := range memories {
+		output = append(output, toMemoryResponse(m))
+	}
+	c.JSON(http.StatusOK, Success(output))
+}
+
+func MemoryDelete(c *gin.Context) {
+
+	var req request.MemoryDeleteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+		return
+	}
+
+	if err := memory.Get().Delete(req.Id); err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+	} else {
+		c.JSON(http.StatusOK, Success(nil))
+	}
+}
+
+// MemoryEdit 修改记忆内容,向量会重新生成
+func MemoryEdit(c *gin.Context) {
+
+	var req request.MemoryEditRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+		return
+	}
+
+	m, err := memory.Get().Edit(c.Request.Context(), req.Id, req.Content)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+	} else {
+		c.JSON(http.StatusOK, Success(toMemoryResponse(m)))
+	}
+}
+
+func toMemoryResponse(m *memory.Memory) response.Memory {
+	return response.Memory{
+		Id:          m.Id,
+		Content:     m.Content,
+		Session:     m.Session,
+		CreatedTime: m.CreatedTime,
+		UpdatedTime: m.UpdatedTime,
+	}
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/AntNoHuabei/Remo/pkg/api/request"
+	"github.com/AntNoHuabei/Remo/pkg/api/response"
+	"github.com/AntNoHuabei/Remo/pkg/chat"
+	"github.com/gin-gonic/gin"
+)
+
+// MessageEdit 修改用户消息,原消息保留为另一个版本,并以 SSE 返回新的回复
+func MessageEdit(ctx *gin.Context) {
+
+	sendError := func(code string, err error, requestId string) {
+		writeEvent(ctx, response.NewError(requestId, code, err))
+		ctx.Abort()
+	}
+
+	var req request.MessageEditRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		sendError(response.ErrorInvalidRequest, err, req.RequestId)
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		sendError(response.ErrorInvalidRequest, errors.New("content is required"), req.RequestId)
+		return
+	}
+	agent, err := recoverAgent(ctx, req.Session, req.Model)
+	if err != nil {
+		sendError(response.ErrorInvalidRequest, err, req.RequestId)
+		return
+	}
+
+	startStream(ctx)
+
+	output, err := agent.Edit(ctx.Request.Context(), req.Id, req.Content, req.RequestId)
+	if err != nil {
+		sendError(errorCode(err, response.ErrorInvalidRequest), err, req.RequestId)
+		return
+	}
+	writeStream(ctx, output)
+}
+
+// MessageRegenerate 为同一条用户消息生成新的回复,之前的回复保留为另一个版本
+func MessageRegenerate(ctx *gin.Context) {
+
+	sendError := func(code string, err error, requestId string) {
+		writeEvent(ctx, response.NewError(requestId, code, err))
+		ctx.Abort()
+	}
+
+	var req request.MessageRegenerateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		sendError(response.ErrorInvalidRequest, err, req.RequestId)
+		return
+	}
+	agent, err := recoverAgent(ctx, req.Session, req.Model)
+	if err != nil {
+		sendError(response.ErrorInvalidRequest, err, req.RequestId)
+		return
+	}
+
+	startStream(ctx)
+
+	output, err := agent.Regenerate(ctx.Request.Context(), req.Id, req.RequestId)
+	if err != nil {
+		sendError(errorCode(err, response.ErrorInvalidRequest), err, req.RequestId)
+		return
+	}
+	writeStream(ctx, output)
+}
+
+// MessageSwitch 切换到包含指定消息的分支,返回切换后的消息
+func MessageSwitch(c *gin.Context) {
+
+	var req request.MessageSwitchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+		return
+	}
+
+	thread, err := chat.SwitchBranch(req.Session, req.Id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+	} else {
+		c.JSON(http.StatusOK, Success(newSessionMessagesResponse(thread)))
+	}
+}
+
+func recoverAgent(ctx *gin.Context, session string, model string) (*chat.ContinuousAgent, error) {
+	agent, err := chat.NewContinuousAgent(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+	if err := agent.Recover(ctx, session); err != nil {
+		return nil, err
+	}
+	return agent, nil
+}
@@ -0,0 +1,12 @@
+package api
+
+import (
+	"github.com/AntNoHuabei/Remo/pkg/provider"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+func ModelList(c *gin.Context) {
+
+	c.JSON(http.StatusOK, Success(provider.Get().List()))
+}
@@ -0,0 +1,473 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/AntNoHuabei/Remo/pkg/api/request"
+	"github.com/AntNoHuabei/Remo/pkg/api/response"
+	"github.com/AntNoHuabei/Remo/pkg/chat"
+	"github.com/AntNoHuabei/Remo/pkg/persist"
+	"github.com/AntNoHuabei/Remo/pkg/provider"
+	"github.com/AntNoHuabei/Remo/pkg/usage"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/eino-contrib/jsonschema"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// GatewayAuth OpenAI 兼容接口的开关和 API Key 校验,必须设置 API Key 才能使用
+func GatewayAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := config.Get().GetGateway()
+		if !cfg.Enabled {
+			openAIError(c, http.StatusNotFound, "not_found_error", "gateway is disabled")
+			c.Abort()
+			return
+		}
+		// API Key 保存在密钥存储中,旧的配置文件中的值在移动前仍然有效
+		apiKey := cfg.APIKey
+		if apiKey == "" {
+			key, _, err := persist.GetSecret(persist.GatewaySecret)
+			if err != nil {
+				openAIError(c, http.StatusServiceUnavailable, "api_error", err.Error())
+				c.Abort()
+				return
+			}
+			apiKey = key
+		}
+		// 未设置 API Key 时拒绝所有请求,避免接口在无认证的情况下对外提供
+		if apiKey == "" {
+			openAIError(c, http.StatusForbidden, "permission_error", "gateway api key is not configured")
+			c.Abort()
+			return
+		}
+		token, _ := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(apiKey)) != 1 {
+			openAIError(c, http.StatusUnauthorized, "invalid_request_error", "invalid api key")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// OpenAIModels 返回所有可用的对话模型和向量模型
+func OpenAIModels(c *gin.Context) {
+
+	list := response.ModelList{Object: "list", Data: make([]response.ModelObject, 0)}
+	for _, m := range provider.Get().List() {
+		list.Data = append(list.Data, response.ModelObject{Id: m.Name, Object: "model", OwnedBy: m.Provider.Name})
+	}
+	for _, m := range provider.Get().ListEmbeddings() {
+		list.Data = append(list.Data, response.ModelObject{Id: m.Name, Object: "model", OwnedBy: m.Provider.Name})
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// OpenAIEmbeddings 使用配置的向量模型计算向量
+func OpenAIEmbeddings(c *gin.Context) {
+
+	var req request.EmbeddingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	if len(req.Input) == 0 {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", "input is required")
+		return
+	}
+
+	embedder, m, err := provider.Get().NewEmbedder(c.Request.Context(), req.Model)
+	if err != nil {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	vectors, err := embedder.EmbedStrings(c.Request.Context(), req.Input)
+	if err != nil {
+		openAIError(c, http.StatusBadGateway, "api_error", err.Error())
+		return
+	}
+
+	list := response.EmbeddingList{Object: "list", Model: m.Name, Data: make([]response.EmbeddingObject, 0, len(vectors))}
+	for i, v := range vectors {
+		list.Data = append(list.Data, response.EmbeddingObject{Object: "embedding", Index: i, Embedding: v})
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// OpenAIChatCompletions 通过配置的模型供应商完成对话,支持流式和非流式
+func OpenAIChatCompletions(c *gin.Context) {
+
+	var req request.ChatCompletionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	if len(req.Messages) == 0 {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", "messages is required")
+		return
+	}
+	messages, err := toSchemaMessages(req.Messages)
+	if err != nil {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	if req.Session != "" {
+		session, err := chat.GetSession(req.Session)
+		if err == nil {
+			err = session.CheckActive()
+		}
+		if err != nil {
+			openAIError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	cm, m, err := provider.Get().NewChatModel(ctx, req.Model)
+	if err != nil {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	if len(req.Tools) > 0 {
+		tools, err := toToolInfos(req.Tools)
+		if err != nil {
+			openAIError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		if cm, err = cm.WithTools(tools); err != nil {
+			openAIError(c, http.StatusInternalServerError, "api_error", err.Error())
+			return
+		}
+	}
+
+	cm = chat.MeterTools(cm, m, req.Session, usage.PurposeGateway)
+
+	maxTokens := req.MaxTokens
+	if req.MaxCompletionTokens != nil {
+		maxTokens = req.MaxCompletionTokens
+	}
+	opts := m.ModelOptions(provider.GenerateOptions{
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		MaxTokens:   maxTokens,
+	})
+	if len(req.Stop) > 0 {
+		opts = append(opts, model.WithStop(req.Stop))
+	}
+
+	completion := &completion{
+		id:      "chatcmpl-" + uuid.New().String(),
+		created: time.Now().Unix(),
+		started: time.Now(),
+		model:   m,
+		req:     &req,
+	}
+
+	if req.Stream {
+		completion.stream(c, cm, messages, opts)
+	} else {
+		completion.generate(c, cm, messages, opts)
+	}
+}
+
+// completion 一次 /v1/chat/completions 请求
+type completion struct {
+	id      string
+	created int64
+	started time.Time
+	// firstToken 收到第一个 token 的毫秒数
+	firstToken int64
+	model      *provider.Model
+	req        *request.ChatCompletionRequest
+}
+
+func (cp *completion) generate(c *gin.Context, cm model.ToolCallingChatModel, messages []*schema.Message, opts []model.Option) {
+	msg, err := cm.Generate(c.Request.Context(), messages, opts...)
+	if err != nil {
+		openAIError(c, http.StatusBadGateway, "api_error", err.Error())
+		return
+	}
+	cp.firstToken = time.Since(cp.started).Milliseconds()
+	cp.save(msg)
+
+	c.JSON(http.StatusOK, response.ChatCompletion{
+		Id:      cp.id,
+		Object:  "chat.completion",
+		Created: cp.created,
+		Model:   cp.model.Name,
+		Choices: []response.ChatCompletionChoice{{
+			Message: response.ChatCompletionMessage{
+				Role:             "assistant",
+				Content:          msg.Content,
+				ReasoningContent: msg.ReasoningContent,
+				ToolCalls:        toCompletionToolCalls(msg.ToolCalls),
+			},
+			FinishReason: finishReason(msg),
+		}},
+		Usage: completionUsage(msg),
+	})
+}
+
+func (cp *completion) stream(c *gin.Context, cm model.ToolCallingChatModel, messages []*schema.Message, opts []model.Option) {
+	sr, err := cm.Stream(c.Request.Context(), messages, opts...)
+	if err != nil {
+		openAIError(c, http.StatusBadGateway, "api_error", err.Error())
+		return
+	}
+	defer sr.Close()
+
+	startStream(c)
+
+	chunks := make([]*schema.Message, 0)
+	first := true
+	for {
+		m, err := sr.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			cp.writeData(c, response.OpenAIError{Error: response.OpenAIErrorBody{Message: err.Error(), Type: "api_error"}})
+			return
+		}
+		chunks = append(chunks, m)
+		if cp.firstToken == 0 && (m.Content != "" || m.ReasoningContent != "") {
+			cp.firstToken = max(time.Since(cp.started).Milliseconds(), 1)
+		}
+
+		delta := response.ChatCompletionDelta{
+			Content:          m.Content,
+			ReasoningContent: m.ReasoningContent,
+			ToolCalls:        toCompletionToolCalls(m.ToolCalls),
+		}
+		if first {
+			delta.Role = "assistant"
+			first = false
+		}
+		cp.writeData(c, cp.chunk(delta, nil))
+	}
+
+	full, err := chat.ConcatMessages(chunks)
+	if err != nil {
+		full = &schema.Message{Role: schema.Assistant}
+	}
+	cp.save(full)
+
+	reason := finishReason(full)
+	cp.writeData(c, cp.chunk(response.ChatCompletionDelta{}, &reason))
+	if cp.req.StreamOptions != nil && cp.req.StreamOptions.IncludeUsage {
+		usage := cp.chunk(response.ChatCompletionDelta{}, nil)
+		usage.Choices = []response.ChatCompletionChunkChoice{}
+		usage.Usage = completionUsage(full)
+		cp.writeData(c, usage)
+	}
+	c.Writer.WriteString("data: [DONE]\n\n")
+	c.Writer.Flush()
+}
+
+func (cp *completion) chunk(delta response.ChatCompletionDelta, finishReason *string) response.ChatCompletionChunk {
+	return response.ChatCompletionChunk{
+		Id:      cp.id,
+		Object:  "chat.completion.chunk",
+		Created: cp.created,
+		Model:   cp.model.Name,
+		Choices: []response.ChatCompletionChunkChoice{{Delta: delta, FinishReason: finishReason}},
+	}
+}
+
+// writeData 以 OpenAI 的格式发送 SSE 数据,不带 event 名称
+func (cp *completion) writeData(c *gin.Context, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	c.Writer.WriteString("data: " + string(data) + "\n\n")
+	c.Writer.Flush()
+}
+
+// save 请求指定了会话时,保存最后一条用户消息和回复
+func (cp *completion) save(msg *schema.Message) {
+	if cp.req.Session == "" {
+		return
+	}
+	for i := len(cp.req.Messages) - 1; i >= 0; i-- {
+		if cp.req.Messages[i].Role != string(schema.User) {
+			continue
+		}
+		text, parts, _ := cp.req.Messages[i].Parts()
+		for _, p := range parts {
+			text += p.Text
+		}
+		chat.MessageAppend(cp.req.Session, &chat.Message{
+			Content:   text,
+			Role:      "user",
+			Session:   cp.req.Session,
+			RequestId: cp.id,
+			Model:     cp.model.Name,
+		})
+		break
+	}
+	reply := &chat.Message{
+		Content:           msg.Content,
+		ReasoningContent:  msg.ReasoningContent,
+		Role:              "assistant",
+		Session:           cp.req.Session,
+		RequestId:         cp.id,
+		Model:             cp.model.Name,
+		Status:            chat.MessageStatusCompleted,
+		FirstTokenLatency: cp.firstToken,
+		Latency:           max(time.Since(cp.started).Milliseconds(), cp.firstToken),
+	}
+	if msg.ResponseMeta != nil {
+		reply.AddUsage(msg.ResponseMeta.Usage)
+		reply.Tokens = reply.CompletionTokens
+	}
+	chat.MessageAppend(cp.req.Session, reply)
+}
+
+// toSchemaMessages 将 OpenAI 格式的消息转换为 eino 消息
+func toSchemaMessages(messages []request.ChatCompletionMessage) ([]*schema.Message, error) {
+	output := make([]*schema.Message, 0, len(messages))
+	for _, m := range messages {
+		switch schema.RoleType(m.Role) {
+		case schema.System, schema.User, schema.Assistant, schema.Tool:
+		case "developer":
+			m.Role = string(schema.System)
+		default:
+			return nil, fmt.Errorf("unsupported role: %s", m.Role)
+		}
+
+		text, parts, err := m.Parts()
+		if err != nil {
+			return nil, err
+		}
+		msg := &schema.Message{
+			Role:       schema.RoleType(m.Role),
+			Content:    text,
+			Name:       m.Name,
+			ToolCallID: m.ToolCallID,
+		}
+		for _, tc := range m.ToolCalls {
+			msg.ToolCalls = append(msg.ToolCalls, schema.ToolCall{
+				ID:       tc.Id,
+				Type:     "function",
+				Function: schema.FunctionCall{Name: tc.Function.Name, Arguments: tc.Function.Arguments},
+			})
+		}
+
+		hasImage := false
+		for _, p := range parts {
+			if p.Type == "image_url" {
+				hasImage = true
+			} else if p.Type != "text" {
+				return nil, fmt.Errorf("unsupported content type: %s", p.Type)
+			}
+		}
+		if hasImage && msg.Role == schema.User {
+			for _, p := range parts {
+				if p.Type == "text" {
+					msg.UserInputMultiContent = append(msg.UserInputMultiContent, schema.MessageInputPart{
+						Type: schema.ChatMessagePartTypeText,
+						Text: p.Text,
+					})
+					continue
+				}
+				if p.ImageURL == nil {
+					return nil, fmt.Errorf("image_url is required for image content")
+				}
+				url := p.ImageURL.URL
+				msg.UserInputMultiContent = append(msg.UserInputMultiContent, schema.MessageInputPart{
+					Type: schema.ChatMessagePartTypeImageURL,
+					Image: &schema.MessageInputImage{
+						MessagePartCommon: schema.MessagePartCommon{URL: &url},
+						Detail:            schema.ImageURLDetail(p.ImageURL.Detail),
+					},
+				})
+			}
+		} else if hasImage {
+			return nil, fmt.Errorf("image content is only supported in user messages")
+		} else {
+			for _, p := range parts {
+				msg.Content += p.Text
+			}
+		}
+		output = append(output, msg)
+	}
+	return output, nil
+}
+
+// toToolInfos 将请求中的函数定义转换为工具描述
+func toToolInfos(tools []request.ChatCompletionTool) ([]*schema.ToolInfo, error) {
+	output := make([]*schema.ToolInfo, 0, len(tools))
+	for _, t := range tools {
+		if t.Type != "" && t.Type != "function" {
+			return nil, fmt.Errorf("unsupported tool type: %s", t.Type)
+		}
+		info := &schema.ToolInfo{Name: t.Function.Name, Desc: t.Function.Description}
+		if len(t.Function.Parameters) > 0 {
+			params := &jsonschema.Schema{}
+			if err := json.Unmarshal(t.Function.Parameters, params); err != nil {
+				return nil, fmt.Errorf("invalid parameters of %s: %w", t.Function.Name, err)
+			}
+			info.ParamsOneOf = schema.NewParamsOneOfByJSONSchema(params)
+		}
+		output = append(output, info)
+	}
+	return output, nil
+}
+
+func toCompletionToolCalls(calls []schema.ToolCall) []response.ChatCompletionToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	output := make([]response.ChatCompletionToolCall, 0, len(calls))
+	for i, tc := range calls {
+		index := i
+		if tc.Index != nil {
+			index = *tc.Index
+		}
+		call := response.ChatCompletionToolCall{
+			Index:    &index,
+			Id:       tc.ID,
+			Function: response.ChatCompletionFunctionCall{Name: tc.Function.Name, Arguments: tc.Function.Arguments},
+		}
+		if tc.ID != "" {
+			call.Type = "function"
+		}
+		output = append(output, call)
+	}
+	return output
+}
+
+func finishReason(msg *schema.Message) string {
+	if len(msg.ToolCalls) > 0 {
+		return "tool_calls"
+	}
+	if msg.ResponseMeta != nil && msg.ResponseMeta.FinishReason != "" {
+		return msg.ResponseMeta.FinishReason
+	}
+	return "stop"
+}
+
+func completionUsage(msg *schema.Message) *response.CompletionUsage {
+	if msg.ResponseMeta == nil || msg.ResponseMeta.Usage == nil {
+		return nil
+	}
+	return &response.CompletionUsage{
+		PromptTokens:     msg.ResponseMeta.Usage.PromptTokens,
+		CompletionTokens: msg.ResponseMeta.Usage.CompletionTokens,
+		TotalTokens:      msg.ResponseMeta.Usage.TotalTokens,
+	}
+}
+
+func openAIError(c *gin.Context, status int, errType string, message string) {
+	c.JSON(status, response.OpenAIError{Error: response.OpenAIErrorBody{Message: message, Type: errType}})
+}
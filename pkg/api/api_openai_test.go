@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/AntNoHuabei/Remo/pkg/api/request"
+	"github.com/cloudwego/eino/schema"
+	"github.com/gin-gonic/gin"
+)
+
+func TestToSchemaMessages(t *testing.T) {
+	var req request.ChatCompletionRequest
+	body := `{
+		"model": "deepseek-chat",
+		"stop": "END",
+		"messages": [
+			{"role": "developer", "content": "be brief"},
+			{"role": "user", "content": [{"type": "text", "text": "what is "}, {"type": "text", "text": "this?"}]},
+			{"role": "assistant", "content": null, "tool_calls": [{"id": "call-1", "type": "function", "function": {"name": "look", "arguments": "{}"}}]},
+			{"role": "tool", "tool_call_id": "call-1", "content": "a cat"},
+			{"role": "user", "content": [{"type": "text", "text": "and this?"}, {"type": "image_url", "image_url": {"url": "data:image/png;base64,AAAA"}}]}
+		]
+	}`
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatalf("Failed to parse request: %v", err)
+	}
+	if len(req.Stop) != 1 || req.Stop[0] != "END" {
+		t.Errorf("Expected stop to accept a string, got %v", req.Stop)
+	}
+
+	messages, err := toSchemaMessages(req.Messages)
+	if err != nil {
+		t.Fatalf("toSchemaMessages failed: %v", err)
+	}
+	if messages[0].Role != schema.System {
+		t.Errorf("Expected developer role to map to system, got %s", messages[0].Role)
+	}
+	if messages[1].Content != "what is this?" {
+		t.Errorf("Expected text parts to be joined, got %q", messages[1].Content)
+	}
+	if len(messages[2].ToolCalls) != 1 || messages[2].ToolCalls[0].Function.Name != "look" {
+		t.Errorf("Unexpected tool calls: %+v", messages[2].ToolCalls)
+	}
+	if messages[3].ToolCallID != "call-1" || messages[3].Content != "a cat" {
+		t.Errorf("Unexpected tool message: %+v", messages[3])
+	}
+	if parts := messages[4].UserInputMultiContent; len(parts) != 2 || parts[1].Image == nil || *parts[1].Image.URL != "data:image/png;base64,AAAA" {
+		t.Errorf("Unexpected multimodal content: %+v", parts)
+	}
+
+	if _, err := toSchemaMessages([]request.ChatCompletionMessage{{Role: "robot"}}); err == nil {
+		t.Error("Expected error for unknown role")
+	}
+}
+
+func TestToToolInfos(t *testing.T) {
+	var tools []request.ChatCompletionTool
+	body := `[{"type": "function", "function": {"name": "weather", "description": "get weather",
+		"parameters": {"type": "object", "properties": {"city": {"type": "string"}}, "required": ["city"]}}}]`
+	if err := json.Unmarshal([]byte(body), &tools); err != nil {
+		t.Fatalf("Failed to parse tools: %v", err)
+	}
+
+	infos, err := toToolInfos(tools)
+	if err != nil {
+		t.Fatalf("toToolInfos failed: %v", err)
+	}
+	params, err := infos[0].ParamsOneOf.ToJSONSchema()
+	if err != nil {
+		t.Fatalf("ToJSONSchema failed: %v", err)
+	}
+	if _, ok := params.Properties.Get("city"); !ok || len(params.Required) != 1 {
+		t.Errorf("Unexpected parameters: %+v", params)
+	}
+}
+
+func TestGatewayAuth(t *testing.T) {
+	cfg, err := config.Init(filepath.Join(t.TempDir(), "app.json"))
+	if err != nil {
+		t.Fatalf("config.Init failed: %v", err)
+	}
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/v1/models", GatewayAuth(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	get := func(token string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		engine.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// 默认关闭
+	if code := get(""); code != http.StatusNotFound {
+		t.Errorf("Expected the gateway to be disabled by default, got %d", code)
+	}
+	// 开启但未设置 API Key 时拒绝请求
+	cfg.Update(func(c *config.Config) { c.Gateway.Enabled = true })
+	if code := get(""); code != http.StatusForbidden {
+		t.Errorf("Expected requests to be refused without an api key, got %d", code)
+	}
+	cfg.Update(func(c *config.Config) { c.Gateway.APIKey = "sk-test" })
+	if code := get("wrong"); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a wrong key, got %d", code)
+	}
+	if code := get("sk-test"); code != http.StatusOK {
+		t.Errorf("Expected 200 for the right key, got %d", code)
+	}
+}
@@ -3,7 +3,10 @@ package api
 import (
 	"github.com/AntNoHuabei/Remo/pkg/api/request"
 	"github.com/AntNoHuabei/Remo/pkg/chat"
+	"github.com/AntNoHuabei/Remo/pkg/provider"
 	"github.com/gin-gonic/gin"
+	"io"
+	"mime"
 	"net/http"
 )
 
@@ -30,12 +33,17 @@ func SessionList(c *gin.Context) {
 			req.Size = 10
 		}
 
-		sessions, err := chat.SessionList((req.Page-1)*req.Size, req.Size)
+		page, err := chat.SessionList(chat.SessionFilter{
+			Query:    req.Query,
+			Archived: req.Archived,
+			Offset:   (req.Page - 1) * req.Size,
+			Limit:    req.Size,
+		})
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, Fail(err.Error()))
 		} else {
-			c.JSON(http.StatusOK, Success(sessions))
+			c.JSON(http.StatusOK, Success(page))
 		}
 
 	}
@@ -60,6 +68,123 @@ func SessionDelete(c *gin.Context) {
 	}
 }
 
+// SessionTrash 返回回收站中的会话
+func SessionTrash(c *gin.Context) {
+
+	sessions, err := chat.TrashList()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Fail(err.Error()))
+	} else {
+		c.JSON(http.StatusOK, Success(sessions))
+	}
+}
+
+// SessionRestore 从回收站恢复会话
+func SessionRestore(c *gin.Context) {
+
+	var req request.SessionDeleteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+		return
+	}
+
+	if err := chat.RestoreSession(req.Id); err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+	} else {
+		c.JSON(http.StatusOK, Success(nil))
+	}
+}
+
+// SessionPurge 彻底删除会话及其消息和断点
+func SessionPurge(c *gin.Context) {
+
+	var req request.SessionDeleteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+		return
+	}
+
+	if err := chat.PurgeSession(req.Id); err != nil {
+		c.JSON(http.StatusInternalServerError, Fail(err.Error()))
+	} else {
+		c.JSON(http.StatusOK, Success(nil))
+	}
+}
+
+func SessionUpdate(c *gin.Context) {
+
+	var req request.SessionUpdateRequest
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+		return
+	}
+
+	// 只更新请求中的字段,避免覆盖对话期间保存的当前分支和更新时间
+	fields := make(map[string]any)
+	if req.Model != nil {
+		if *req.Model != "" {
+			if _, err := provider.Get().Resolve(*req.Model); err != nil {
+				c.JSON(http.StatusBadRequest, Fail(err.Error()))
+				return
+			}
+		}
+		fields["model"] = *req.Model
+	}
+	if req.Instruction != nil {
+		fields["instruction"] = *req.Instruction
+	}
+	if req.Options != nil {
+		fields["options"] = *req.Options
+	}
+	if req.Pinned != nil {
+		fields["pinned"] = *req.Pinned
+	}
+	if req.Archived != nil {
+		fields["archived"] = *req.Archived
+	}
+
+	session, err := chat.UpdateSession(req.Id, fields)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+	} else {
+		c.JSON(http.StatusOK, Success(session))
+	}
+}
+
+// SessionRename 修改会话标题,修改后不会再自动生成标题
+func SessionRename(c *gin.Context) {
+
+	var req request.SessionRenameRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+		return
+	}
+
+	session, err := chat.RenameSession(req.Id, req.Title)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+	} else {
+		c.JSON(http.StatusOK, Success(session))
+	}
+}
+
+// SessionMessagesResponse 会话当前分支的消息,SummaryBoundary 为最近一次摘要覆盖到的消息 id,
+// 该消息及之前的消息不再发送给模型。Branches 为有多个版本的消息及其全部版本的 id
+type SessionMessagesResponse struct {
+	Messages        []*chat.Message     `json:"messages"`
+	SummaryBoundary string              `json:"summary_boundary"`
+	Branches        map[string][]string `json:"branches"`
+}
+
+func newSessionMessagesResponse(thread *chat.Thread) SessionMessagesResponse {
+	return SessionMessagesResponse{
+		Messages:        thread.Messages,
+		SummaryBoundary: chat.SummaryBoundary(thread.Messages),
+		Branches:        thread.Branches,
+	}
+}
+
 func SessionMessages(c *gin.Context) {
 
 	var req request.SessionMessagesRequest
@@ -68,11 +193,57 @@ func SessionMessages(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, Fail(err.Error()))
 		return
 	} else {
-		messages, err := chat.Messages(req.Session)
+		thread, err := chat.CurrentThread(req.Session)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, Fail(err.Error()))
 		} else {
-			c.JSON(http.StatusOK, Success(messages))
+			c.JSON(http.StatusOK, Success(newSessionMessagesResponse(thread)))
 		}
 	}
 }
+
+// SessionExport 导出会话为 Markdown、HTML 或可再导入的 json,响应为下载的文件
+func SessionExport(c *gin.Context) {
+
+	var req request.SessionExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+		return
+	}
+
+	file, err := chat.Export(req.Ids, req.Format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+		return
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Name}))
+	c.Data(http.StatusOK, file.ContentType, file.Data)
+}
+
+// SessionImport 导入 multipart 表单 file 字段中的文件,支持导出的 json 和 ChatGPT 的 conversations.json
+func SessionImport(c *gin.Context) {
+
+	fh, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+		return
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+		return
+	}
+
+	result, err := chat.Import(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+	} else {
+		c.JSON(http.StatusOK, Success(result))
+	}
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/AntNoHuabei/Remo/pkg/api/request"
+	"github.com/AntNoHuabei/Remo/pkg/usage"
+	"github.com/gin-gonic/gin"
+)
+
+// UsageSummary 按日期、模型或会话统计模型调用的 token 用量和费用
+func UsageSummary(c *gin.Context) {
+
+	var req request.UsageSummaryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+		return
+	}
+
+	summary, err := usage.Get().Summary(usage.Query{
+		GroupBy: req.GroupBy,
+		From:    req.From,
+		To:      req.To,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+	} else {
+		c.JSON(http.StatusOK, Success(summary))
+	}
+}
+
+// UsageBudget 返回已超出的预算,未超出时为 null
+func UsageBudget(c *gin.Context) {
+
+	budget, err := usage.Get().CheckBudget()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Fail(err.Error()))
+	} else {
+		c.JSON(http.StatusOK, Success(budget))
+	}
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/AntNoHuabei/Remo/pkg/api/request"
+	"github.com/AntNoHuabei/Remo/pkg/persist"
+	"github.com/gin-gonic/gin"
+)
+
+// StorageGuard 存储已加密且没有解锁时拒绝 /vault 以外的请求
+func StorageGuard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if !persist.Locked() || strings.HasPrefix(path, "/vault/") {
+			c.Next()
+			return
+		}
+		if strings.HasPrefix(path, "/v1/") {
+			openAIError(c, http.StatusLocked, "api_error", persist.ErrLocked.Error())
+		} else {
+			c.JSON(http.StatusLocked, Fail(persist.ErrLocked.Error()))
+		}
+		c.Abort()
+	}
+}
+
+// vaultError 口令错误返回 403,其他错误返回 400
+func vaultError(c *gin.Context, err error) {
+	if errors.Is(err, persist.ErrWrongPassphrase) {
+		c.JSON(http.StatusForbidden, Fail(err.Error()))
+	} else {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+	}
+}
+
+// VaultStatus 返回是否启用了加密以及是否已解锁
+func VaultStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, Success(persist.GetEncryptionStatus()))
+}
+
+// VaultUnlock 使用口令解锁存储
+func VaultUnlock(c *gin.Context) {
+
+	var req request.VaultUnlockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+		return
+	}
+	if err := persist.Unlock(req.Passphrase); err != nil {
+		vaultError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, Success(persist.GetEncryptionStatus()))
+}
+
+// VaultLock 从内存中清除数据密钥,之后的请求需要先解锁
+func VaultLock(c *gin.Context) {
+	if err := persist.Lock(); err != nil {
+		vaultError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, Success(persist.GetEncryptionStatus()))
+}
+
+// VaultEnable 启用加密并加密已有的会话和 API Key
+func VaultEnable(c *gin.Context) {
+
+	var req request.VaultEnableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+		return
+	}
+	if err := persist.EnableEncryption(persist.KeyOptions{Source: req.Source, Passphrase: req.Passphrase}); err != nil {
+		vaultError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, Success(persist.GetEncryptionStatus()))
+}
+
+// VaultRotate 生成新的数据密钥并重新加密全部数据
+func VaultRotate(c *gin.Context) {
+
+	var req request.VaultRotateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+		return
+	}
+	if err := persist.RotateKey(req.Passphrase, persist.KeyOptions{Source: req.Source, Passphrase: req.NewPassphrase}); err != nil {
+		vaultError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, Success(persist.GetEncryptionStatus()))
+}
+
+// VaultDisable 解密全部数据并关闭加密
+func VaultDisable(c *gin.Context) {
+
+	var req request.VaultDisableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+		return
+	}
+	if err := persist.DisableEncryption(req.Passphrase); err != nil {
+		vaultError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, Success(persist.GetEncryptionStatus()))
+}
+
+// SecretList 返回已保存的 API Key 的名称,不返回值
+func SecretList(c *gin.Context) {
+
+	names, err := persist.SecretNames()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Fail(err.Error()))
+	} else {
+		c.JSON(http.StatusOK, Success(names))
+	}
+}
+
+// SecretSet 保存或删除 API Key
+func SecretSet(c *gin.Context) {
+
+	var req request.SecretSetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Fail(err.Error()))
+		return
+	}
+	if req.Name != persist.GatewaySecret && !strings.HasPrefix(req.Name, persist.ProviderSecret("")) {
+		c.JSON(http.StatusBadRequest, Fail("invalid secret name: "+req.Name))
+		return
+	}
+	if err := persist.SetSecret(req.Name, req.Value); err != nil {
+		c.JSON(http.StatusInternalServerError, Fail(err.Error()))
+	} else {
+		c.JSON(http.StatusOK, Success(nil))
+	}
+}
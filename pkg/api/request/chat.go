@@ -1,7 +1,31 @@
 package request
 
 type ChatRequest struct {
-	Message   string `json:"message"`
-	Session   string `json:"session"`
+	Message     string       `json:"message"`
+	Session     string       `json:"session"`
+	RequestId   string       `json:"request_id"`
+	Model       string       `json:"model"` // 使用的模型,为空则使用默认模型
+	Attachments []Attachment `json:"attachments"`
+}
+
+// Attachment 消息的附件,内容由前端读取后以 base64 发送,服务端不读取本地路径
+type Attachment struct {
+	Kind     string `json:"kind"` // image, screenshot, file
+	Name     string `json:"name"`
+	MimeType string `json:"mime_type"` // 为空时根据文件名和内容判断
+	Data     string `json:"data"`      // base64 或 data URL
+}
+
+type ChatAbortRequest struct {
 	RequestId string `json:"request_id"`
 }
+
+// ChatConfirmRequest 对等待确认的工具调用作出决定
+type ChatConfirmRequest struct {
+	Session     string `json:"session"`
+	RequestId   string `json:"request_id"`
+	InterruptId string `json:"interrupt_id"`
+	Approved    bool   `json:"approved"`
+	Reason      string `json:"reason"`
+	Model       string `json:"model"`
+}
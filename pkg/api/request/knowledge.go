@@ -0,0 +1,11 @@
+package request
+
+type KnowledgeDeleteRequest struct {
+	Id string `json:"id"`
+}
+
+// KnowledgeSearchRequest 检索知识库,TopK 为 0 时使用配置的数量
+type KnowledgeSearchRequest struct {
+	Query string `json:"query"`
+	TopK  int    `json:"top_k"`
+}
@@ -0,0 +1,11 @@
+package request
+
+type MemoryDeleteRequest struct {
+	Id string `json:"id"`
+}
+
+// MemoryEditRequest 修改记忆内容
+type MemoryEditRequest struct {
+	Id      string `json:"id"`
+	Content string `json:"content"`
+}
@@ -0,0 +1,24 @@
+package request
+
+// MessageEditRequest 修改用户消息并从该消息重新生成回复
+type MessageEditRequest struct {
+	Session   string `json:"session"`
+	Id        string `json:"id"`
+	Content   string `json:"content"`
+	RequestId string `json:"request_id"`
+	Model     string `json:"model"`
+}
+
+// MessageRegenerateRequest 为同一条用户消息重新生成回复,Id 为助手消息或用户消息
+type MessageRegenerateRequest struct {
+	Session   string `json:"session"`
+	Id        string `json:"id"`
+	RequestId string `json:"request_id"`
+	Model     string `json:"model"`
+}
+
+// MessageSwitchRequest 切换到包含指定消息的分支
+type MessageSwitchRequest struct {
+	Session string `json:"session"`
+	Id      string `json:"id"`
+}
@@ -0,0 +1,108 @@
+package request
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ChatCompletionRequest OpenAI 兼容的 /v1/chat/completions 请求
+type ChatCompletionRequest struct {
+	Model               string                  `json:"model"`
+	Messages            []ChatCompletionMessage `json:"messages"`
+	Stream              bool                    `json:"stream"`
+	StreamOptions       *StreamOptions          `json:"stream_options"`
+	Temperature         *float32                `json:"temperature"`
+	TopP                *float32                `json:"top_p"`
+	MaxTokens           *int                    `json:"max_tokens"`
+	MaxCompletionTokens *int                    `json:"max_completion_tokens"`
+	Stop                StringOrArray           `json:"stop"`
+	Tools               []ChatCompletionTool    `json:"tools"`
+	// Session 扩展字段,不为空时将最后一条用户消息和回复保存到该会话
+	Session string `json:"session"`
+}
+
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// ChatCompletionMessage 对话消息,content 可以是字符串或内容片段数组
+type ChatCompletionMessage struct {
+	Role       string                   `json:"role"`
+	Content    json.RawMessage          `json:"content"`
+	Name       string                   `json:"name"`
+	ToolCalls  []ChatCompletionToolCall `json:"tool_calls"`
+	ToolCallID string                   `json:"tool_call_id"`
+}
+
+// ContentPart 内容片段
+type ContentPart struct {
+	Type     string `json:"type"` // text, image_url
+	Text     string `json:"text"`
+	ImageURL *struct {
+		URL    string `json:"url"`
+		Detail string `json:"detail"`
+	} `json:"image_url"`
+}
+
+// Parts 解析消息内容,字符串内容返回 text 为该字符串、parts 为空
+func (m ChatCompletionMessage) Parts() (string, []ContentPart, error) {
+	raw := strings.TrimSpace(string(m.Content))
+	if raw == "" || raw == "null" {
+		return "", nil, nil
+	}
+	if strings.HasPrefix(raw, "\"") {
+		var text string
+		if err := json.Unmarshal(m.Content, &text); err != nil {
+			return "", nil, err
+		}
+		return text, nil, nil
+	}
+	var parts []ContentPart
+	if err := json.Unmarshal(m.Content, &parts); err != nil {
+		return "", nil, fmt.Errorf("invalid content of %s message: %w", m.Role, err)
+	}
+	return "", parts, nil
+}
+
+type ChatCompletionToolCall struct {
+	Index    *int   `json:"index,omitempty"`
+	Id       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type ChatCompletionTool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Parameters  json.RawMessage `json:"parameters"`
+	} `json:"function"`
+}
+
+// EmbeddingRequest OpenAI 兼容的 /v1/embeddings 请求
+type EmbeddingRequest struct {
+	Model string        `json:"model"`
+	Input StringOrArray `json:"input"`
+}
+
+// StringOrArray 可以是字符串或字符串数组的字段
+type StringOrArray []string
+
+func (s *StringOrArray) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*s = []string{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*s = many
+	return nil
+}
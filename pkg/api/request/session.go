@@ -1,8 +1,12 @@
 package request
 
+import "github.com/AntNoHuabei/Remo/pkg/provider"
+
 type SessionListRequest struct {
-	Size int `json:"size"`
-	Page int `json:"page"`
+	Size     int    `json:"size"`
+	Page     int    `json:"page"`
+	Query    string `json:"query"`    // 搜索标题和消息内容
+	Archived bool   `json:"archived"` // 为 true 时只列出已归档的会话
 }
 
 type SessionDeleteRequest struct {
@@ -11,3 +15,25 @@ type SessionDeleteRequest struct {
 type SessionMessagesRequest struct {
 	Session string `json:"session"`
 }
+
+// SessionUpdateRequest 更新会话设置,为空的字段保持不变
+type SessionUpdateRequest struct {
+	Id          string                    `json:"id"`
+	Model       *string                   `json:"model"`
+	Instruction *string                   `json:"instruction"`
+	Options     *provider.GenerateOptions `json:"options"`
+	Pinned      *bool                     `json:"pinned"`
+	Archived    *bool                     `json:"archived"`
+}
+
+// SessionRenameRequest 修改会话标题
+type SessionRenameRequest struct {
+	Id    string `json:"id"`
+	Title string `json:"title"`
+}
+
+// SessionExportRequest 导出会话,Ids 为空时导出回收站以外的全部会话
+type SessionExportRequest struct {
+	Ids    []string `json:"ids"`
+	Format string   `json:"format"` // markdown, html, json
+}
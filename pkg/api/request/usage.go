@@ -0,0 +1,8 @@
+package request
+
+// UsageSummaryRequest 用量统计条件
+type UsageSummaryRequest struct {
+	GroupBy string `json:"group_by"` // day, model, session,默认 day
+	From    int64  `json:"from"`     // 毫秒时间戳,0 表示不限制
+	To      int64  `json:"to"`       // 毫秒时间戳(不含),0 表示不限制
+}
@@ -0,0 +1,30 @@
+package request
+
+// VaultUnlockRequest 解锁加密的存储,密钥保存在系统钥匙串中时口令留空
+type VaultUnlockRequest struct {
+	Passphrase string `json:"passphrase"`
+}
+
+// VaultEnableRequest 启用加密
+type VaultEnableRequest struct {
+	Source     string `json:"source"`     // passphrase 或 keyring
+	Passphrase string `json:"passphrase"` // source 为 passphrase 时的口令
+}
+
+// VaultRotateRequest 轮换数据密钥,可以同时更换口令或密钥来源
+type VaultRotateRequest struct {
+	Passphrase    string `json:"passphrase"` // 当前的口令,密钥保存在系统钥匙串中时留空
+	Source        string `json:"source"`
+	NewPassphrase string `json:"new_passphrase"` // source 为 passphrase 时的新口令
+}
+
+// VaultDisableRequest 关闭加密
+type VaultDisableRequest struct {
+	Passphrase string `json:"passphrase"` // 当前的口令,密钥保存在系统钥匙串中时留空
+}
+
+// SecretSetRequest 保存 API Key,Value 为空时删除
+type SecretSetRequest struct {
+	Name  string `json:"name"` // gateway 或 provider:<供应商名称>
+	Value string `json:"value"`
+}
@@ -1,9 +1,127 @@
 package response
 
+// 流式响应的事件类型,同时作为 SSE 的 event 名称
+const (
+	EventStart            = "start"
+	EventSummary          = "summary" // 较早的历史被压缩为摘要,在开始后发送
+	EventCitation         = "citation"
+	EventReasoningDelta   = "reasoning_delta"
+	EventContentDelta     = "content_delta"
+	EventToolCall         = "tool_call"
+	EventToolResult       = "tool_result"
+	EventToolConfirmation = "tool_confirmation"
+	EventUsage            = "usage"
+	EventBudget           = "budget" // 已超出预算,配置为提醒时在开始后发送
+	EventError            = "error"
+	EventDone             = "done"
+)
+
+// 错误码
+const (
+	ErrorInvalidRequest   = "invalid_request"   // 请求参数错误,如会话或模型不存在
+	ErrorGenerationFailed = "generation_failed" // 模型或工具调用失败
+	ErrorInternal         = "internal_error"
+	ErrorBudgetExceeded   = "budget_exceeded" // 已超出预算且配置为拒绝对话
+	ErrorNotMultimodal    = "not_multimodal"  // 消息带有附件但模型不支持多模态输入
+)
+
+// ChatResponse 流式响应中的一个事件,Event 决定哪个字段有值
 type ChatResponse struct {
-	Content       string `json:"content"`
-	ReasonContent string `json:"reason_content"`
-	IndexOfDelta  int    `json:"index_of_delta"`
-	RequestID     string `json:"request_id"`
-	Error         error  `json:"error,omitempty"`
+	Event     string `json:"event"`
+	Index     int    `json:"index"` // 同一请求内从 0 开始单调递增,同时作为 SSE 的 id
+	RequestID string `json:"request_id"`
+
+	Content       string `json:"content,omitempty"`        // content_delta
+	ReasonContent string `json:"reason_content,omitempty"` // reasoning_delta
+
+	Start            *Start            `json:"start,omitempty"`
+	Summary          *Summary          `json:"summary,omitempty"`
+	Citations        []Citation        `json:"citations,omitempty"`
+	ToolConfirmation *ToolConfirmation `json:"tool_confirmation,omitempty"`
+	ToolCall         *ToolCall         `json:"tool_call,omitempty"`
+	ToolResult       *ToolResult       `json:"tool_result,omitempty"`
+	Usage            *Usage            `json:"usage,omitempty"`
+	Budget           *Budget           `json:"budget,omitempty"`
+	Error            *Error            `json:"error,omitempty"`
+	Done             *Done             `json:"done,omitempty"`
+}
+
+// Start 生成开始
+type Start struct {
+	Session string `json:"session"`
+	Model   string `json:"model"`
+}
+
+// Summary 本轮生成前保存的历史摘要
+type Summary struct {
+	Id      string `json:"id"`
+	Until   string `json:"until"` // 摘要覆盖到的最后一条消息
+	Content string `json:"content"`
+}
+
+// Citation 注入到本轮对话的知识库片段,Index 对应回复中的 [n] 引用标记
+type Citation struct {
+	Index    int     `json:"index"`
+	Document string  `json:"document"` // 文件 id
+	Name     string  `json:"name"`     // 文件名
+	Chunk    int     `json:"chunk"`    // 片段在文件中的序号
+	Content  string  `json:"content"`
+	Score    float64 `json:"score"`
+}
+
+// ToolCall 模型发起的工具调用
+type ToolCall struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ToolResult 工具执行结果
+type ToolResult struct {
+	Id      string `json:"id"` // 对应 ToolCall.Id
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+// ToolConfirmation 等待用户确认的工具调用
+type ToolConfirmation struct {
+	InterruptId string `json:"interrupt_id"` // 确认时需要回传
+	Tool        string `json:"tool"`
+	Arguments   string `json:"arguments"`
+}
+
+// Usage 本次生成消耗的 token,多轮工具调用时为累计值
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	ReasoningTokens  int `json:"reasoning_tokens"` // 包含在 CompletionTokens 中
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Budget 已超出的预算
+type Budget struct {
+	Period   string  `json:"period"` // daily, monthly
+	Limit    float64 `json:"limit"`
+	Spent    float64 `json:"spent"`
+	Currency string  `json:"currency"`
+}
+
+// Error 错误信息
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Done 生成结束,总是最后一个事件
+type Done struct {
+	Status string `json:"status"` // completed, aborted, interrupted, failed
+}
+
+// NewError 创建错误事件
+func NewError(requestId string, code string, err error) ChatResponse {
+	return ChatResponse{
+		Event:     EventError,
+		RequestID: requestId,
+		Error:     &Error{Code: code, Message: err.Error()},
+	}
 }
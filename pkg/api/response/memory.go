@@ -0,0 +1,10 @@
+package response
+
+// Memory 长期记忆,不包含向量
+type Memory struct {
+	Id          string `json:"id"`
+	Content     string `json:"content"`
+	Session     string `json:"session"` // 提取记忆的会话
+	CreatedTime int64  `json:"created_time"`
+	UpdatedTime int64  `json:"updated_time"`
+}
@@ -0,0 +1,103 @@
+package response
+
+// ChatCompletion OpenAI 兼容的非流式响应
+type ChatCompletion struct {
+	Id      string                 `json:"id"`
+	Object  string                 `json:"object"` // chat.completion
+	Created int64                  `json:"created"`
+	Model   string                 `json:"model"`
+	Choices []ChatCompletionChoice `json:"choices"`
+	Usage   *CompletionUsage       `json:"usage,omitempty"`
+}
+
+type ChatCompletionChoice struct {
+	Index        int                   `json:"index"`
+	Message      ChatCompletionMessage `json:"message"`
+	FinishReason string                `json:"finish_reason"`
+}
+
+type ChatCompletionMessage struct {
+	Role             string                   `json:"role"`
+	Content          string                   `json:"content"`
+	ReasoningContent string                   `json:"reasoning_content,omitempty"`
+	ToolCalls        []ChatCompletionToolCall `json:"tool_calls,omitempty"`
+}
+
+type ChatCompletionToolCall struct {
+	Index    *int                       `json:"index,omitempty"`
+	Id       string                     `json:"id,omitempty"`
+	Type     string                     `json:"type,omitempty"`
+	Function ChatCompletionFunctionCall `json:"function"`
+}
+
+type ChatCompletionFunctionCall struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
+}
+
+// ChatCompletionChunk OpenAI 兼容的流式响应片段
+type ChatCompletionChunk struct {
+	Id      string                      `json:"id"`
+	Object  string                      `json:"object"` // chat.completion.chunk
+	Created int64                       `json:"created"`
+	Model   string                      `json:"model"`
+	Choices []ChatCompletionChunkChoice `json:"choices"`
+	Usage   *CompletionUsage            `json:"usage,omitempty"`
+}
+
+type ChatCompletionChunkChoice struct {
+	Index        int                 `json:"index"`
+	Delta        ChatCompletionDelta `json:"delta"`
+	FinishReason *string             `json:"finish_reason"`
+}
+
+type ChatCompletionDelta struct {
+	Role             string                   `json:"role,omitempty"`
+	Content          string                   `json:"content,omitempty"`
+	ReasoningContent string                   `json:"reasoning_content,omitempty"`
+	ToolCalls        []ChatCompletionToolCall `json:"tool_calls,omitempty"`
+}
+
+type CompletionUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// ModelList OpenAI 兼容的模型列表
+type ModelList struct {
+	Object string        `json:"object"` // list
+	Data   []ModelObject `json:"data"`
+}
+
+type ModelObject struct {
+	Id      string `json:"id"`
+	Object  string `json:"object"` // model
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// EmbeddingList OpenAI 兼容的向量响应
+type EmbeddingList struct {
+	Object string            `json:"object"` // list
+	Data   []EmbeddingObject `json:"data"`
+	Model  string            `json:"model"`
+	Usage  CompletionUsage   `json:"usage"`
+}
+
+type EmbeddingObject struct {
+	Object    string    `json:"object"` // embedding
+	Index     int       `json:"index"`
+	Embedding []float64 `json:"embedding"`
+}
+
+// OpenAIError OpenAI 兼容的错误响应
+type OpenAIError struct {
+	Error OpenAIErrorBody `json:"error"`
+}
+
+type OpenAIErrorBody struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Code    string `json:"code,omitempty"`
+}
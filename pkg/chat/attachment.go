@@ -0,0 +1,201 @@
+package chat
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/google/uuid"
+)
+
+// 附件类型
+const (
+	AttachmentImage      = "image"      // 图片
+	AttachmentScreenshot = "screenshot" // 屏幕截图,按图片发送给模型
+	AttachmentFile       = "file"       // 文件,图片按图片发送,文本文件按文本发送
+)
+
+const (
+	// MaxAttachmentSize 单个附件的最大字节数
+	MaxAttachmentSize = 20 << 20
+	// imageTokens 估算上下文时每张图片占用的 token 数
+	imageTokens = 1024
+)
+
+// ErrNotMultimodal 消息带有附件但模型不支持多模态输入
+var ErrNotMultimodal = errors.New("model does not support attachments")
+
+// Attachment 消息的附件,消息中只保存元数据,内容单独保存
+type Attachment struct {
+	Id       string `json:"id"`
+	Kind     string `json:"kind"` // image, screenshot, file
+	Name     string `json:"name"`
+	MimeType string `json:"mime_type"`
+	Size     int    `json:"size"`
+	// Data 附件内容,保存消息时写入附件集合,不随消息保存
+	Data []byte `json:"-"`
+}
+
+// NewAttachment 创建附件,mimeType 为空时根据文件名和内容判断
+func NewAttachment(kind string, name string, mimeType string, data []byte) (*Attachment, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("attachment %q is empty", name)
+	}
+	if len(data) > MaxAttachmentSize {
+		return nil, fmt.Errorf("attachment %q is larger than %d MB", name, MaxAttachmentSize>>20)
+	}
+	if mimeType == "" {
+		mimeType = mime.TypeByExtension(filepath.Ext(name))
+	}
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+
+	a := &Attachment{
+		Id:       uuid.New().String(),
+		Kind:     kind,
+		Name:     name,
+		MimeType: strings.TrimSpace(mimeType),
+		Size:     len(data),
+		Data:     data,
+	}
+	switch kind {
+	case AttachmentImage, AttachmentScreenshot:
+		if !a.isImage() {
+			return nil, fmt.Errorf("attachment %q is not an image: %s", name, a.MimeType)
+		}
+	case AttachmentFile:
+		if !a.isImage() && !a.isText() {
+			return nil, fmt.Errorf("unsupported attachment %q: %s, only images and text files are supported", name, a.MimeType)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported attachment kind: %s", kind)
+	}
+	if a.Name == "" {
+		a.Name = kind
+	}
+	return a, nil
+}
+
+// DecodeAttachment 从 base64 或 data URL 创建附件
+func DecodeAttachment(kind string, name string, mimeType string, data string) (*Attachment, error) {
+	if rest, ok := strings.CutPrefix(data, "data:"); ok {
+		header, payload, found := strings.Cut(rest, ",")
+		if !found || !strings.HasSuffix(header, ";base64") {
+			return nil, fmt.Errorf("attachment %q is not a base64 data URL", name)
+		}
+		if mimeType == "" {
+			mimeType = strings.TrimSuffix(header, ";base64")
+		}
+		data = payload
+	}
+	b, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 data of attachment %q: %w", name, err)
+	}
+	return NewAttachment(kind, name, mimeType, b)
+}
+
+// GetAttachment 返回附件及其内容
+func GetAttachment(id string) (*Attachment, error) {
+	a, err := repo().GetAttachment(id)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil {
+		return nil, fmt.Errorf("attachment not found: %s", id)
+	}
+	return a, nil
+}
+
+func (a *Attachment) isImage() bool {
+	return strings.HasPrefix(a.MimeType, "image/")
+}
+
+func (a *Attachment) isText() bool {
+	if strings.HasPrefix(a.MimeType, "text/") {
+		return true
+	}
+	switch a.MimeType {
+	case "application/json", "application/xml", "application/javascript", "application/x-yaml", "application/yaml", "application/toml":
+		return true
+	}
+	return false
+}
+
+// tokens 估算附件占用的 token 数
+func (a *Attachment) tokens() int {
+	if a.isImage() {
+		return imageTokens
+	}
+	return EstimateTokens(string(a.Data))
+}
+
+// saveAttachments 保存消息中新上传的附件内容,已保存的附件不会重复写入
+func saveAttachments(message *Message) error {
+	for _, a := range message.Attachments {
+		if a.Data == nil {
+			continue
+		}
+		if err := repo().InsertAttachment(message.Session, message.Id, a); err != nil {
+			return err
+		}
+		a.Data = nil
+	}
+	return nil
+}
+
+// inputParts 将用户消息和附件转换为多模态输入
+func inputParts(m *Message) ([]schema.MessageInputPart, error) {
+	parts := make([]schema.MessageInputPart, 0, len(m.Attachments)+1)
+	if m.Content != "" {
+		parts = append(parts, schema.MessageInputPart{Type: schema.ChatMessagePartTypeText, Text: m.Content})
+	}
+	for _, a := range m.Attachments {
+		data := a.Data
+		if data == nil {
+			stored, err := GetAttachment(a.Id)
+			if err != nil {
+				return nil, err
+			}
+			data = stored.Data
+		}
+
+		if a.isImage() {
+			encoded := base64.StdEncoding.EncodeToString(data)
+			parts = append(parts, schema.MessageInputPart{
+				Type: schema.ChatMessagePartTypeImageURL,
+				Image: &schema.MessageInputImage{
+					MessagePartCommon: schema.MessagePartCommon{Base64Data: &encoded, MIMEType: a.MimeType},
+					Detail:            schema.ImageURLDetailAuto,
+				},
+			})
+			continue
+		}
+		text := string(data)
+		if !utf8.ValidString(text) {
+			text = strings.ToValidUTF8(text, "\uFFFD")
+		}
+		parts = append(parts, schema.MessageInputPart{
+			Type: schema.ChatMessagePartTypeText,
+			Text: fmt.Sprintf("File %s:\n```\n%s\n```", a.Name, text),
+		})
+	}
+	return parts, nil
+}
+
+// attachmentNotes 不发送附件内容时用于说明消息带有的附件,如摘要和不支持多模态的模型
+func attachmentNotes(m *Message) string {
+	notes := make([]string, 0, len(m.Attachments))
+	for _, a := range m.Attachments {
+		notes = append(notes, fmt.Sprintf("[%s: %s]", a.Kind, a.Name))
+	}
+	return strings.Join(notes, " ")
+}
@@ -0,0 +1,128 @@
+package chat
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/AntNoHuabei/Remo/pkg/persist"
+)
+
+// pngHeader 足以被识别为 PNG 的文件头
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestNewAttachment(t *testing.T) {
+	a, err := DecodeAttachment(AttachmentScreenshot, "", "", "data:image/png;base64,"+base64.StdEncoding.EncodeToString(pngHeader))
+	if err != nil {
+		t.Fatalf("DecodeAttachment failed: %v", err)
+	}
+	if a.MimeType != "image/png" || a.Name != AttachmentScreenshot || a.Size != len(pngHeader) {
+		t.Errorf("Unexpected attachment %+v", a)
+	}
+
+	a, err = NewAttachment(AttachmentFile, "notes.txt", "", []byte("hello"))
+	if err != nil || !a.isText() {
+		t.Errorf("Expected a text file, got %+v %v", a, err)
+	}
+	if a, err = NewAttachment(AttachmentFile, "", "", pngHeader); err != nil || !a.isImage() {
+		t.Errorf("Expected the mime type to be detected from the content, got %+v %v", a, err)
+	}
+
+	invalid := []struct {
+		kind, name, mime string
+		data             []byte
+	}{
+		{AttachmentImage, "a.txt", "", []byte("not an image")},
+		{AttachmentFile, "a.zip", "application/zip", []byte("PK")},
+		{"audio", "a.wav", "", []byte("RIFF")},
+		{AttachmentImage, "a.png", "", nil},
+	}
+	for _, c := range invalid {
+		if _, err := NewAttachment(c.kind, c.name, c.mime, c.data); err == nil {
+			t.Errorf("Expected %s %q to be rejected", c.kind, c.name)
+		}
+	}
+	if _, err := DecodeAttachment(AttachmentImage, "a.png", "", "data:image/png,raw"); err == nil {
+		t.Error("Expected data URLs without base64 to be rejected")
+	}
+}
+
+func TestChatWithAttachments(t *testing.T) {
+	openTestDB(t)
+	cm := echoModel()
+	agent := newTestAgent(t, cm, "", nil)
+
+	image, _ := NewAttachment(AttachmentImage, "cat.png", "", pngHeader)
+	file, _ := NewAttachment(AttachmentFile, "notes.txt", "", []byte("buy milk"))
+	message := &Message{Role: "user", Content: "look", Session: agent.session, Attachments: []*Attachment{image, file}}
+
+	// 不支持多模态的模型拒绝附件,不保存消息
+	if _, err := agent.Chat(context.Background(), message); !errors.Is(err, ErrNotMultimodal) {
+		t.Fatalf("Expected ErrNotMultimodal, got %v", err)
+	}
+	if messages, _ := Messages(agent.session); len(messages) != 0 {
+		t.Errorf("Expected no messages to be saved, got %d", len(messages))
+	}
+
+	agent.model.Define = config.ChatModelDefine{IsMultimodal: true}
+	collect(mustChat(t, agent, "look", image, file))
+
+	input := cm.lastInput()[len(cm.lastInput())-1]
+	if input.Content != "" || len(input.UserInputMultiContent) != 3 {
+		t.Fatalf("Expected a multi-part message, got %+v", input)
+	}
+	parts := input.UserInputMultiContent
+	if parts[0].Text != "look" || parts[1].Image == nil || *parts[1].Image.Base64Data != base64.StdEncoding.EncodeToString(pngHeader) ||
+		parts[1].Image.MIMEType != "image/png" || !strings.Contains(parts[2].Text, "buy milk") {
+		t.Errorf("Unexpected parts %+v", parts)
+	}
+
+	// 消息只保存附件的元数据,内容保存在附件集合中
+	messages, _ := Messages(agent.session)
+	if len(messages[0].Attachments) != 2 || messages[0].Attachments[0].Name != "cat.png" {
+		t.Fatalf("Expected attachment metadata on the message, got %+v", messages[0].Attachments)
+	}
+	stored, err := GetAttachment(image.Id)
+	if err != nil || string(stored.Data) != string(pngHeader) || stored.MimeType != "image/png" {
+		t.Errorf("Unexpected stored attachment %+v %v", stored, err)
+	}
+
+	// 编辑消息沿用原来的附件,彻底删除会话时一并删除
+	out, err := agent.Edit(context.Background(), messages[0].Id, "look again", "")
+	if err != nil {
+		t.Fatalf("Edit failed: %v", err)
+	}
+	collect(out)
+	if input := cm.lastInput()[len(cm.lastInput())-1]; len(input.UserInputMultiContent) != 3 || input.UserInputMultiContent[0].Text != "look again" {
+		t.Errorf("Expected the edited message to keep its attachments, got %+v", input)
+	}
+	if n := countDocs(t, persist.Attachment); n != 2 {
+		t.Errorf("Expected attachments to be stored once, got %d", n)
+	}
+	if err := PurgeSession(agent.session); err != nil {
+		t.Fatalf("PurgeSession failed: %v", err)
+	}
+	if n := countDocs(t, persist.Attachment); n != 0 {
+		t.Errorf("Expected attachments to be purged, got %d", n)
+	}
+}
+
+func TestAttachmentNotesForTextModels(t *testing.T) {
+	openTestDB(t)
+	cm := echoModel()
+	agent := newTestAgent(t, cm, "", nil)
+
+	image, _ := NewAttachment(AttachmentImage, "cat.png", "", pngHeader)
+	agent.model.Define = config.ChatModelDefine{IsMultimodal: true}
+	collect(mustChat(t, agent, "look", image))
+
+	// 之后换成不支持多模态的模型,历史中的附件只以说明发送
+	agent.model.Define = config.ChatModelDefine{}
+	collect(mustChat(t, agent, "and now?"))
+	if input := cm.lastInput()[len(cm.lastInput())-3]; input.Content != "look\n\n[image: cat.png]" || len(input.UserInputMultiContent) != 0 {
+		t.Errorf("Expected a text note for the attachment, got %+v", input)
+	}
+}
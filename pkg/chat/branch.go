@@ -0,0 +1,160 @@
+package chat
+
+import (
+	"fmt"
+)
+
+// Thread 会话当前分支上的消息
+type Thread struct {
+	// Messages 从第一条消息到当前分支最后一条消息,以及覆盖这些消息的摘要,按顺序排列
+	Messages []*Message `json:"messages"`
+	// Branches 有多个版本的消息,键为消息 id,值为同一父消息下的全部版本,按创建顺序排列
+	Branches map[string][]string `json:"branches"`
+}
+
+// CurrentThread 返回会话当前分支上的消息
+func CurrentThread(session string) (*Thread, error) {
+	messages, err := Messages(session)
+	if err != nil {
+		return nil, err
+	}
+	return newThread(messages, currentLeafOf(session, messages)), nil
+}
+
+// SwitchBranch 切换到包含指定消息的分支,该消息之后沿最新的版本继续
+func SwitchBranch(session string, id string) (*Thread, error) {
+	messages, err := Messages(session)
+	if err != nil {
+		return nil, err
+	}
+	message := findMessage(messages, id)
+	if message == nil || message.Type == MessageTypeSummary {
+		return nil, fmt.Errorf("message not found: %s", id)
+	}
+
+	// messages 已按顺序排列,最后一个子消息即最新的版本
+	leaf := id
+	for {
+		var latest string
+		for _, m := range messages {
+			if m.ParentId == leaf && m.Type != MessageTypeSummary {
+				latest = m.Id
+			}
+		}
+		if latest == "" {
+			break
+		}
+		leaf = latest
+	}
+
+	if err := setCurrentMessage(session, leaf); err != nil {
+		return nil, err
+	}
+	return newThread(messages, leaf), nil
+}
+
+func setCurrentMessage(session string, id string) error {
+	return repo().UpdateSession(session, map[string]any{"current_message": id})
+}
+
+// currentLeaf 返回会话当前分支的最后一条消息,会话没有消息时为空
+func currentLeaf(session string) (string, error) {
+	messages, err := Messages(session)
+	if err != nil {
+		return "", err
+	}
+	return currentLeafOf(session, messages), nil
+}
+
+// currentLeafOf 会话记录的消息不存在时使用最新的消息
+func currentLeafOf(session string, messages []*Message) string {
+	s, _ := GetSession(session)
+	return leafOf(s, messages)
+}
+
+// leafOf 返回 s 记录的当前分支最后一条消息,s 为 nil 或记录的消息不存在时返回最新的消息
+func leafOf(s *Session, messages []*Message) string {
+	if s != nil && findMessage(messages, s.CurrentMessage) != nil {
+		return s.CurrentMessage
+	}
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Type != MessageTypeSummary {
+			return messages[i].Id
+		}
+	}
+	return ""
+}
+
+// branchPath 返回从第一条消息到 leaf 的消息,leaf 为空时返回空
+func branchPath(messages []*Message, leaf string) []*Message {
+	byId := make(map[string]*Message, len(messages))
+	for _, m := range messages {
+		byId[m.Id] = m
+	}
+
+	var path []*Message
+	for id := leaf; id != ""; {
+		m, ok := byId[id]
+		if !ok {
+			break
+		}
+		path = append(path, m)
+		id = m.ParentId
+		// 数据异常形成环时停止
+		if len(path) > len(messages) {
+			break
+		}
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// withSummaries 在分支消息中加入覆盖到分支上消息的摘要,返回按顺序排列的消息
+func withSummaries(messages []*Message, path []*Message) []*Message {
+	onPath := make(map[string]bool, len(path))
+	for _, m := range path {
+		onPath[m.Id] = true
+	}
+
+	output := make([]*Message, 0, len(path))
+	for _, m := range messages {
+		if onPath[m.Id] || (m.Type == MessageTypeSummary && onPath[m.SummaryUntil]) {
+			output = append(output, m)
+		}
+	}
+	return output
+}
+
+func newThread(messages []*Message, leaf string) *Thread {
+	thread := &Thread{
+		Messages: withSummaries(messages, branchPath(messages, leaf)),
+		Branches: make(map[string][]string),
+	}
+
+	siblings := make(map[string][]string)
+	for _, m := range messages {
+		if m.Type != MessageTypeSummary {
+			siblings[m.ParentId] = append(siblings[m.ParentId], m.Id)
+		}
+	}
+	for _, m := range thread.Messages {
+		if versions := siblings[m.ParentId]; m.Type != MessageTypeSummary && len(versions) > 1 {
+			thread.Branches[m.Id] = versions
+		}
+	}
+	return thread
+}
+
+func findMessage(messages []*Message, id string) *Message {
+	if id == "" {
+		return nil
+	}
+	for _, m := range messages {
+		if m.Id == id {
+			return m
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/AntNoHuabei/Remo/pkg/persist"
+)
+
+func threadContents(t *testing.T, session string) string {
+	thread, err := CurrentThread(session)
+	if err != nil {
+		t.Fatalf("CurrentThread failed: %v", err)
+	}
+	contents := make([]string, 0, len(thread.Messages))
+	for _, m := range thread.Messages {
+		contents = append(contents, m.Content)
+	}
+	return strings.Join(contents, "|")
+}
+
+func TestMessagesOrder(t *testing.T) {
+	openTestDB(t)
+	s := CreateSession()
+
+	// 创建时间相同时按序号排列
+	for _, content := range []string{"a", "b", "c"} {
+		if err := MessageAppend(s.Id, &Message{Role: "user", Content: content, CreatedTime: 1000}); err != nil {
+			t.Fatalf("MessageAppend failed: %v", err)
+		}
+	}
+	messages, _ := Messages(s.Id)
+	for i, m := range messages {
+		if m.Seq != int64(i+1) || m.Content != string(rune('a'+i)) {
+			t.Errorf("Unexpected message %d: seq %d content %q", i, m.Seq, m.Content)
+		}
+		if i > 0 && m.ParentId != messages[i-1].Id {
+			t.Errorf("Expected message %d to follow the previous one", i)
+		}
+	}
+
+	// 旧版本的消息没有序号和父消息,按时间连接
+	legacy := CreateSession()
+	for i, content := range []string{"old2", "old1"} {
+		m := &Message{Id: fmt.Sprintf("00000000-0000-0000-0000-00000000000%d", i), Session: legacy.Id, Role: "user", Content: content, CreatedTime: int64(2 - i)}
+		doc, _ := persist.NewDocument(m)
+		doc.Set("_id", m.Id)
+		persist.DB.InsertOne(persist.Message, doc)
+	}
+	if got := threadContents(t, legacy.Id); got != "old1|old2" {
+		t.Errorf("Expected legacy messages in time order, got %q", got)
+	}
+	MessageAppend(legacy.Id, &Message{Role: "user", Content: "new"})
+	if got := threadContents(t, legacy.Id); got != "old1|old2|new" {
+		t.Errorf("Expected new messages to follow legacy ones, got %q", got)
+	}
+}
+
+func TestEditAndRegenerate(t *testing.T) {
+	openTestDB(t)
+	cm := echoModel()
+	agent := newTestAgent(t, cm, "", nil)
+	ctx := context.Background()
+
+	collect(mustChat(t, agent, "a"))
+	collect(mustChat(t, agent, "b"))
+	if got := threadContents(t, agent.session); got != "a|re a #1|b|re b #2" {
+		t.Fatalf("Unexpected thread %q", got)
+	}
+
+	// 重新生成最后的回复,历史不包括旧的回复
+	thread, _ := CurrentThread(agent.session)
+	oldReply := thread.Messages[3]
+	out, err := agent.Regenerate(ctx, oldReply.Id, "")
+	if err != nil {
+		t.Fatalf("Regenerate failed: %v", err)
+	}
+	collect(out)
+	if got := cm.lastContents(); got != "a|re a #1|b" {
+		t.Errorf("Unexpected model input %q", got)
+	}
+	thread, _ = CurrentThread(agent.session)
+	if got := threadContents(t, agent.session); got != "a|re a #1|b|re b #3" {
+		t.Errorf("Unexpected thread after regenerate %q", got)
+	}
+	if versions := thread.Branches[thread.Messages[3].Id]; len(versions) != 2 || versions[0] != oldReply.Id {
+		t.Errorf("Expected both replies as versions, got %v", versions)
+	}
+
+	// 修改第一条消息,从头开始新的分支
+	first := thread.Messages[0]
+	out, err = agent.Edit(ctx, first.Id, "c", "")
+	if err != nil {
+		t.Fatalf("Edit failed: %v", err)
+	}
+	collect(out)
+	if got := cm.lastContents(); got != "c" {
+		t.Errorf("Expected only the edited message in the model input, got %q", got)
+	}
+	thread, _ = CurrentThread(agent.session)
+	if got := threadContents(t, agent.session); got != "c|re c #4" {
+		t.Errorf("Unexpected thread after edit %q", got)
+	}
+	if versions := thread.Branches[thread.Messages[0].Id]; len(versions) != 2 {
+		t.Errorf("Expected 2 versions of the first message, got %v", versions)
+	}
+	if _, err := agent.Edit(ctx, thread.Messages[1].Id, "x", ""); err == nil {
+		t.Error("Expected editing an assistant message to fail")
+	}
+
+	// 切换回原来的版本,沿最新的回复继续
+	thread, err = SwitchBranch(agent.session, first.Id)
+	if err != nil {
+		t.Fatalf("SwitchBranch failed: %v", err)
+	}
+	if got := threadContents(t, agent.session); got != "a|re a #1|b|re b #3" || len(thread.Messages) != 4 {
+		t.Errorf("Unexpected thread after switching %q", got)
+	}
+
+	// 继续对话接在切换后的分支上
+	loadSession(agent, agent.session)
+	collect(mustChat(t, agent, "d"))
+	if got := cm.lastContents(); got != "a|re a #1|b|re b #3|d" {
+		t.Errorf("Unexpected model input after switching %q", got)
+	}
+}
@@ -0,0 +1,44 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cloudwego/eino/compose"
+)
+
+// DefaultCheckpointTTL 断点默认保留时间,超过该时间未更新的断点会被清理
+const DefaultCheckpointTTL = 7 * 24 * time.Hour
+
+// NewStore 创建会话的断点存储,断点写入会话数据的存储,进程重启后仍可恢复
+func NewStore(session string) (compose.CheckPointStore, error) {
+	if session == "" {
+		return nil, fmt.Errorf("session is required")
+	}
+	return &sessionStore{
+		session: session,
+	}, nil
+}
+
+type sessionStore struct {
+	session string
+}
+
+func (i *sessionStore) Set(ctx context.Context, key string, value []byte) error {
+	return repo().SetCheckpoint(i.session, key, value)
+}
+
+func (i *sessionStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	return repo().GetCheckpoint(i.session, key)
+}
+
+// DeleteCheckpoints 删除会话的全部断点
+func DeleteCheckpoints(session string) error {
+	return repo().DeleteCheckpoints(session)
+}
+
+// CleanCheckpoints 清理超过 ttl 未更新的断点,以及旧版本遗留的按会话存储的断点文档,返回清理的数量
+func CleanCheckpoints(ttl time.Duration) (int, error) {
+	return repo().CleanCheckpoints(time.Now().Add(-ttl).UnixMilli())
+}
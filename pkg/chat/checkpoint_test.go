@@ -0,0 +1,130 @@
+package chat
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/AntNoHuabei/Remo/internal/log"
+	"github.com/AntNoHuabei/Remo/pkg/persist"
+	"github.com/ostafen/clover"
+)
+
+func TestMain(m *testing.M) {
+	// 只输出到控制台,避免在包目录下生成日志文件
+	if err := log.Init(&log.Config{Level: "error"}); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func openTestDB(t *testing.T) string {
+	dir := t.TempDir()
+	if err := persist.Open(dir); err != nil {
+		t.Fatalf("Failed to open db: %v", err)
+	}
+	t.Cleanup(func() {
+		persist.DB.Close()
+	})
+	return dir
+}
+
+func TestSessionStoreGetMissing(t *testing.T) {
+	openTestDB(t)
+
+	s, err := NewStore("s1")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	v, ok, err := s.Get(context.Background(), "missing")
+	if err != nil || ok || v != nil {
+		t.Errorf("Expected (nil, false, nil), got (%v, %v, %v)", v, ok, err)
+	}
+}
+
+func TestSessionStoreUpsert(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+
+	s, _ := NewStore("s1")
+	if err := s.Set(ctx, "cp", []byte("v1")); err != nil {
+		t.Fatalf("Failed to set checkpoint: %v", err)
+	}
+	if err := s.Set(ctx, "cp", []byte("v2")); err != nil {
+		t.Fatalf("Failed to overwrite checkpoint: %v", err)
+	}
+
+	v, ok, err := s.Get(ctx, "cp")
+	if err != nil || !ok {
+		t.Fatalf("Expected checkpoint to exist, got ok=%v err=%v", ok, err)
+	}
+	if string(v) != "v2" {
+		t.Errorf("Expected 'v2', got '%s'", v)
+	}
+
+	n, _ := persist.DB.Query(persist.SessionCheckpoint).Count()
+	if n != 1 {
+		t.Errorf("Expected 1 checkpoint document, got %d", n)
+	}
+
+	// 不同会话的同名断点互不影响
+	other, _ := NewStore("s2")
+	if _, ok, _ := other.Get(ctx, "cp"); ok {
+		t.Error("Checkpoint should not be visible from another session")
+	}
+}
+
+func TestSessionStorePersistsAcrossReopen(t *testing.T) {
+	dir := openTestDB(t)
+	ctx := context.Background()
+
+	s, _ := NewStore("s1")
+	if err := s.Set(ctx, "cp", []byte("data")); err != nil {
+		t.Fatalf("Failed to set checkpoint: %v", err)
+	}
+
+	persist.DB.Close()
+	if err := persist.Open(dir); err != nil {
+		t.Fatalf("Failed to reopen db: %v", err)
+	}
+
+	s, _ = NewStore("s1")
+	v, ok, err := s.Get(ctx, "cp")
+	if err != nil || !ok || string(v) != "data" {
+		t.Errorf("Expected checkpoint to survive reopen, got (%s, %v, %v)", v, ok, err)
+	}
+}
+
+func TestCleanCheckpoints(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+
+	s, _ := NewStore("s1")
+	s.Set(ctx, "fresh", []byte("1"))
+	s.Set(ctx, "stale", []byte("2"))
+
+	// 模拟过期断点和旧版本遗留的按会话存储的文档
+	persist.DB.Query(persist.SessionCheckpoint).UpdateById(checkpointDocId("s1", "stale"), map[string]interface{}{
+		"updated_time": time.Now().Add(-2 * time.Hour).UnixMilli(),
+	})
+	legacy := clover.NewDocument()
+	legacy.Set("_id", "s1")
+	persist.DB.InsertOne(persist.SessionCheckpoint, legacy)
+
+	n, err := CleanCheckpoints(time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to clean checkpoints: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 checkpoints cleaned, got %d", n)
+	}
+
+	if _, ok, _ := s.Get(ctx, "fresh"); !ok {
+		t.Error("Fresh checkpoint should be kept")
+	}
+	if _, ok, _ := s.Get(ctx, "stale"); ok {
+		t.Error("Stale checkpoint should be removed")
+	}
+}
@@ -0,0 +1,259 @@
+package chat
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/AntNoHuabei/Remo/internal/log"
+	"github.com/AntNoHuabei/Remo/pkg/provider"
+	"github.com/AntNoHuabei/Remo/pkg/usage"
+	"github.com/cloudwego/eino/adk"
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	// DefaultContextWindow 模型未配置上下文长度时使用的默认值
+	DefaultContextWindow = 32768
+	// defaultOutputReserve 为模型输出预留的 token 数,会话设置了 max_tokens 时使用该值
+	defaultOutputReserve = 4096
+	// compactThreshold 历史超过可用上下文的该比例时触发摘要
+	compactThreshold = 0.8
+	// compactTarget 摘要后保留的历史不超过可用上下文的该比例
+	compactTarget = 0.5
+	// minKeepMessages 摘要时至少保留的最近消息数
+	minKeepMessages = 4
+	// messageOverhead 每条消息的格式开销
+	messageOverhead = 4
+)
+
+const summaryPrompt = `You compress conversations. Summarize the conversation below between a user and an assistant so that it can continue with your summary in place of the original messages.
+Keep facts, names, numbers, decisions, user preferences, open questions and anything the assistant promised to do. Drop greetings and small talk.
+If a previous summary is given, merge it into the new summary. Write the summary in the language of the conversation and output only the summary.`
+
+// EstimateTokens 估算文本的 token 数,中日韩文字按每字 1 个 token,其它字符按每 4 个字节 1 个 token
+func EstimateTokens(text string) int {
+	cjk, other := 0, 0
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+		} else {
+			other += utf8.RuneLen(r)
+		}
+	}
+	return cjk + (other+3)/4
+}
+
+func messageTokens(m *Message) int {
+	tokens := m.Tokens
+	if tokens == 0 {
+		tokens = EstimateTokens(m.Content)
+	}
+	return tokens + messageOverhead
+}
+
+// contextBudget 返回历史消息可以使用的 token 数
+func contextBudget(m *provider.Model, options provider.GenerateOptions, instruction string) int {
+	window := m.Define.ContextWindow
+	if window <= 0 {
+		window = DefaultContextWindow
+	}
+	reserve := defaultOutputReserve
+	if options.MaxTokens != nil && *options.MaxTokens > 0 {
+		reserve = *options.MaxTokens
+	}
+	return max(window-reserve-EstimateTokens(instruction), 0)
+}
+
+// splitSummary 返回最近的摘要和摘要之后仍需发送给模型的消息,
+// 覆盖到的消息不在 messages 中的摘要(已删除或属于其它分支)会被忽略
+func splitSummary(messages []*Message) (*Message, []*Message) {
+	ids := make(map[string]bool, len(messages))
+	for _, m := range messages {
+		ids[m.Id] = true
+	}
+	var summary *Message
+	for _, m := range messages {
+		if m.Type == MessageTypeSummary && ids[m.SummaryUntil] {
+			summary = m
+		}
+	}
+	covered := summary != nil
+
+	output := make([]*Message, 0, len(messages))
+	for _, m := range messages {
+		if m.Type == MessageTypeSummary {
+			continue
+		}
+		if covered {
+			covered = m.Id != summary.SummaryUntil
+			continue
+		}
+		output = append(output, m)
+	}
+	return summary, output
+}
+
+// SummaryBoundary 返回最近一次摘要覆盖到的消息 id,没有摘要时为空
+func SummaryBoundary(messages []*Message) string {
+	summary, _ := splitSummary(messages)
+	if summary == nil {
+		return ""
+	}
+	return summary.SummaryUntil
+}
+
+// genModelInput 在系统提示词后附加长期记忆、知识库片段和较早对话的摘要
+func (agent *ContinuousAgent) genModelInput(ctx context.Context, instruction string, input *adk.AgentInput) ([]adk.Message, error) {
+	system := instruction
+	if len(agent.memories) > 0 {
+		system += "\n\nThings you remember about the user from earlier conversations:\n- " + strings.Join(agent.memories, "\n- ")
+	}
+	if len(agent.references) > 0 {
+		system += "\n\n" + referencePrompt(agent.references)
+	}
+	if agent.summary != nil {
+		system += "\n\nSummary of the earlier conversation:\n" + agent.summary.Content
+	}
+	system = strings.TrimSpace(system)
+
+	output := make([]adk.Message, 0, len(input.Messages)+1)
+	if system != "" {
+		output = append(output, schema.SystemMessage(system))
+	}
+	return append(output, input.Messages...), nil
+}
+
+// input 返回发送给模型的历史消息,多模态模型发送附件内容,其它模型只说明消息带有附件
+func (agent *ContinuousAgent) input() []adk.Message {
+	multimodal := agent.multimodal()
+	output := make([]adk.Message, 0, len(agent.history))
+	for _, m := range agent.history {
+		if m.Content == "" && len(m.Attachments) == 0 {
+			continue
+		}
+		msg := &schema.Message{
+			Content: m.Content,
+			Role:    schema.RoleType(m.Role),
+		}
+		if len(m.Attachments) > 0 {
+			parts, err := inputParts(m)
+			if err != nil {
+				log.Warn("failed to load attachments", "session", agent.session, "message", m.Id, "error", err)
+			}
+			if multimodal && err == nil && msg.Role == schema.User {
+				msg.Content = ""
+				msg.UserInputMultiContent = parts
+			} else {
+				msg.Content = strings.TrimSpace(m.Content + "\n\n" + attachmentNotes(m))
+			}
+		}
+		output = append(output, msg)
+	}
+	return output
+}
+
+// multimodal 当前模型是否支持图片等多模态输入
+func (agent *ContinuousAgent) multimodal() bool {
+	return agent.model != nil && agent.model.Define.IsMultimodal
+}
+
+// compact 历史超过上下文预算时,将较早的消息压缩为摘要并保存,返回新的摘要。
+// 摘要失败时本轮只丢弃较早的消息,生成被中止时保留历史不变
+func (agent *ContinuousAgent) compact(ctx context.Context) *Message {
+	if agent.chatModel == nil || agent.model == nil {
+		return nil
+	}
+
+	budget := contextBudget(agent.model, agent.sessionOptions, agent.instruction)
+	total := 0
+	if agent.summary != nil {
+		total += messageTokens(agent.summary)
+	}
+	for _, m := range agent.history {
+		total += messageTokens(m)
+	}
+	if float64(total) <= float64(budget)*compactThreshold {
+		return nil
+	}
+
+	// 从最早的消息开始压缩,保留的历史从用户消息开始
+	target := float64(budget) * compactTarget
+	cut := 0
+	for cut < len(agent.history)-minKeepMessages && float64(total) > target {
+		total -= messageTokens(agent.history[cut])
+		cut++
+	}
+	for cut > 0 && cut < len(agent.history)-1 && agent.history[cut].Role != string(schema.User) {
+		cut++
+	}
+	if cut == 0 {
+		return nil
+	}
+
+	content, err := agent.summarize(ctx, agent.history[:cut])
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		log.Warn("failed to summarize conversation", "session", agent.session, "error", err)
+		agent.history = agent.history[cut:]
+		return nil
+	}
+
+	summary := &Message{
+		Session:      agent.session,
+		Content:      content,
+		Role:         string(schema.System),
+		Model:        agent.model.Name,
+		Status:       MessageStatusCompleted,
+		Type:         MessageTypeSummary,
+		SummaryUntil: agent.history[cut-1].Id,
+		CreatedTime:  time.Now().UnixMilli(),
+	}
+	if err := MessageAppend(agent.session, summary); err != nil {
+		log.Warn("failed to save summary", "session", agent.session, "error", err)
+	}
+	agent.summary = summary
+	agent.history = agent.history[cut:]
+	return summary
+}
+
+// summarize 调用模型将消息和之前的摘要合并为新的摘要
+func (agent *ContinuousAgent) summarize(ctx context.Context, messages []*Message) (string, error) {
+	var sb strings.Builder
+	if agent.summary != nil {
+		sb.WriteString("Previous summary:\n")
+		sb.WriteString(agent.summary.Content)
+		sb.WriteString("\n\n")
+	}
+	sb.WriteString("Conversation:\n")
+	for _, m := range messages {
+		content := strings.TrimSpace(m.Content + " " + attachmentNotes(m))
+		if content == "" {
+			continue
+		}
+		switch schema.RoleType(m.Role) {
+		case schema.User:
+			sb.WriteString("User: ")
+		case schema.Assistant:
+			sb.WriteString("Assistant: ")
+		default:
+			sb.WriteString(m.Role + ": ")
+		}
+		sb.WriteString(content)
+		sb.WriteString("\n\n")
+	}
+
+	cm := Meter(agent.chatModel, agent.model, agent.session, usage.PurposeSummary)
+	msg, err := cm.Generate(ctx, []*schema.Message{
+		schema.SystemMessage(summaryPrompt),
+		schema.UserMessage(sb.String()),
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(msg.Content), nil
+}
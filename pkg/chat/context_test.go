@@ -0,0 +1,204 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/AntNoHuabei/Remo/pkg/api/response"
+	"github.com/cloudwego/eino/adk"
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	cases := map[string]int{
+		"":         0,
+		"abcd":     1,
+		"abcde":    2,
+		"你好世界":     4,
+		"hello 世界": 4,
+	}
+	for text, expected := range cases {
+		if got := EstimateTokens(text); got != expected {
+			t.Errorf("EstimateTokens(%q) = %d, expected %d", text, got, expected)
+		}
+	}
+}
+
+func TestSplitSummary(t *testing.T) {
+	messages := []*Message{
+		{Id: "1", Role: "user", Content: "a"},
+		{Id: "2", Role: "assistant", Content: "b"},
+		{Id: "s", Role: "system", Content: "summary", Type: MessageTypeSummary, SummaryUntil: "2"},
+		{Id: "3", Role: "user", Content: "c"},
+	}
+
+	summary, rest := splitSummary(messages)
+	if summary == nil || summary.Id != "s" {
+		t.Fatalf("Expected summary s, got %+v", summary)
+	}
+	if len(rest) != 1 || rest[0].Id != "3" {
+		t.Errorf("Expected only message 3 after the summary, got %d messages", len(rest))
+	}
+	if boundary := SummaryBoundary(messages); boundary != "2" {
+		t.Errorf("Expected boundary 2, got %q", boundary)
+	}
+
+	// 摘要覆盖的消息不存在时回退为完整历史
+	summary, rest = splitSummary(messages[2:])
+	if summary != nil || len(rest) != 1 {
+		t.Errorf("Expected summary to be ignored, got %+v and %d messages", summary, len(rest))
+	}
+}
+
+func appendHistory(t *testing.T, agent *ContinuousAgent, n int) {
+	start := len(agent.history)
+	for i := start; i < start+n; i++ {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		message := &Message{
+			Session:     agent.session,
+			Role:        role,
+			Content:     fmt.Sprintf("message %d %s", i, strings.Repeat("x", 40)),
+			CreatedTime: int64(i + 1),
+		}
+		if err := MessageAppend(agent.session, message); err != nil {
+			t.Fatalf("Failed to append message: %v", err)
+		}
+		agent.history = append(agent.history, message)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	openTestDB(t)
+	cm := replyModel("  the user likes tea  ", nil)
+	agent := newTestAgent(t, cm, "", nil)
+	agent.model.Define.ContextWindow = 200
+	agent.sessionOptions.MaxTokens = new(int)
+	*agent.sessionOptions.MaxTokens = 50
+
+	// 预算 150,每条约 16 token
+	appendHistory(t, agent, 4)
+	agent.compact(context.Background())
+	if agent.summary != nil || len(agent.history) != 4 {
+		t.Fatalf("Expected no compaction under the threshold")
+	}
+
+	appendHistory(t, agent, 6)
+	agent.compact(context.Background())
+	if agent.summary == nil {
+		t.Fatal("Expected a summary")
+	}
+	if agent.summary.Content != "the user likes tea" {
+		t.Errorf("Unexpected summary content %q", agent.summary.Content)
+	}
+	if len(agent.history) < minKeepMessages || agent.history[0].Role != "user" {
+		t.Errorf("Expected kept history to start with a user message, got %d messages", len(agent.history))
+	}
+	if !strings.Contains(cm.lastInput()[1].Content, "User: message 0") {
+		t.Errorf("Expected the oldest message in the summary input, got %q", cm.lastInput()[1].Content)
+	}
+
+	// 重新加载时摘要替代被覆盖的消息
+	messages, err := Messages(agent.session)
+	if err != nil {
+		t.Fatalf("Failed to load messages: %v", err)
+	}
+	summary, rest := splitSummary(messages)
+	if summary == nil || summary.Id != agent.summary.Id {
+		t.Fatalf("Expected the persisted summary, got %+v", summary)
+	}
+	if len(rest) != len(agent.history) || rest[0].Id != agent.history[0].Id {
+		t.Errorf("Expected %d messages after reload, got %d", len(agent.history), len(rest))
+	}
+
+	input, err := agent.genModelInput(context.Background(), "be nice", &adk.AgentInput{Messages: agent.input()})
+	if err != nil {
+		t.Fatalf("genModelInput failed: %v", err)
+	}
+	if input[0].Role != schema.System || !strings.Contains(input[0].Content, "the user likes tea") || !strings.HasPrefix(input[0].Content, "be nice") {
+		t.Errorf("Expected instruction with summary, got %q", input[0].Content)
+	}
+	if len(input) != len(agent.history)+1 {
+		t.Errorf("Expected %d input messages, got %d", len(agent.history)+1, len(input))
+	}
+}
+
+func TestCompactSummaryFailure(t *testing.T) {
+	openTestDB(t)
+	agent := newTestAgent(t, replyModel("", errors.New("boom")), "", nil)
+	agent.model.Define.ContextWindow = 200
+	agent.sessionOptions.MaxTokens = new(int)
+	*agent.sessionOptions.MaxTokens = 50
+
+	appendHistory(t, agent, 10)
+	agent.compact(context.Background())
+	if agent.summary != nil {
+		t.Error("Expected no summary when summarising fails")
+	}
+	if len(agent.history) >= 10 {
+		t.Errorf("Expected older messages to be dropped, got %d", len(agent.history))
+	}
+
+	messages, _ := Messages(agent.session)
+	if SummaryBoundary(messages) != "" {
+		t.Error("Expected no summary to be persisted")
+	}
+}
+
+// newCompactingAgent 创建历史已超过上下文预算的智能体
+func newCompactingAgent(t *testing.T, cm *fakeModel) *ContinuousAgent {
+	agent := newTestAgent(t, cm, "", nil)
+	agent.model.Define.ContextWindow = 200
+	agent.sessionOptions.MaxTokens = new(int)
+	*agent.sessionOptions.MaxTokens = 50
+	appendHistory(t, agent, 10)
+	return agent
+}
+
+func TestChatCompactsAfterStart(t *testing.T) {
+	openTestDB(t)
+	agent := newCompactingAgent(t, replyModel("the user likes tea", nil))
+
+	events := collectEvents(mustChat(t, agent, "hi"))
+	if events[0].Event != response.EventStart || events[1].Event != response.EventSummary {
+		t.Fatalf("Expected a summary event after the start, got %s and %s", events[0].Event, events[1].Event)
+	}
+	if summary := events[1].Summary; summary == nil || summary.Id != agent.summary.Id || summary.Content != "the user likes tea" {
+		t.Errorf("Unexpected summary event %+v", summary)
+	}
+	if done := events[len(events)-1].Done; done == nil || done.Status != MessageStatusCompleted {
+		t.Errorf("Unexpected done event %+v", events[len(events)-1])
+	}
+}
+
+func TestAbortDuringCompaction(t *testing.T) {
+	openTestDB(t)
+	release := make(chan struct{})
+	agent := newCompactingAgent(t, &fakeModel{reply: func(input []*schema.Message, call int) (*schema.Message, error) {
+		<-release
+		return nil, context.Canceled
+	}})
+	history := len(agent.history)
+
+	var status string
+	for res := range mustChat(t, agent, "hi") {
+		if res.Event == response.EventStart {
+			agent.Abort()
+			close(release)
+		}
+		if res.Done != nil {
+			status = res.Done.Status
+		}
+	}
+	if status != MessageStatusAborted {
+		t.Fatalf("Expected the generation to be aborted, got %q", status)
+	}
+	if agent.summary != nil || len(agent.history) != history+1 {
+		t.Errorf("Expected the history to be kept, got %d messages", len(agent.history))
+	}
+}
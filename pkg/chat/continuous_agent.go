@@ -2,31 +2,89 @@ package chat
 
 import (
 	"context"
+	"fmt"
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/AntNoHuabei/Remo/internal/log"
 	"github.com/AntNoHuabei/Remo/pkg/api/response"
-	"github.com/cloudwego/eino-ext/components/model/deepseek"
+	"github.com/AntNoHuabei/Remo/pkg/provider"
+	"github.com/AntNoHuabei/Remo/pkg/usage"
 	"github.com/cloudwego/eino/adk"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/compose"
 	"github.com/cloudwego/eino/schema"
 	"github.com/google/uuid"
+	"io"
+	"sync"
+	"time"
 )
 
-func NewContinuousAgent(ctx context.Context) (*ContinuousAgent, error) {
+// NewContinuousAgent 创建对话智能体,modelName 不为空时覆盖会话中设置的模型
+// 智能体在 Recover 时根据会话设置构建
+func NewContinuousAgent(ctx context.Context, modelName string) (*ContinuousAgent, error) {
 
-	cm, err := deepseek.NewChatModel(ctx, &deepseek.ChatModelConfig{
-		APIKey:  "sk-0ed0d69fac2c468ba57ac31fc2faf1e3",
-		Model:   "deepseek-reasoner",
-		BaseURL: "https://api.deepseek.com/beta",
-	})
+	if modelName != "" {
+		if _, err := provider.Get().Resolve(modelName); err != nil {
+			return nil, err
+		}
+	}
+
+	return &ContinuousAgent{
+		modelName: modelName,
+	}, nil
+}
+
+type ContinuousAgent struct {
+	agent          *adk.ChatModelAgent
+	chatModel      model.BaseChatModel // 用于生成摘要
+	model          *provider.Model
+	modelName      string
+	options        []model.Option
+	sessionOptions provider.GenerateOptions
+	instruction    string
+	session        string
+	runner         *adk.Runner
+	summary        *Message            // 较早对话的摘要
+	history        []*Message          // 摘要之后的消息
+	leaf           string              // 当前分支的最后一条消息,新消息接在它之后
+	memories       []string            // 本轮检索到的长期记忆
+	references     []response.Citation // 本轮检索到的知识库片段
+	budget         *usage.Budget       // 本轮开始时已超出的预算,不为空时发送提醒
+	untitled       bool                // 会话还没有标题,第一轮对话结束后自动生成
+	titleModel     string
+	language       string
+	mu             sync.Mutex
+	cancel         context.CancelFunc
+}
+
+// build 根据会话设置构建智能体
+func (agent *ContinuousAgent) build(ctx context.Context, session *Session) error {
+
+	modelName := agent.modelName
+	if modelName == "" {
+		modelName = session.Model
+	}
+
+	cm, m, err := provider.Get().NewChatModel(ctx, modelName)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	tools, err := agentTools(ctx, config.Get().GetTools())
+	if err != nil {
+		return err
 	}
 
-	agent, err := adk.NewChatModelAgent(ctx, &adk.ChatModelAgentConfig{
-		Name:          "ContinuousAgent",
-		Description:   "I can keep talking with you and remember everything you've said",
-		Instruction:   "",
-		Model:         cm,
-		ToolsConfig:   adk.ToolsConfig{},
-		GenModelInput: nil,
+	a, err := adk.NewChatModelAgent(ctx, &adk.ChatModelAgentConfig{
+		Name:        "ContinuousAgent",
+		Description: "I can keep talking with you and remember everything you've said",
+		Instruction: session.Instruction,
+		Model:       MeterTools(cm, m, session.Id, usage.PurposeChat),
+		ToolsConfig: adk.ToolsConfig{
+			ToolsNodeConfig: compose.ToolsNodeConfig{
+				Tools: tools,
+			},
+		},
+		GenModelInput: agent.genModelInput,
 		Exit:          nil,
 		OutputKey:     "",
 		MaxIterations: 0,
@@ -34,24 +92,35 @@ func NewContinuousAgent(ctx context.Context) (*ContinuousAgent, error) {
 	})
 
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return &ContinuousAgent{
-		agent: agent,
-	}, nil
-}
-
-type ContinuousAgent struct {
-	agent    *adk.ChatModelAgent
-	session  string
-	runner   *adk.Runner
-	messages []adk.Message
+	agent.agent = a
+	agent.chatModel = cm
+	agent.model = m
+	agent.options = m.ModelOptions(session.Options)
+	agent.sessionOptions = session.Options
+	agent.instruction = session.Instruction
+	agent.untitled = session.Title == DefaultSessionTitle
+	agent.titleModel = config.Get().GetModels().TitleModel
+	agent.language = config.Get().GetApp().Language
+	return nil
 }
 
 // Recover 从断点恢复
 func (agent *ContinuousAgent) Recover(ctx context.Context, session string) error {
 
+	sess, err := GetSession(session)
+	if err != nil {
+		return err
+	}
+	if err := sess.CheckActive(); err != nil {
+		return err
+	}
+	if err := agent.build(ctx, sess); err != nil {
+		return err
+	}
+
 	s, err := NewStore(session)
 	if err != nil {
 		return err
@@ -62,26 +131,33 @@ func (agent *ContinuousAgent) Recover(ctx context.Context, session string) error
 		CheckPointStore: s,
 	})
 
-	messages, err := Messages(session)
-
+	leaf, err := currentLeaf(session)
 	if err != nil {
 		return err
 	}
-
 	agent.session = session
-	agent.messages = make([]adk.Message, 0)
-	for _, message := range messages {
-		agent.messages = append(agent.messages, &schema.Message{
-			Content: message.Content,
-			Role:    schema.RoleType(message.Role),
-		})
+	return agent.checkout(leaf)
+}
+
+// checkout 切换到以 leaf 结尾的分支,leaf 为空表示从第一条消息之前开始
+func (agent *ContinuousAgent) checkout(leaf string) error {
+	messages, err := Messages(agent.session)
+	if err != nil {
+		return err
 	}
 
+	agent.leaf = leaf
+	agent.summary, agent.history = splitSummary(withSummaries(messages, branchPath(messages, leaf)))
 	return nil
 }
 
+// Abort 取消正在进行的生成,已生成的内容会以 aborted 状态保存
 func (agent *ContinuousAgent) Abort() {
-
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+	if agent.cancel != nil {
+		agent.cancel()
+	}
 }
 
 func (agent *ContinuousAgent) Chat(ctx context.Context, message *Message) (<-chan response.ChatResponse, error) {
@@ -89,80 +165,357 @@ func (agent *ContinuousAgent) Chat(ctx context.Context, message *Message) (<-cha
 	if message.RequestId == "" {
 		message.RequestId = uuid.New().String()
 	}
+	if len(message.Attachments) > 0 && !agent.multimodal() {
+		return nil, agent.notMultimodal()
+	}
+	if err := agent.checkBudget(); err != nil {
+		return nil, err
+	}
+
+	if message.Model == "" && agent.model != nil {
+		message.Model = agent.model.Name
+	}
+	agent.append(message)
+	return agent.generate(ctx, message.RequestId, message.Content), nil
+
+}
+
+// Edit 修改用户消息,在原消息的父消息下创建新的分支并重新生成回复,原消息及其后续保留为另一个版本,
+// 新版本沿用原消息的附件
+func (agent *ContinuousAgent) Edit(ctx context.Context, id string, content string, requestId string) (<-chan response.ChatResponse, error) {
+
+	original, err := agent.message(id)
+	if err != nil {
+		return nil, err
+	}
+	if original.Role != string(schema.User) {
+		return nil, fmt.Errorf("only user messages can be edited")
+	}
+	// 编辑第一条消息时 ParentId 为空,新的分支同样从头开始
+	if err := agent.checkout(original.ParentId); err != nil {
+		return nil, err
+	}
+
+	return agent.Chat(ctx, &Message{
+		Content:     content,
+		Role:        string(schema.User),
+		Session:     agent.session,
+		RequestId:   requestId,
+		Attachments: original.Attachments,
+	})
+}
+
+// Regenerate 为同一条用户消息生成新的回复,id 为助手消息时重新生成该回复,为用户消息时为其生成回复
+func (agent *ContinuousAgent) Regenerate(ctx context.Context, id string, requestId string) (<-chan response.ChatResponse, error) {
+
+	message, err := agent.message(id)
+	if err != nil {
+		return nil, err
+	}
+	prompt := message
+	if message.Role == string(schema.Assistant) {
+		if prompt, err = agent.message(message.ParentId); err != nil {
+			return nil, err
+		}
+	}
+	if prompt.Role != string(schema.User) {
+		return nil, fmt.Errorf("no user message to regenerate a reply for")
+	}
+	if len(prompt.Attachments) > 0 && !agent.multimodal() {
+		return nil, agent.notMultimodal()
+	}
+	if err := agent.checkBudget(); err != nil {
+		return nil, err
+	}
+	if err := agent.checkout(prompt.Id); err != nil {
+		return nil, err
+	}
+
+	if requestId == "" {
+		requestId = uuid.New().String()
+	}
+	return agent.generate(ctx, requestId, prompt.Content), nil
+}
+
+func (agent *ContinuousAgent) notMultimodal() error {
+	name := ""
+	if agent.model != nil {
+		name = agent.model.Name
+	}
+	return fmt.Errorf("%w: %s is not a multimodal model, choose a multimodal model to send images or files", ErrNotMultimodal, name)
+}
+
+// message 查找会话中的消息
+func (agent *ContinuousAgent) message(id string) (*Message, error) {
+	messages, err := Messages(agent.session)
+	if err != nil {
+		return nil, err
+	}
+	message := findMessage(messages, id)
+	if message == nil || message.Type == MessageTypeSummary {
+		return nil, fmt.Errorf("message not found: %s", id)
+	}
+	return message, nil
+}
+
+// append 将消息保存到当前分支的最后
+func (agent *ContinuousAgent) append(message *Message) {
+	message.ParentId = agent.leaf
+	if err := saveMessage(agent.session, message); err != nil {
+		log.Warn("failed to save message", "session", agent.session, "error", err)
+	}
+	agent.history = append(agent.history, message)
+	agent.leaf = message.Id
+}
+
+// generate 根据当前分支的历史生成回复,prompt 用于检索记忆和知识库
+func (agent *ContinuousAgent) generate(ctx context.Context, requestId string, prompt string) <-chan response.ChatResponse {
 
-	//TODO 判断是否有工具调用确认
+	runCtx, cancel := agent.begin(ctx, requestId)
 
-	agent.messages = append(agent.messages, &schema.Message{
-		Content: message.Content,
-		Role:    schema.User,
+	return agent.consume(ctx, runCtx, cancel, requestId, func(send func(response.ChatResponse)) *adk.AsyncIterator[*adk.AgentEvent] {
+		// 历史过长时压缩较早的消息,压缩在开始后进行,可以被中止
+		if summary := agent.compact(runCtx); summary != nil {
+			send(response.ChatResponse{Event: response.EventSummary, Summary: &response.Summary{
+				Id:      summary.Id,
+				Until:   summary.SummaryUntil,
+				Content: summary.Content,
+			}})
+		}
+		agent.memories = agent.recall(runCtx, prompt)
+		agent.references = agent.retrieve(runCtx, prompt)
+		if len(agent.references) > 0 {
+			send(response.ChatResponse{Event: response.EventCitation, Citations: agent.references})
+		}
+
+		return agent.runner.Run(runCtx, agent.input(), adk.WithCheckPointID(checkPointID(requestId)),
+			adk.WithChatModelOptions(agent.options))
 	})
+}
+
+// Confirm 对等待确认的工具调用作出决定,并从断点继续生成
+func (agent *ContinuousAgent) Confirm(ctx context.Context, requestId string, interruptId string, decision *ToolDecision) (<-chan response.ChatResponse, error) {
+
+	if err := agent.checkBudget(); err != nil {
+		return nil, err
+	}
 
-	MessageAppend(agent.session, message)
+	runCtx, cancel := agent.begin(ctx, requestId)
 
-	ch := make(chan response.ChatResponse)
+	it, err := agent.runner.ResumeWithParams(runCtx, checkPointID(requestId), &adk.ResumeParams{
+		Targets: map[string]any{interruptId: decision},
+	}, adk.WithChatModelOptions(agent.options))
+	if err != nil {
+		agent.end(requestId, cancel)
+		return nil, err
+	}
 
-	it := agent.runner.Run(ctx, agent.messages, adk.WithCheckPointID("session-"+message.RequestId))
+	return agent.consume(ctx, runCtx, cancel, requestId, func(func(response.ChatResponse)) *adk.AsyncIterator[*adk.AgentEvent] {
+		return it
+	}), nil
+}
+
+func checkPointID(requestId string) string {
+	return "session-" + requestId
+}
+
+// begin 登记一次生成,返回可被 Abort 取消的上下文
+// 生成不随 HTTP 请求结束而取消,客户端断开后可以通过 Subscribe 重新获取
+func (agent *ContinuousAgent) begin(ctx context.Context, requestId string) (context.Context, context.CancelFunc) {
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	agent.mu.Lock()
+	agent.cancel = cancel
+	agent.mu.Unlock()
+	registerGeneration(requestId, agent)
+	return runCtx, cancel
+}
+
+func (agent *ContinuousAgent) end(requestId string, cancel context.CancelFunc) {
+	cancel()
+	unregisterGeneration(requestId, agent)
+}
+
+// consume 在后台启动生成,读取智能体事件并写入事件缓存,结束后保存助手消息。
+// run 在开始事件之后调用,可以先发送事件再返回智能体事件,返回的响应流在 ctx 结束时关闭,不影响生成
+func (agent *ContinuousAgent) consume(ctx context.Context, runCtx context.Context, cancel context.CancelFunc, requestId string, run func(send func(response.ChatResponse)) *adk.AsyncIterator[*adk.AgentEvent]) <-chan response.ChatResponse {
+
+	s := newStream(requestId)
 
 	go func() {
+		// 先结束生成再关闭事件流,订阅方收到结束时已可以开始新的生成
+		defer s.close(requestId)
+		defer agent.end(requestId, cancel)
+
+		send := func(res response.ChatResponse) {
+			res.RequestID = requestId
+			s.append(res)
+		}
+
+		send(response.ChatResponse{
+			Event: response.EventStart,
+			Start: &response.Start{Session: agent.session, Model: agent.model.Name},
+		})
+		if agent.budget != nil {
+			send(response.ChatResponse{Event: response.EventBudget, Budget: &response.Budget{
+				Period:   agent.budget.Period,
+				Limit:    agent.budget.Limit,
+				Spent:    agent.budget.Spent,
+				Currency: agent.budget.Currency,
+			}})
+		}
+		it := run(send)
 
-		var outputMessage = &schema.Message{
-			Role:    schema.Assistant,
-			Content: "",
+		started := time.Now()
+		var outputMessage = &Message{
+			Role:      string(schema.Assistant),
+			RequestId: requestId,
+			Model:     agent.model.Name,
 		}
+		var usage response.Usage
+		var interrupted, failed bool
+		var toolCalls int
 		for {
 			event, ok := it.Next()
-			if !ok {
+			if !ok || runCtx.Err() != nil {
 				break
 			}
 
 			if event.Err != nil {
-				ch <- response.ChatResponse{
-					Error:     event.Err,
-					RequestID: message.RequestId,
-				}
-			} else {
+				failed = true
+				send(response.NewError(requestId, response.ErrorGenerationFailed, event.Err))
+				continue
+			}
 
-				if event.Output != nil && event.Output.MessageOutput != nil {
+			if event.Output != nil && event.Output.MessageOutput != nil {
+				mo := event.Output.MessageOutput
+
+				if mo.Role == schema.Tool {
+					if m, err := mo.GetMessage(); err == nil {
+						send(response.ChatResponse{
+							Event: response.EventToolResult,
+							ToolResult: &response.ToolResult{
+								Id:      m.ToolCallID,
+								Name:    mo.ToolName,
+								Content: m.Content,
+							},
+						})
+					}
+				} else {
 
-					if event.Output.MessageOutput.MessageStream != nil {
+					chunks := make([]*schema.Message, 0)
+					delta := func(m *schema.Message) {
+						chunks = append(chunks, m)
+						if outputMessage.FirstTokenLatency == 0 && (m.ReasoningContent != "" || m.Content != "") {
+							outputMessage.FirstTokenLatency = max(time.Since(started).Milliseconds(), 1)
+						}
+						if m.ReasoningContent != "" {
+							outputMessage.ReasoningContent += m.ReasoningContent
+							send(response.ChatResponse{
+								Event:         response.EventReasoningDelta,
+								ReasonContent: m.ReasoningContent,
+							})
+						}
+						if m.Content != "" {
+							outputMessage.Content += m.Content
+							send(response.ChatResponse{
+								Event:   response.EventContentDelta,
+								Content: m.Content,
+							})
+						}
+					}
 
+					if mo.MessageStream != nil {
 						for {
-							m, err := event.Output.MessageOutput.MessageStream.Recv()
-							if err != nil {
+							m, err := mo.MessageStream.Recv()
+							if err == io.EOF {
 								break
 							}
-
-							if m.ReasoningContent != "" {
-								outputMessage.ReasoningContent = m.ReasoningContent
-								ch <- response.ChatResponse{
-									ReasonContent: m.ReasoningContent,
-									RequestID:     message.RequestId,
-								}
-							} else {
-								outputMessage.Content += m.Content
-								ch <- response.ChatResponse{
-									Content:   m.Content,
-									RequestID: message.RequestId,
+							if err != nil {
+								if runCtx.Err() == nil {
+									failed = true
+									send(response.NewError(requestId, response.ErrorGenerationFailed, err))
 								}
+								break
 							}
+							delta(m)
+						}
+					} else if mo.Message != nil {
+						delta(mo.Message)
+					}
+
+					// 工具调用参数是分块返回的,合并后再发送
+					if full, err := ConcatMessages(chunks); err == nil {
+						toolCalls += len(full.ToolCalls)
+						for _, tc := range full.ToolCalls {
+							send(response.ChatResponse{
+								Event: response.EventToolCall,
+								ToolCall: &response.ToolCall{
+									Id:        tc.ID,
+									Name:      tc.Function.Name,
+									Arguments: tc.Function.Arguments,
+								},
+							})
+						}
+						if full.ResponseMeta != nil && full.ResponseMeta.Usage != nil {
+							outputMessage.AddUsage(full.ResponseMeta.Usage)
+							usage.TotalTokens += full.ResponseMeta.Usage.TotalTokens
 						}
 					}
 				}
 			}
+
+			if event.Action != nil && event.Action.Interrupted != nil {
+				for _, ic := range event.Action.Interrupted.InterruptContexts {
+					info, ok := ic.Info.(*ToolConfirmation)
+					if !ok || !ic.IsRootCause {
+						continue
+					}
+					interrupted = true
+					send(response.ChatResponse{
+						Event: response.EventToolConfirmation,
+						ToolConfirmation: &response.ToolConfirmation{
+							InterruptId: ic.ID,
+							Tool:        info.Tool,
+							Arguments:   info.Arguments,
+						},
+					})
+				}
+			}
 		}
 
-		agent.messages = append(agent.messages, outputMessage)
+		status := MessageStatusCompleted
+		if runCtx.Err() != nil {
+			status = MessageStatusAborted
+		} else if failed {
+			status = MessageStatusFailed
+		} else if interrupted {
+			status = MessageStatusInterrupted
+		}
 
-		//TODO 存储会话
+		usage.PromptTokens = outputMessage.PromptTokens
+		usage.CompletionTokens = outputMessage.CompletionTokens
+		usage.ReasoningTokens = outputMessage.ReasoningTokens
+
+		// 在推理或调用工具时中止也保存回复,保留推理过程和已产生的用量
+		if outputMessage.Content != "" || outputMessage.ReasoningContent != "" || toolCalls > 0 || status == MessageStatusCompleted {
+			outputMessage.Status = status
+			outputMessage.Tokens = outputMessage.CompletionTokens
+			outputMessage.Latency = max(time.Since(started).Milliseconds(), outputMessage.FirstTokenLatency)
+			agent.append(outputMessage)
+			if status == MessageStatusCompleted {
+				agent.remember(outputMessage)
+				agent.autoTitle(outputMessage)
+			}
+		}
 
-		MessageAppend(agent.session, &Message{
-			Content:   outputMessage.Content,
-			Role:      "assistant",
-			RequestId: message.RequestId,
+		if usage.TotalTokens > 0 {
+			send(response.ChatResponse{Event: response.EventUsage, Usage: &usage})
+		}
+		send(response.ChatResponse{
+			Event: response.EventDone,
+			Done:  &response.Done{Status: status},
 		})
-		close(ch)
 	}()
 
-	return ch, nil
-
+	return s.subscribe(ctx, s.base-1)
 }
@@ -0,0 +1,139 @@
+package chat
+
+import (
+	"context"
+	"testing"
+
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/AntNoHuabei/Remo/pkg/api/response"
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestChatStreamEvents(t *testing.T) {
+	openTestDB(t)
+	resetStream(t, "req-events")
+	session := CreateSession()
+	agent := newTestAgent(t, toolCallingModel(), session.Id, &config.ToolsConfig{DefaultPolicy: config.ToolPolicyAuto})
+
+	out, err := agent.Chat(context.Background(), &Message{Content: "hi", Role: "user", Session: session.Id, RequestId: "req-events"})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	events := make([]response.ChatResponse, 0)
+	for res := range out {
+		events = append(events, res)
+	}
+
+	for i, res := range events {
+		if res.Index != i {
+			t.Errorf("Expected index %d, got %d", i, res.Index)
+		}
+		if res.RequestID != "req-events" {
+			t.Errorf("Unexpected request id %q", res.RequestID)
+		}
+	}
+
+	names := make([]string, 0, len(events))
+	for _, res := range events {
+		names = append(names, res.Event)
+	}
+	expected := []string{
+		response.EventStart,
+		response.EventToolCall,
+		response.EventToolResult,
+		response.EventContentDelta,
+		response.EventUsage,
+		response.EventDone,
+	}
+	if len(names) != len(expected) {
+		t.Fatalf("Expected events %v, got %v", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Fatalf("Expected events %v, got %v", expected, names)
+		}
+	}
+
+	if start := events[0].Start; start == nil || start.Session != session.Id || start.Model != testModelName {
+		t.Errorf("Unexpected start event: %+v", start)
+	}
+	if usage := events[4].Usage; usage == nil || usage.TotalTokens != 30 || usage.PromptTokens != 20 {
+		t.Errorf("Expected usage summed over both model calls, got %+v", usage)
+	}
+	if done := events[5].Done; done == nil || done.Status != MessageStatusCompleted {
+		t.Errorf("Unexpected done event: %+v", done)
+	}
+}
+
+func TestChatSavesReplyDetails(t *testing.T) {
+	openTestDB(t)
+	agent := newTestAgent(t, reasoningModel(), "", nil)
+
+	events := collectEvents(mustChat(t, agent, "hi"))
+	if usage := events[len(events)-2].Usage; usage == nil || usage.ReasoningTokens != 5 || usage.TotalTokens != 20 {
+		t.Errorf("Unexpected usage event %+v", usage)
+	}
+
+	messages, _ := Messages(agent.session)
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(messages))
+	}
+	user, reply := messages[0], messages[1]
+	if user.Model != testModelName || user.CreatedTime == 0 {
+		t.Errorf("Expected the user message to record the model and time, got %+v", user)
+	}
+	if reply.Content != "Hello!" || reply.ReasoningContent != "Let me think." {
+		t.Errorf("Expected the full reply and reasoning, got %q and %q", reply.Content, reply.ReasoningContent)
+	}
+	if reply.PromptTokens != 12 || reply.CompletionTokens != 8 || reply.ReasoningTokens != 5 || reply.Tokens != 8 {
+		t.Errorf("Unexpected token usage %+v", reply)
+	}
+	if reply.FirstTokenLatency <= 0 || reply.Latency < reply.FirstTokenLatency-1 {
+		t.Errorf("Unexpected latency: first token %d, total %d", reply.FirstTokenLatency, reply.Latency)
+	}
+	if reply.Model != testModelName || reply.CreatedTime == 0 || reply.Status != MessageStatusCompleted {
+		t.Errorf("Unexpected reply %+v", reply)
+	}
+}
+
+func TestAbortDuringToolCallSavesReply(t *testing.T) {
+	openTestDB(t)
+	release := make(chan struct{})
+	cm := &fakeModel{reply: func(input []*schema.Message, call int) (*schema.Message, error) {
+		if call > 1 {
+			// 拿到工具结果后等待中止
+			<-release
+			return nil, context.Canceled
+		}
+		msg := schema.AssistantMessage("", []schema.ToolCall{{
+			ID:       "call-1",
+			Function: schema.FunctionCall{Name: "echo", Arguments: `{"text":"hi"}`},
+		}})
+		msg.ReasoningContent = "Need the tool."
+		return msg, nil
+	}}
+	agent := newTestAgent(t, cm, "", &config.ToolsConfig{DefaultPolicy: config.ToolPolicyAuto})
+
+	var status string
+	for res := range mustChat(t, agent, "hi") {
+		if res.Event == response.EventToolResult {
+			agent.Abort()
+			close(release)
+		}
+		if res.Done != nil {
+			status = res.Done.Status
+		}
+	}
+	if status != MessageStatusAborted {
+		t.Fatalf("Expected the generation to be aborted, got %q", status)
+	}
+
+	messages, _ := Messages(agent.session)
+	if len(messages) != 2 {
+		t.Fatalf("Expected the aborted reply to be saved, got %d messages", len(messages))
+	}
+	if reply := messages[1]; reply.Status != MessageStatusAborted || reply.ReasoningContent != "Need the tool." {
+		t.Errorf("Unexpected reply %+v", reply)
+	}
+}
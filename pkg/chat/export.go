@@ -0,0 +1,327 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// 导出格式
+const (
+	ExportMarkdown = "markdown" // 当前分支的对话记录
+	ExportHTML     = "html"     // 当前分支的对话记录,图片嵌入页面
+	ExportJSON     = "json"     // Bundle,包含全部分支、摘要和附件,可以再导入
+)
+
+const (
+	// bundleFormat Bundle 的 format 字段,用于识别导入的文件
+	bundleFormat = "remo"
+	// bundleVersion 当前的 Bundle 版本,导入时拒绝更新的版本
+	bundleVersion = 1
+)
+
+// Bundle 无损导出的会话数据
+type Bundle struct {
+	Format     string           `json:"format"`
+	Version    int              `json:"version"`
+	ExportedAt int64            `json:"exported_at"` // 毫秒时间戳
+	Sessions   []*BundleSession `json:"sessions"`
+}
+
+// BundleSession 会话及其全部消息和附件
+type BundleSession struct {
+	Session *Session `json:"session"`
+	// Messages 全部分支上的消息和摘要,按顺序排列
+	Messages    []*Message          `json:"messages"`
+	Attachments []*BundleAttachment `json:"attachments"`
+}
+
+// BundleAttachment 附件及其内容,Data 在 json 中为 base64
+type BundleAttachment struct {
+	Id       string `json:"id"`
+	Message  string `json:"message"` // 第一次引用附件的消息
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	MimeType string `json:"mime_type"`
+	Data     []byte `json:"data"`
+}
+
+// ExportFile 导出的文件
+type ExportFile struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Export 按格式导出会话,ids 为空时导出回收站以外的全部会话
+func Export(ids []string, format string) (*ExportFile, error) {
+	bundle, err := ExportBundle(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	name := "remo-" + time.Now().Format("20060102-150405")
+	switch format {
+	case ExportMarkdown:
+		return &ExportFile{Name: name + ".md", ContentType: "text/markdown; charset=utf-8", Data: []byte(bundle.Markdown())}, nil
+	case ExportHTML:
+		data, err := bundle.HTML()
+		if err != nil {
+			return nil, err
+		}
+		return &ExportFile{Name: name + ".html", ContentType: "text/html; charset=utf-8", Data: data}, nil
+	case ExportJSON:
+		data, err := json.MarshalIndent(bundle, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return &ExportFile{Name: name + ".json", ContentType: "application/json", Data: data}, nil
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+// ExportBundle 导出会话的全部消息和附件,ids 为空时按创建时间导出回收站以外的全部会话
+func ExportBundle(ids []string) (*Bundle, error) {
+	var sessions []*Session
+	if len(ids) == 0 {
+		all, err := repo().ListSessions()
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range all {
+			if s.DeletedAt == 0 {
+				sessions = append(sessions, s)
+			}
+		}
+		sort.SliceStable(sessions, func(i, j int) bool {
+			return sessions[i].CreatedAt < sessions[j].CreatedAt
+		})
+	} else {
+		for _, id := range ids {
+			s, err := GetSession(id)
+			if err != nil {
+				return nil, err
+			}
+			sessions = append(sessions, s)
+		}
+	}
+
+	bundle := &Bundle{Format: bundleFormat, Version: bundleVersion, ExportedAt: time.Now().UnixMilli(), Sessions: make([]*BundleSession, 0, len(sessions))}
+	for _, s := range sessions {
+		messages, err := Messages(s.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		bs := &BundleSession{Session: s, Messages: messages, Attachments: make([]*BundleAttachment, 0)}
+		exported := make(map[string]bool)
+		for _, m := range messages {
+			for _, a := range m.Attachments {
+				if exported[a.Id] {
+					continue
+				}
+				exported[a.Id] = true
+				stored, err := GetAttachment(a.Id)
+				if err != nil {
+					return nil, err
+				}
+				bs.Attachments = append(bs.Attachments, &BundleAttachment{
+					Id:       stored.Id,
+					Message:  m.Id,
+					Kind:     stored.Kind,
+					Name:     stored.Name,
+					MimeType: stored.MimeType,
+					Data:     stored.Data,
+				})
+			}
+		}
+		bundle.Sessions = append(bundle.Sessions, bs)
+	}
+	return bundle, nil
+}
+
+// thread 返回会话当前分支上的消息,不包括摘要
+func (bs *BundleSession) thread() []*Message {
+	output := make([]*Message, 0, len(bs.Messages))
+	for _, m := range newThread(bs.Messages, leafOf(bs.Session, bs.Messages)).Messages {
+		if m.Type != MessageTypeSummary {
+			output = append(output, m)
+		}
+	}
+	return output
+}
+
+func (bs *BundleSession) attachment(id string) *BundleAttachment {
+	for _, a := range bs.Attachments {
+		if a.Id == id {
+			return a
+		}
+	}
+	return nil
+}
+
+// roleLabel 导出的对话记录中显示的角色名称
+func roleLabel(role string) string {
+	switch schema.RoleType(role) {
+	case schema.User:
+		return "用户"
+	case schema.Assistant:
+		return "助手"
+	case schema.System:
+		return "系统"
+	case schema.Tool:
+		return "工具"
+	}
+	return role
+}
+
+func formatTime(ms int64) string {
+	return time.UnixMilli(ms).Format("2006-01-02 15:04")
+}
+
+// messageMeta 消息的角色、时间和模型
+func messageMeta(m *Message) string {
+	meta := roleLabel(m.Role) + " · " + formatTime(m.CreatedTime)
+	if m.Role == string(schema.Assistant) && m.Model != "" {
+		meta += " · " + m.Model
+	}
+	return meta
+}
+
+// Markdown 将每个会话当前分支上的消息渲染为 Markdown,附件只写出名称
+func (b *Bundle) Markdown() string {
+	var sb strings.Builder
+	for i, bs := range b.Sessions {
+		if i > 0 {
+			sb.WriteString("\n---\n\n")
+		}
+		fmt.Fprintf(&sb, "# %s\n\n", bs.Session.Title)
+		fmt.Fprintf(&sb, "创建时间: %s\n\n", formatTime(bs.Session.CreatedAt))
+
+		for _, m := range bs.thread() {
+			fmt.Fprintf(&sb, "## %s\n\n", messageMeta(m))
+			if m.ReasoningContent != "" {
+				fmt.Fprintf(&sb, "<details>\n<summary>推理过程</summary>\n\n%s\n\n</details>\n\n", strings.TrimSpace(m.ReasoningContent))
+			}
+			if content := strings.TrimSpace(m.Content); content != "" {
+				sb.WriteString(content + "\n\n")
+			}
+			if notes := attachmentNotes(m); notes != "" {
+				sb.WriteString(notes + "\n\n")
+			}
+		}
+	}
+	return sb.String()
+}
+
+var htmlTemplate = template.Must(template.New("export").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { max-width: 860px; margin: 0 auto; padding: 24px; font-family: -apple-system, "Segoe UI", "Microsoft YaHei", sans-serif; line-height: 1.6; color: #222; }
+section + section { border-top: 1px solid #ddd; margin-top: 32px; }
+.message { margin: 16px 0; padding: 12px 16px; border-radius: 8px; background: #f6f7f9; }
+.message.user { background: #e8f0fe; }
+.meta { font-size: 12px; color: #888; margin-bottom: 4px; }
+.content { white-space: pre-wrap; word-wrap: break-word; }
+details { color: #666; margin-bottom: 8px; }
+img { max-width: 100%; border-radius: 4px; margin-top: 8px; display: block; }
+</style>
+</head>
+<body>
+{{- range .Sessions}}
+<section>
+<h1>{{.Title}}</h1>
+<p class="meta">创建时间: {{.Created}}</p>
+{{- range .Messages}}
+<div class="message {{.Role}}">
+<div class="meta">{{.Meta}}</div>
+{{- if .Reasoning}}
+<details><summary>推理过程</summary><div class="content">{{.Reasoning}}</div></details>
+{{- end}}
+<div class="content">{{.Content}}</div>
+{{- range .Images}}
+<img src="{{.URL}}" alt="{{.Name}}">
+{{- end}}
+{{- range .Files}}
+<div><a href="{{.URL}}" download="{{.Name}}">{{.Name}}</a></div>
+{{- end}}
+</div>
+{{- end}}
+</section>
+{{- end}}
+</body>
+</html>
+`))
+
+type htmlFile struct {
+	Name string
+	URL  template.URL
+}
+
+type htmlMessage struct {
+	Role      string
+	Meta      string
+	Reasoning string
+	Content   string
+	Images    []htmlFile
+	Files     []htmlFile
+}
+
+type htmlSession struct {
+	Title    string
+	Created  string
+	Messages []htmlMessage
+}
+
+// HTML 将每个会话当前分支上的消息渲染为独立的 HTML 页面,附件以 data URL 嵌入
+func (b *Bundle) HTML() ([]byte, error) {
+	page := struct {
+		Title    string
+		Sessions []htmlSession
+	}{Title: "Remo"}
+	if len(b.Sessions) == 1 {
+		page.Title = b.Sessions[0].Session.Title
+	}
+
+	for _, bs := range b.Sessions {
+		session := htmlSession{Title: bs.Session.Title, Created: formatTime(bs.Session.CreatedAt)}
+		for _, m := range bs.thread() {
+			message := htmlMessage{Role: m.Role, Meta: messageMeta(m), Reasoning: strings.TrimSpace(m.ReasoningContent), Content: strings.TrimSpace(m.Content)}
+			for _, a := range m.Attachments {
+				stored := bs.attachment(a.Id)
+				if stored == nil {
+					continue
+				}
+				// 只有图片使用原 MIME 类型,避免导入的数据中的类型被当作页面执行
+				if (&Attachment{MimeType: stored.MimeType}).isImage() && stored.MimeType != "image/svg+xml" {
+					message.Images = append(message.Images, htmlFile{Name: stored.Name, URL: dataURL(stored.MimeType, stored.Data)})
+				} else {
+					message.Files = append(message.Files, htmlFile{Name: stored.Name, URL: dataURL("application/octet-stream", stored.Data)})
+				}
+			}
+			session.Messages = append(session.Messages, message)
+		}
+		page.Sessions = append(page.Sessions, session)
+	}
+
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, page); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func dataURL(mimeType string, data []byte) template.URL {
+	return template.URL("data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data))
+}
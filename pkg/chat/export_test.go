@@ -0,0 +1,166 @@
+package chat
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// newExportSession 创建有附件、推理过程和两个版本的会话,当前分支为 "look again|re 2"
+func newExportSession(t *testing.T) (*Session, *Attachment) {
+	s := CreateSession()
+	RenameSession(s.Id, "Cats <3")
+	image, _ := NewAttachment(AttachmentImage, "cat.png", "", pngHeader)
+	first := &Message{Role: "user", Content: "look", Attachments: []*Attachment{image}}
+	MessageAppend(s.Id, first)
+	MessageAppend(s.Id, &Message{Role: "assistant", Content: "re 1", ReasoningContent: "thinking", Model: "m1"})
+	edited := &Message{Role: "user", Content: "look again", ParentId: first.ParentId, Attachments: first.Attachments}
+	if err := saveMessage(s.Id, edited); err != nil {
+		t.Fatalf("saveMessage failed: %v", err)
+	}
+	MessageAppend(s.Id, &Message{Role: "assistant", Content: "re 2 <script>"})
+	if got := threadContents(t, s.Id); got != "look again|re 2 <script>" {
+		t.Fatalf("Unexpected thread %q", got)
+	}
+	return s, image
+}
+
+func TestExportFormats(t *testing.T) {
+	openTestDB(t)
+	s, _ := newExportSession(t)
+
+	file, err := Export([]string{s.Id}, ExportMarkdown)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	md := string(file.Data)
+	if !strings.HasSuffix(file.Name, ".md") || !strings.Contains(md, "# Cats <3") || !strings.Contains(md, "[image: cat.png]") {
+		t.Errorf("Unexpected markdown %s:\n%s", file.Name, md)
+	}
+	// 只导出当前分支
+	if strings.Contains(md, "re 1") || !strings.Contains(md, "look again") {
+		t.Errorf("Expected only the current branch, got:\n%s", md)
+	}
+
+	file, err = Export([]string{s.Id}, ExportHTML)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	page := string(file.Data)
+	if strings.Contains(page, "<script>") || !strings.Contains(page, "re 2 &lt;script&gt;") {
+		t.Errorf("Expected message content to be escaped, got:\n%s", page)
+	}
+	if !strings.Contains(page, `src="data:image/png;base64,`) {
+		t.Errorf("Expected the image to be embedded, got:\n%s", page)
+	}
+
+	if _, err := Export([]string{s.Id}, "pdf"); err == nil {
+		t.Error("Expected an error for an unsupported format")
+	}
+}
+
+func TestExportImportBundle(t *testing.T) {
+	openTestDB(t)
+	s, image := newExportSession(t)
+	other := CreateSession()
+	DeleteSession(other.Id)
+
+	// ids 为空时不导出回收站中的会话
+	file, err := Export(nil, ExportJSON)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	var bundle Bundle
+	if err := json.Unmarshal(file.Data, &bundle); err != nil {
+		t.Fatalf("Invalid bundle: %v", err)
+	}
+	if len(bundle.Sessions) != 1 || len(bundle.Sessions[0].Messages) != 4 || len(bundle.Sessions[0].Attachments) != 1 {
+		t.Fatalf("Unexpected bundle %+v", bundle.Sessions)
+	}
+
+	if err := PurgeSession(s.Id); err != nil {
+		t.Fatalf("PurgeSession failed: %v", err)
+	}
+	result, err := Import(file.Data)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if result.Sessions != 1 || result.Messages != 4 || result.Attachments != 1 || result.Skipped != 0 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if got := threadContents(t, s.Id); got != "look again|re 2 <script>" {
+		t.Errorf("Expected the current branch to be restored, got %q", got)
+	}
+	messages, _ := Messages(s.Id)
+	if messages[1].ReasoningContent != "thinking" || messages[1].Model != "m1" {
+		t.Errorf("Expected message details to be restored, got %+v", messages[1])
+	}
+	if a, err := GetAttachment(image.Id); err != nil || string(a.Data) != string(pngHeader) {
+		t.Errorf("Expected the attachment to be restored, got %v", err)
+	}
+
+	// 再次导入时按消息 ID 跳过
+	result, err = Import(file.Data)
+	if err != nil || result.Sessions != 0 || result.Messages != 0 || result.Skipped != 4 {
+		t.Errorf("Expected all messages to be skipped, got %+v %v", result, err)
+	}
+
+	if _, err := Import([]byte(`{"format":"other"}`)); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+	if _, err := Import([]byte(`{"format":"remo","version":99}`)); err == nil {
+		t.Error("Expected an error for a newer version")
+	}
+}
+
+const chatGPTExport = `[{
+	"title": "Go question",
+	"create_time": 1700000000.5,
+	"update_time": 1700000100,
+	"conversation_id": "conv-1",
+	"current_node": "a2",
+	"mapping": {
+		"client-created-root": {"id": "client-created-root", "message": null, "parent": null, "children": ["sys"]},
+		"sys": {"id": "sys", "parent": "client-created-root", "message": {"id": "sys", "author": {"role": "system"}, "content": {"content_type": "text", "parts": [""]}, "metadata": {"is_visually_hidden_from_conversation": true}}},
+		"u1": {"id": "u1", "parent": "sys", "message": {"id": "u1", "author": {"role": "user"}, "create_time": 1700000001, "content": {"content_type": "text", "parts": ["What is a goroutine?"]}, "metadata": {}}},
+		"a1": {"id": "a1", "parent": "u1", "message": {"id": "a1", "author": {"role": "assistant"}, "create_time": 1700000002, "content": {"content_type": "text", "parts": ["A thread."]}, "metadata": {"model_slug": "gpt-4o"}}},
+		"a2": {"id": "a2", "parent": "u1", "message": {"id": "a2", "author": {"role": "assistant"}, "create_time": 1700000003, "content": {"content_type": "text", "parts": ["A lightweight thread."]}, "metadata": {"model_slug": "gpt-4o"}}}
+	}
+}]`
+
+func TestImportChatGPT(t *testing.T) {
+	openTestDB(t)
+
+	result, err := Import([]byte(chatGPTExport))
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if result.Sessions != 1 || result.Messages != 3 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+
+	id := importId("conv-1")
+	s, err := GetSession(id)
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if s.Title != "Go question" || s.CreatedAt != 1700000000500 || s.LastMessagePreview != "A lightweight thread." {
+		t.Errorf("Unexpected session %+v", s)
+	}
+	// 两个回复为同一问题的两个版本,当前分支为 current_node
+	thread, _ := CurrentThread(id)
+	if got := threadContents(t, id); got != "What is a goroutine?|A lightweight thread." {
+		t.Errorf("Unexpected thread %q", got)
+	}
+	if versions := thread.Branches[importId("a2")]; len(versions) != 2 {
+		t.Errorf("Expected two versions of the reply, got %v", thread.Branches)
+	}
+	if thread.Messages[1].Model != "gpt-4o" {
+		t.Errorf("Expected the model to be kept, got %q", thread.Messages[1].Model)
+	}
+
+	result, err = Import([]byte(chatGPTExport))
+	if err != nil || result.Messages != 0 || result.Skipped != 3 {
+		t.Errorf("Expected all messages to be skipped, got %+v %v", result, err)
+	}
+}
@@ -0,0 +1,27 @@
+package chat
+
+import (
+	"fmt"
+	"sync"
+)
+
+// generations 正在进行的生成,request_id -> *ContinuousAgent
+var generations sync.Map
+
+func registerGeneration(requestId string, agent *ContinuousAgent) {
+	generations.Store(requestId, agent)
+}
+
+func unregisterGeneration(requestId string, agent *ContinuousAgent) {
+	generations.CompareAndDelete(requestId, agent)
+}
+
+// Abort 取消 request_id 对应的生成
+func Abort(requestId string) error {
+	v, ok := generations.Load(requestId)
+	if !ok {
+		return fmt.Errorf("generation not found: %s", requestId)
+	}
+	v.(*ContinuousAgent).Abort()
+	return nil
+}
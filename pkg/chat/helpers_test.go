@@ -0,0 +1,229 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/AntNoHuabei/Remo/pkg/api/response"
+	"github.com/AntNoHuabei/Remo/pkg/provider"
+	"github.com/cloudwego/eino/adk"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/schema"
+)
+
+// testModelName newTestAgent 创建的智能体使用的模型名称
+const testModelName = "test/model"
+
+// fakeModel 测试用的模型,记录每次调用收到的消息。
+// reply 返回第 call 次调用的回复;chunks 不为空时流式调用按块返回 chunks
+type fakeModel struct {
+	mu     sync.Mutex
+	reply  func(input []*schema.Message, call int) (*schema.Message, error)
+	chunks []*schema.Message
+	inputs [][]*schema.Message
+}
+
+func (m *fakeModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inputs = append(m.inputs, input)
+	if m.reply == nil {
+		return nil, errors.New("not supported")
+	}
+	return m.reply(input, len(m.inputs))
+}
+
+func (m *fakeModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	if m.chunks != nil {
+		m.mu.Lock()
+		m.inputs = append(m.inputs, input)
+		m.mu.Unlock()
+		return schema.StreamReaderFromArray(m.chunks), nil
+	}
+	msg, err := m.Generate(ctx, input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return schema.StreamReaderFromArray([]*schema.Message{msg}), nil
+}
+
+func (m *fakeModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+// lastInput 最后一次调用收到的消息
+func (m *fakeModel) lastInput() []*schema.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.inputs[len(m.inputs)-1]
+}
+
+// lastContents 最后一次调用收到的非系统消息内容,以 | 连接
+func (m *fakeModel) lastContents() string {
+	contents := make([]string, 0)
+	for _, msg := range m.lastInput() {
+		if msg.Role != schema.System {
+			contents = append(contents, msg.Content)
+		}
+	}
+	return strings.Join(contents, "|")
+}
+
+// replyModel 总是回复 text,err 不为空时返回错误
+func replyModel(text string, err error) *fakeModel {
+	return &fakeModel{reply: func(input []*schema.Message, call int) (*schema.Message, error) {
+		if err != nil {
+			return nil, err
+		}
+		return schema.AssistantMessage(text, nil), nil
+	}}
+}
+
+// echoModel 回复最后一条消息和调用次数,如 "re a #1"
+func echoModel() *fakeModel {
+	return &fakeModel{reply: func(input []*schema.Message, call int) (*schema.Message, error) {
+		return schema.AssistantMessage(fmt.Sprintf("re %s #%d", input[len(input)-1].Content, call), nil), nil
+	}}
+}
+
+// toolCallingModel 第一轮调用 echo 工具,拿到工具结果后原样回复,每次调用用量为 15 token
+func toolCallingModel() *fakeModel {
+	return &fakeModel{reply: func(input []*schema.Message, call int) (*schema.Message, error) {
+		var msg *schema.Message
+		last := input[len(input)-1]
+		if last.Role == schema.Tool {
+			msg = schema.AssistantMessage("result: "+last.Content, nil)
+		} else {
+			msg = schema.AssistantMessage("", []schema.ToolCall{{
+				ID:       "call-1",
+				Function: schema.FunctionCall{Name: "echo", Arguments: `{"text":"hi"}`},
+			}})
+		}
+		msg.ResponseMeta = &schema.ResponseMeta{
+			Usage: &schema.TokenUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+		}
+		return msg, nil
+	}}
+}
+
+// reasoningModel 分块返回推理过程和回复,最后一块带有 token 用量
+func reasoningModel() *fakeModel {
+	last := schema.AssistantMessage("!", nil)
+	last.ResponseMeta = &schema.ResponseMeta{Usage: &schema.TokenUsage{
+		PromptTokens:            12,
+		CompletionTokens:        8,
+		TotalTokens:             20,
+		CompletionTokensDetails: schema.CompletionTokensDetails{ReasoningTokens: 5},
+	}}
+	return &fakeModel{chunks: []*schema.Message{
+		{Role: schema.Assistant, ReasoningContent: "Let me "},
+		{Role: schema.Assistant, ReasoningContent: "think."},
+		schema.AssistantMessage("Hello", nil),
+		last,
+	}}
+}
+
+type echoTool struct{}
+
+func (echoTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{Name: "echo", Desc: "echo the arguments"}, nil
+}
+
+func (echoTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	return "echo " + argumentsInJSON, nil
+}
+
+// newTestAgent 创建使用 cm 的智能体并加载会话的消息,session 为空时新建会话。
+// tools 不为空时按其中的策略提供 echo 工具
+func newTestAgent(t *testing.T, cm model.ToolCallingChatModel, session string, tools *config.ToolsConfig) *ContinuousAgent {
+	ctx := context.Background()
+	if session == "" {
+		session = CreateSession().Id
+	}
+
+	cfg := &adk.ChatModelAgentConfig{Name: "TestAgent", Description: "test", Model: cm}
+	if tools != nil {
+		registeredTools = []tool.InvokableTool{echoTool{}}
+		t.Cleanup(func() {
+			registeredTools = nil
+		})
+		agentTools, err := agentTools(ctx, *tools)
+		if err != nil {
+			t.Fatalf("Failed to build tools: %v", err)
+		}
+		cfg.ToolsConfig = adk.ToolsConfig{ToolsNodeConfig: compose.ToolsNodeConfig{Tools: agentTools}}
+	}
+	a, err := adk.NewChatModelAgent(ctx, cfg)
+	if err != nil {
+		t.Fatalf("Failed to create agent: %v", err)
+	}
+	store, _ := NewStore(session)
+	agent := &ContinuousAgent{
+		agent:     a,
+		chatModel: cm,
+		model:     &provider.Model{Name: testModelName},
+		runner:    adk.NewRunner(ctx, adk.RunnerConfig{Agent: a, EnableStreaming: true, CheckPointStore: store}),
+	}
+	if err := loadSession(agent, session); err != nil {
+		t.Fatalf("Failed to load session: %v", err)
+	}
+	return agent
+}
+
+// loadSession 与 Recover 相同,但不重新构建智能体
+func loadSession(agent *ContinuousAgent, session string) error {
+	leaf, err := currentLeaf(session)
+	if err != nil {
+		return err
+	}
+	agent.session = session
+	return agent.checkout(leaf)
+}
+
+// mustChat 在智能体的会话中发送一条用户消息
+func mustChat(t *testing.T, agent *ContinuousAgent, content string, attachments ...*Attachment) <-chan response.ChatResponse {
+	out, err := agent.Chat(context.Background(), &Message{Role: "user", Content: content, Session: agent.session, Attachments: attachments})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	return out
+}
+
+type collected struct {
+	content       string
+	confirmations []*response.ToolConfirmation
+	toolCalls     []*response.ToolCall
+	toolResults   []*response.ToolResult
+}
+
+func collect(ch <-chan response.ChatResponse) *collected {
+	c := &collected{}
+	for res := range ch {
+		c.content += res.Content
+		if res.ToolConfirmation != nil {
+			c.confirmations = append(c.confirmations, res.ToolConfirmation)
+		}
+		if res.ToolCall != nil {
+			c.toolCalls = append(c.toolCalls, res.ToolCall)
+		}
+		if res.ToolResult != nil {
+			c.toolResults = append(c.toolResults, res.ToolResult)
+		}
+	}
+	return c
+}
+
+func collectEvents(ch <-chan response.ChatResponse) []response.ChatResponse {
+	events := make([]response.ChatResponse, 0)
+	for res := range ch {
+		events = append(events, res)
+	}
+	return events
+}
@@ -0,0 +1,316 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/google/uuid"
+)
+
+// importNamespace 将非 uuid 的 ID 转换为 uuid 时使用的命名空间,同一 ID 总是得到同一 uuid
+var importNamespace = uuid.MustParse("6f0f3a52-8d0e-4b6c-9a57-3f1c2e6d8b41")
+
+// ImportResult 导入的数据量
+type ImportResult struct {
+	Sessions    int `json:"sessions"` // 新建的会话数,已存在的会话只补充缺少的消息
+	Messages    int `json:"messages"`
+	Attachments int `json:"attachments"`
+	Skipped     int `json:"skipped"` // 已存在而跳过的消息数
+}
+
+// Import 导入 ExportJSON 格式的 Bundle 或 ChatGPT 导出的 conversations.json,按消息 ID 去重,可以重复导入
+func Import(data []byte) (*ImportResult, error) {
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(data, []byte("[")):
+		var conversations []*chatGPTConversation
+		if err := json.Unmarshal(data, &conversations); err != nil {
+			return nil, fmt.Errorf("invalid ChatGPT export: %w", err)
+		}
+		return ImportBundle(chatGPTBundle(conversations))
+	case bytes.HasPrefix(data, []byte("{")):
+		var bundle Bundle
+		if err := json.Unmarshal(data, &bundle); err != nil {
+			return nil, fmt.Errorf("invalid export file: %w", err)
+		}
+		return ImportBundle(&bundle)
+	default:
+		return nil, errors.New("unsupported import format")
+	}
+}
+
+// ImportBundle 导入 Bundle 中的会话,已存在的消息和附件跳过
+func ImportBundle(bundle *Bundle) (*ImportResult, error) {
+	if bundle.Format != bundleFormat {
+		return nil, errors.New("unsupported import format")
+	}
+	if bundle.Version > bundleVersion {
+		return nil, fmt.Errorf("export version %d is newer than supported version %d", bundle.Version, bundleVersion)
+	}
+
+	result := &ImportResult{}
+	for _, bs := range bundle.Sessions {
+		if bs.Session == nil || bs.Session.Id == "" {
+			return result, errors.New("session is missing in the export file")
+		}
+		if err := importSession(bs, result); err != nil {
+			return result, fmt.Errorf("failed to import session %s: %w", bs.Session.Id, err)
+		}
+	}
+	return result, nil
+}
+
+// importId clover 要求文档 ID 为 uuid,其他来源的 ID 转换为确定的 uuid
+func importId(id string) string {
+	if id == "" {
+		return ""
+	}
+	if _, err := uuid.Parse(id); err == nil {
+		return id
+	}
+	return uuid.NewSHA1(importNamespace, []byte(id)).String()
+}
+
+// importSession 会话不存在时先保存会话,再按顺序追加缺少的消息,消息序号接在已有的消息之后
+func importSession(bs *BundleSession, result *ImportResult) error {
+	session := *bs.Session
+	session.Id = importId(session.Id)
+	session.CurrentMessage = importId(session.CurrentMessage)
+
+	sessionMu.Lock()
+	existing, err := repo().GetSession(session.Id)
+	if err == nil && existing == nil {
+		err = repo().InsertSession(&session)
+		result.Sessions++
+	}
+	sessionMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	stored, err := repo().Messages(session.Id)
+	if err != nil {
+		return err
+	}
+	exists := make(map[string]bool, len(stored))
+	for _, m := range stored {
+		exists[m.Id] = true
+	}
+	attachments := make(map[string]*BundleAttachment, len(bs.Attachments))
+	for _, a := range bs.Attachments {
+		attachments[importId(a.Id)] = a
+	}
+
+	messages := make([]*Message, 0, len(bs.Messages))
+	for _, m := range bs.Messages {
+		message := *m
+		message.Id = importId(m.Id)
+		message.Session = session.Id
+		message.ParentId = importId(m.ParentId)
+		message.SummaryUntil = importId(m.SummaryUntil)
+		message.Attachments = make([]*Attachment, 0, len(m.Attachments))
+		for _, a := range m.Attachments {
+			copied := *a
+			copied.Id = importId(a.Id)
+			copied.Data = nil
+			message.Attachments = append(message.Attachments, &copied)
+		}
+		messages = append(messages, &message)
+	}
+	sortMessages(messages)
+
+	for _, m := range messages {
+		if exists[m.Id] {
+			result.Skipped++
+			continue
+		}
+		if err := repo().AppendMessage(m); err != nil {
+			return err
+		}
+		exists[m.Id] = true
+		result.Messages++
+
+		for _, a := range m.Attachments {
+			blob := attachments[a.Id]
+			if blob == nil {
+				continue
+			}
+			if saved, err := repo().GetAttachment(a.Id); err != nil || saved != nil {
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			err := repo().InsertAttachment(session.Id, m.Id, &Attachment{
+				Id:       a.Id,
+				Kind:     blob.Kind,
+				Name:     blob.Name,
+				MimeType: blob.MimeType,
+				Size:     len(blob.Data),
+				Data:     blob.Data,
+			})
+			if err != nil {
+				return err
+			}
+			result.Attachments++
+		}
+	}
+	return nil
+}
+
+// chatGPTConversation ChatGPT 导出的 conversations.json 中的一个对话,
+// 消息以树的形式保存在 mapping 中,current_node 为当前分支的最后一个节点
+type chatGPTConversation struct {
+	Id             string                  `json:"id"`
+	ConversationId string                  `json:"conversation_id"`
+	Title          string                  `json:"title"`
+	CreateTime     float64                 `json:"create_time"` // 秒
+	UpdateTime     float64                 `json:"update_time"`
+	CurrentNode    string                  `json:"current_node"`
+	Mapping        map[string]*chatGPTNode `json:"mapping"`
+}
+
+type chatGPTNode struct {
+	Id      string          `json:"id"`
+	Parent  string          `json:"parent"`
+	Message *chatGPTMessage `json:"message"`
+}
+
+type chatGPTMessage struct {
+	Id     string `json:"id"`
+	Author struct {
+		Role string `json:"role"`
+	} `json:"author"`
+	CreateTime float64 `json:"create_time"`
+	Content    struct {
+		ContentType string `json:"content_type"`
+		Parts       []any  `json:"parts"`
+	} `json:"content"`
+	Metadata struct {
+		ModelSlug string `json:"model_slug"`
+		Hidden    bool   `json:"is_visually_hidden_from_conversation"`
+	} `json:"metadata"`
+}
+
+// text 返回用户和助手消息中的文本,其他消息如系统提示词、工具调用和图片返回空
+func (m *chatGPTMessage) text() string {
+	if m == nil || m.Metadata.Hidden {
+		return ""
+	}
+	if role := schema.RoleType(m.Author.Role); role != schema.User && role != schema.Assistant {
+		return ""
+	}
+	if m.Content.ContentType != "text" && m.Content.ContentType != "multimodal_text" {
+		return ""
+	}
+	parts := make([]string, 0, len(m.Content.Parts))
+	for _, p := range m.Content.Parts {
+		if s, ok := p.(string); ok && strings.TrimSpace(s) != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// chatGPTBundle 将 ChatGPT 的对话转换为 Bundle,跳过的节点的子消息接到最近的保留下来的祖先节点上
+func chatGPTBundle(conversations []*chatGPTConversation) *Bundle {
+	bundle := &Bundle{Format: bundleFormat, Version: bundleVersion, Sessions: make([]*BundleSession, 0, len(conversations))}
+	for _, c := range conversations {
+		id := c.ConversationId
+		if id == "" {
+			id = c.Id
+		}
+		if id == "" || len(c.Mapping) == 0 {
+			continue
+		}
+
+		created := int64(c.CreateTime * 1000)
+		nodes := make([]string, 0, len(c.Mapping))
+		for node := range c.Mapping {
+			nodes = append(nodes, node)
+		}
+		sort.Strings(nodes)
+
+		messages := make(map[string]*Message)
+		for _, node := range nodes {
+			n := c.Mapping[node]
+			if n == nil {
+				continue
+			}
+			m := n.Message
+			text := m.text()
+			if text == "" {
+				continue
+			}
+			message := &Message{
+				Id:          m.Id,
+				Session:     id,
+				Role:        m.Author.Role,
+				Content:     text,
+				Status:      MessageStatusCompleted,
+				Tokens:      EstimateTokens(text),
+				CreatedTime: int64(m.CreateTime * 1000),
+			}
+			if message.Id == "" {
+				message.Id = node
+			}
+			if message.CreatedTime == 0 {
+				message.CreatedTime = created
+			}
+			if message.Role == string(schema.Assistant) {
+				message.Model = m.Metadata.ModelSlug
+			}
+			messages[node] = message
+		}
+
+		// nearest 返回节点自身或最近的保留下来的祖先节点对应的消息
+		nearest := func(node string) *Message {
+			for i := 0; node != "" && i <= len(c.Mapping); i++ {
+				if m := messages[node]; m != nil {
+					return m
+				}
+				n := c.Mapping[node]
+				if n == nil {
+					break
+				}
+				node = n.Parent
+			}
+			return nil
+		}
+
+		bs := &BundleSession{
+			Session: &Session{
+				Id:        id,
+				Title:     c.Title,
+				CreatedAt: created,
+				UpdatedAt: int64(c.UpdateTime * 1000),
+			},
+			Messages:    make([]*Message, 0, len(messages)),
+			Attachments: make([]*BundleAttachment, 0),
+		}
+		if bs.Session.Title == "" {
+			bs.Session.Title = DefaultSessionTitle
+		}
+		for _, node := range nodes {
+			m := messages[node]
+			if m == nil {
+				continue
+			}
+			if parent := nearest(c.Mapping[node].Parent); parent != nil {
+				m.ParentId = parent.Id
+			}
+			bs.Messages = append(bs.Messages, m)
+		}
+		if current := nearest(c.CurrentNode); current != nil {
+			bs.Session.CurrentMessage = current.Id
+			bs.Session.LastMessagePreview = preview(current.Content)
+		}
+		bundle.Sessions = append(bundle.Sessions, bs)
+	}
+	return bundle
+}
@@ -0,0 +1,51 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/AntNoHuabei/Remo/internal/log"
+	"github.com/AntNoHuabei/Remo/pkg/api/response"
+	"github.com/AntNoHuabei/Remo/pkg/knowledge"
+)
+
+// retrieve 检索与用户消息相关的知识库片段
+func (agent *ContinuousAgent) retrieve(ctx context.Context, query string) []response.Citation {
+	base := knowledge.Get()
+	if !base.Enabled() {
+		return nil
+	}
+	results, err := base.Search(ctx, query, 0)
+	if err != nil {
+		log.Warn("failed to search knowledge base", "session", agent.session, "error", err)
+		return nil
+	}
+	return Citations(results)
+}
+
+// Citations 将检索结果转换为引用,编号从 1 开始
+func Citations(results []knowledge.Result) []response.Citation {
+	output := make([]response.Citation, 0, len(results))
+	for i, r := range results {
+		output = append(output, response.Citation{
+			Index:    i + 1,
+			Document: r.Chunk.Document,
+			Name:     r.Document.Name,
+			Chunk:    r.Chunk.Index,
+			Content:  r.Chunk.Content,
+			Score:    r.Score,
+		})
+	}
+	return output
+}
+
+// referencePrompt 将知识库片段格式化为系统提示词
+func referencePrompt(citations []response.Citation) string {
+	var sb strings.Builder
+	sb.WriteString("Excerpts from the user's documents that may help with the answer. When you use one, cite it by its number, for example [1]:")
+	for _, c := range citations {
+		fmt.Fprintf(&sb, "\n\n[%d] %s\n%s", c.Index, c.Name, c.Content)
+	}
+	return sb.String()
+}
@@ -2,58 +2,52 @@ package chat
 
 import (
 	"context"
-	"fmt"
-	"github.com/AntNoHuabei/Remo/pkg/persist"
-	"github.com/cloudwego/eino/compose"
-	"github.com/ostafen/clover"
+
+	"github.com/AntNoHuabei/Remo/internal/log"
+	"github.com/AntNoHuabei/Remo/pkg/memory"
+	"github.com/AntNoHuabei/Remo/pkg/usage"
 )
 
-func NewStore(session string) (compose.CheckPointStore, error) {
-	doc, err := persist.DB.Query(persist.SessionCheckpoint).FindById(session)
+// recall 检索与用户消息相关的长期记忆
+func (agent *ContinuousAgent) recall(ctx context.Context, query string) []string {
+	store := memory.Get()
+	if !store.Enabled() {
+		return nil
+	}
+	results, err := store.Search(ctx, query, 0)
 	if err != nil {
-		doc = clover.NewDocument()
-		doc.Set("_id", session)
-		_, err = persist.DB.InsertOne(persist.SessionCheckpoint, doc)
-		if err != nil {
-			return nil, err
-		}
+		log.Debug("failed to recall memories", "session", agent.session, "error", err)
+		return nil
 	}
-	return &sessionStore{
-		session: session,
-		doc:     doc,
-	}, nil
-}
-
-type sessionStore struct {
-	session string
-	doc     *clover.Document
-}
-
-func (i *sessionStore) Set(ctx context.Context, key string, value []byte) error {
-
-	if i.doc != nil {
-		i.doc.Set(key, value)
-	} else {
-		return fmt.Errorf("session not found")
+	output := make([]string, 0, len(results))
+	for _, r := range results {
+		output = append(output, r.Memory.Content)
 	}
-	return nil
+	return output
 }
 
-func (i *sessionStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
-	if i.doc != nil {
-		if i.doc.Has(key) {
-			v := i.doc.Get(key)
-			switch v.(type) {
-			case []byte:
-				return v.([]byte), true, nil
+// remember 在后台从最近一轮对话中提取长期记忆
+func (agent *ContinuousAgent) remember(assistant *Message) {
+	store := memory.Get()
+	if !store.AutoExtract() || agent.chatModel == nil {
+		return
+	}
 
-			default:
-				return nil, false, fmt.Errorf("invalid type")
-			}
-		} else {
-			return nil, false, fmt.Errorf("key not found")
+	var user *Message
+	for i := len(agent.history) - 1; i >= 0; i-- {
+		if agent.history[i].Role == "user" {
+			user = agent.history[i]
+			break
 		}
-	} else {
-		return nil, false, fmt.Errorf("session not found")
 	}
+	if user == nil {
+		return
+	}
+
+	cm, session := Meter(agent.chatModel, agent.model, agent.session, usage.PurposeMemory), agent.session
+	go func() {
+		if _, err := store.Extract(context.Background(), cm, session, user.Content, assistant.Content); err != nil {
+			log.Warn("failed to extract memories", "session", session, "error", err)
+		}
+	}()
 }
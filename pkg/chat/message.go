@@ -1,11 +1,25 @@
 package chat
 
 import (
-	"github.com/AntNoHuabei/Remo/pkg/persist"
+	"sort"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
 	"github.com/google/uuid"
-	"github.com/ostafen/clover"
 )
 
+const (
+	MessageStatusCompleted = "completed"
+	MessageStatusAborted   = "aborted"
+	// MessageStatusInterrupted 等待工具调用确认
+	MessageStatusInterrupted = "interrupted"
+	// MessageStatusFailed 生成过程中出错
+	MessageStatusFailed = "failed"
+)
+
+// MessageTypeSummary 较早消息的摘要,摘要覆盖的消息不再发送给模型
+const MessageTypeSummary = "summary"
+
 type Message struct {
 	Model       string `json:"model"`
 	CreatedTime int64  `json:"created_time"`
@@ -14,34 +28,139 @@ type Message struct {
 	Content     string `json:"content"`
 	Role        string `json:"role"`
 	RequestId   string `json:"request_id"`
+	Status      string `json:"status"` // completed, aborted, interrupted, failed
+	Tokens      int    `json:"tokens"` // 消息占用的 token 数
+	Type        string `json:"type,omitempty"`
+	// Seq 会话内的序号,从 1 开始递增,旧版本保存的消息为 0
+	Seq int64 `json:"seq"`
+	// ParentId 分支中的上一条消息,为空表示第一条消息。编辑和重新生成时创建同一父消息下的新分支
+	ParentId string `json:"parent_id"`
+	// ReasoningContent 模型的推理过程,仅助手消息有值
+	ReasoningContent string `json:"reason_content"`
+	// 生成回复消耗的 token,多轮工具调用时为累计值,仅助手消息有值
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	ReasoningTokens  int `json:"reasoning_tokens"` // 包含在 CompletionTokens 中
+	// FirstTokenLatency 开始生成到收到第一个 token 的毫秒数
+	FirstTokenLatency int64 `json:"first_token_latency"`
+	// Latency 生成的总耗时(毫秒)
+	Latency int64 `json:"latency"`
+	// Attachments 用户消息的附件,内容单独保存
+	Attachments []*Attachment `json:"attachments,omitempty"`
+	// SummaryUntil 摘要覆盖到的最后一条消息 id,仅摘要消息有值
+	SummaryUntil string `json:"summary_until,omitempty"`
 }
 
-func Messages(session string) ([]*Message, error) {
+// AddUsage 累加模型返回的 token 用量
+func (m *Message) AddUsage(usage *schema.TokenUsage) {
+	if usage == nil {
+		return
+	}
+	m.PromptTokens += usage.PromptTokens
+	m.CompletionTokens += usage.CompletionTokens
+	m.ReasoningTokens += usage.CompletionTokensDetails.ReasoningTokens
+}
+
+// ConcatMessages 合并流式返回的消息块,schema.ConcatMessages 不会合并推理 token 数,这里取各块中的最大值
+func ConcatMessages(chunks []*schema.Message) (*schema.Message, error) {
+	full, err := schema.ConcatMessages(chunks)
+	if err != nil || full.ResponseMeta == nil || full.ResponseMeta.Usage == nil {
+		return full, err
+	}
 
-	docs, err := persist.DB.Query(persist.Message).Where(clover.Field("session").Eq(session)).FindAll()
+	reasoning := 0
+	for _, m := range chunks {
+		if m.ResponseMeta != nil && m.ResponseMeta.Usage != nil {
+			reasoning = max(reasoning, m.ResponseMeta.Usage.CompletionTokensDetails.ReasoningTokens)
+		}
+	}
+	if reasoning > full.ResponseMeta.Usage.CompletionTokensDetails.ReasoningTokens {
+		usage := *full.ResponseMeta.Usage
+		usage.CompletionTokensDetails.ReasoningTokens = reasoning
+		meta := *full.ResponseMeta
+		meta.Usage = &usage
+		full.ResponseMeta = &meta
+	}
+	return full, nil
+}
 
+// Messages 返回会话的全部消息,包括所有分支和摘要,按创建时间和序号排序
+func Messages(session string) ([]*Message, error) {
+
+	output, err := repo().Messages(session)
 	if err != nil {
 		return nil, err
 	}
-	var output = make([]*Message, 0)
-	for _, doc := range docs {
-		var message = Message{}
-		if err := doc.Unmarshal(&message); err == nil {
-			output = append(output, &message)
+	sortMessages(output)
+	linkLegacy(output)
+	return output, nil
+}
+
+func sortMessages(messages []*Message) {
+	sort.SliceStable(messages, func(i, j int) bool {
+		a, b := messages[i], messages[j]
+		if a.CreatedTime != b.CreatedTime {
+			return a.CreatedTime < b.CreatedTime
 		}
+		return a.Seq < b.Seq
+	})
+}
+
+// linkLegacy 旧版本保存的消息没有序号和父消息,按顺序连接为一条分支
+func linkLegacy(messages []*Message) {
+	var previous string
+	for _, m := range messages {
+		if m.Seq != 0 || m.Type == MessageTypeSummary {
+			continue
+		}
+		if m.ParentId == "" {
+			m.ParentId = previous
+		}
+		previous = m.Id
 	}
-	return output, nil
 }
 
+// MessageAppend 保存消息,未指定父消息时接在会话当前分支的最后
 func MessageAppend(session string, message *Message) error {
 
+	if message.ParentId == "" && message.Type != MessageTypeSummary {
+		leaf, err := currentLeaf(session)
+		if err != nil {
+			return err
+		}
+		message.ParentId = leaf
+	}
+	return saveMessage(session, message)
+}
+
+// saveMessage 按消息中的父消息保存,并将其设为会话当前分支的最后一条消息
+func saveMessage(session string, message *Message) error {
+
 	if message.Id == "" {
 		message.Id = uuid.New().String()
 	}
+	if message.CreatedTime == 0 {
+		message.CreatedTime = time.Now().UnixMilli()
+	}
+	if message.Tokens == 0 {
+		message.Tokens = EstimateTokens(message.Content)
+		for _, a := range message.Attachments {
+			message.Tokens += a.tokens()
+		}
+	}
+	if message.Session == "" {
+		message.Session = session
+	}
+	if err := saveAttachments(message); err != nil {
+		return err
+	}
 
-	doc := clover.NewDocumentOf(message)
-	doc.Set("_id", message.Id)
-
-	persist.DB.InsertOne(persist.Message, doc)
-	return nil
+	if err := repo().AppendMessage(message); err != nil {
+		return err
+	}
+	// 摘要不是对话内容,不更新会话
+	if message.Type == MessageTypeSummary {
+		return nil
+	}
+	return touchSession(message)
 }
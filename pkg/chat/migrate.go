@@ -0,0 +1,140 @@
+package chat
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/AntNoHuabei/Remo/internal/log"
+	"github.com/AntNoHuabei/Remo/pkg/persist"
+)
+
+// settingCloverMigrated 记录 clover 中的会话迁移到 SQLite 的时间
+const settingCloverMigrated = "clover_migrated_at"
+
+// Migration 迁移的数据量
+type Migration struct {
+	Sessions    int `json:"sessions"`
+	Messages    int `json:"messages"`
+	Attachments int `json:"attachments"`
+	Checkpoints int `json:"checkpoints"`
+}
+
+// Migrate 将 from 中的会话及其消息、附件和断点复制到 to,to 中已有的会话跳过。
+// 每个会话最后写入会话本身,中途失败时重新执行会先清除该会话已复制的部分
+func Migrate(from Repository, to Repository) (*Migration, error) {
+	sessions, err := from.ListSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Migration{}
+	for _, session := range sessions {
+		existing, err := to.GetSession(session.Id)
+		if err != nil {
+			return result, err
+		}
+		if existing != nil {
+			continue
+		}
+		if err := to.PurgeSession(session.Id); err != nil {
+			return result, err
+		}
+		if err := migrateSession(from, to, session, result); err != nil {
+			return result, fmt.Errorf("failed to migrate session %s: %w", session.Id, err)
+		}
+		result.Sessions++
+	}
+	return result, nil
+}
+
+func migrateSession(from Repository, to Repository, session *Session, result *Migration) error {
+	messages, err := from.Messages(session.Id)
+	if err != nil {
+		return err
+	}
+	sortMessages(messages)
+	attachments := make(map[string]bool)
+	for _, m := range messages {
+		if err := to.InsertMessage(m); err != nil {
+			return err
+		}
+		result.Messages++
+
+		// 编辑后的消息沿用原消息的附件,只复制一次
+		for _, a := range m.Attachments {
+			if attachments[a.Id] {
+				continue
+			}
+			attachments[a.Id] = true
+			stored, err := from.GetAttachment(a.Id)
+			if err != nil {
+				return err
+			}
+			if stored == nil {
+				log.Warn("attachment not found while migrating", "session", session.Id, "attachment", a.Id)
+				continue
+			}
+			if err := to.InsertAttachment(session.Id, m.Id, stored); err != nil {
+				return err
+			}
+			result.Attachments++
+		}
+	}
+
+	checkpoints, err := from.Checkpoints(session.Id)
+	if err != nil {
+		return err
+	}
+	for key, data := range checkpoints {
+		if err := to.SetCheckpoint(session.Id, key, data); err != nil {
+			return err
+		}
+		result.Checkpoints++
+	}
+	return to.InsertSession(session)
+}
+
+// InitRepository 按配置设置会话数据的存储,需要先调用 persist.InitDB。
+// 使用 SQLite 时,第一次启动会将 clover 中已有的会话复制到 SQLite,clover 中的数据保留不删除;
+// 存储已加密且没有解锁时,复制在解锁后进行
+func InitRepository(cfg config.StorageConfig) error {
+	if cfg.Backend == config.StorageClover {
+		UseRepository(NewCloverRepository(persist.DB))
+		return nil
+	}
+	if persist.SQL == nil {
+		return fmt.Errorf("sqlite database is not open")
+	}
+
+	r := NewSQLiteRepository(persist.SQL)
+	UseRepository(r)
+	if !persist.Locked() {
+		return migrateClover(r)
+	}
+	persist.AfterUnlock(func() {
+		if err := migrateClover(r); err != nil {
+			log.Error("failed to migrate sessions after unlock", "error", err)
+		}
+	})
+	return nil
+}
+
+// migrateClover 第一次使用 SQLite 时将 clover 中的会话复制到 r
+func migrateClover(r Repository) error {
+	settings := persist.GetSettings()
+	if _, migrated, err := settings.Get(settingCloverMigrated); err != nil || migrated {
+		return err
+	}
+	result, err := Migrate(NewCloverRepository(persist.DB), r)
+	if err != nil {
+		return fmt.Errorf("failed to migrate sessions from clover: %w", err)
+	}
+	if err := settings.Set(settingCloverMigrated, strconv.FormatInt(time.Now().UnixMilli(), 10)); err != nil {
+		return err
+	}
+	log.Info("migrated sessions from clover to sqlite", "sessions", result.Sessions, "messages", result.Messages,
+		"attachments", result.Attachments, "checkpoints", result.Checkpoints)
+	return nil
+}
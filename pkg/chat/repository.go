@@ -0,0 +1,75 @@
+package chat
+
+import (
+	"sync"
+
+	"github.com/AntNoHuabei/Remo/pkg/persist"
+)
+
+// Repository 会话、消息、附件和断点的存储
+type Repository interface {
+	// InsertSession 保存新的会话
+	InsertSession(session *Session) error
+	// GetSession 返回会话,不存在时返回 nil
+	GetSession(id string) (*Session, error)
+	// ReplaceSession 保存会话的全部字段
+	ReplaceSession(session *Session) error
+	// UpdateSession 按 json 字段名更新会话的部分字段,会话不存在时返回 persist.ErrNotFound
+	UpdateSession(id string, fields map[string]any) error
+	// ListSessions 返回全部会话,包括回收站中的会话
+	ListSessions() ([]*Session, error)
+	// DeletedSessions 返回移入回收站的时间不晚于 before 的会话,before 为 0 时返回回收站中的全部会话
+	DeletedSessions(before int64) ([]*Session, error)
+	// PurgeSession 删除会话及其消息、附件和断点
+	PurgeSession(id string) error
+
+	// AppendMessage 为消息分配会话内递增的序号并保存
+	AppendMessage(message *Message) error
+	// InsertMessage 按原样保存消息,用于迁移数据
+	InsertMessage(message *Message) error
+	// Messages 返回会话的全部消息,不保证顺序
+	Messages(session string) ([]*Message, error)
+	// SearchMessages 返回有消息内容包含 query 的会话,query 为小写
+	SearchMessages(query string) (map[string]bool, error)
+
+	// InsertAttachment 保存附件内容,message 为第一次引用附件的消息
+	InsertAttachment(session string, message string, attachment *Attachment) error
+	// GetAttachment 返回附件及其内容,不存在时返回 nil
+	GetAttachment(id string) (*Attachment, error)
+
+	// SetCheckpoint 保存或覆盖会话的断点
+	SetCheckpoint(session string, key string, data []byte) error
+	// GetCheckpoint 返回会话的断点
+	GetCheckpoint(session string, key string) ([]byte, bool, error)
+	// Checkpoints 返回会话的全部断点,键为断点 ID
+	Checkpoints(session string) (map[string][]byte, error)
+	// DeleteCheckpoints 删除会话的全部断点
+	DeleteCheckpoints(session string) error
+	// CleanCheckpoints 删除在 expired 之前更新的断点,返回删除的数量
+	CleanCheckpoints(expired int64) (int, error)
+
+	// CleanOrphans 删除会话已不存在的消息、断点和附件,返回各自删除的数量
+	CleanOrphans() (messages int, checkpoints int, attachments int, err error)
+}
+
+var (
+	globalRepository Repository
+	repositoryMu     sync.RWMutex
+)
+
+// UseRepository 设置会话数据使用的存储
+func UseRepository(r Repository) {
+	repositoryMu.Lock()
+	globalRepository = r
+	repositoryMu.Unlock()
+}
+
+// repo 返回会话数据使用的存储,未设置时使用 persist.DB 中的 clover 集合
+func repo() Repository {
+	repositoryMu.RLock()
+	defer repositoryMu.RUnlock()
+	if globalRepository == nil {
+		return NewCloverRepository(persist.DB)
+	}
+	return globalRepository
+}
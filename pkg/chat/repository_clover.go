@@ -0,0 +1,367 @@
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AntNoHuabei/Remo/pkg/persist"
+	"github.com/google/uuid"
+	"github.com/ostafen/clover"
+)
+
+// checkpoint 断点文档,每个断点 ID 对应一个文档
+type checkpoint struct {
+	Session      string `json:"session"`
+	CheckpointId string `json:"checkpoint_id"`
+	Data         []byte `json:"data"`
+	UpdatedTime  int64  `json:"updated_time"`
+}
+
+// attachmentBlob 附件集合中的文档
+type attachmentBlob struct {
+	Id       string `json:"id"`
+	Session  string `json:"session"`
+	Message  string `json:"message"` // 第一次引用附件的消息,编辑消息时新版本沿用同一附件
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	MimeType string `json:"mime_type"`
+	Data     []byte `json:"data"`
+}
+
+var (
+	// cloverMessageMu 保证同一进程内会话序号的分配和消息的保存是原子的
+	cloverMessageMu sync.Mutex
+	// cloverCheckpointMu 保证同一进程内断点 upsert 的原子性
+	cloverCheckpointMu sync.Mutex
+	// cloverSessionMu 保证同一进程内更新会话部分字段时读取和写回的原子性
+	cloverSessionMu sync.Mutex
+)
+
+// NewCloverRepository 创建保存在 clover 集合中的存储。
+// clover 不支持跨集合的事务,同一进程内的原子性由锁保证
+func NewCloverRepository(db *clover.DB) Repository {
+	return &cloverRepository{db: db}
+}
+
+type cloverRepository struct {
+	db *clover.DB
+}
+
+// checkpointDocId 由会话和断点 ID 生成确定的文档 ID,clover 要求文档 ID 为 uuid
+func checkpointDocId(session, checkpointId string) string {
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte(session+":"+checkpointId)).String()
+}
+
+func (r *cloverRepository) insert(collection string, id string, v any) error {
+	doc, err := persist.NewSealedDocument(collection, v)
+	if err != nil {
+		return err
+	}
+	doc.Set("_id", id)
+	_, err = r.db.InsertOne(collection, doc)
+	return err
+}
+
+func (r *cloverRepository) InsertSession(session *Session) error {
+	return r.insert(persist.Conversation, session.Id, session)
+}
+
+func (r *cloverRepository) GetSession(id string) (*Session, error) {
+	doc, err := r.db.Query(persist.Conversation).FindById(id)
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	var session Session
+	if err := persist.Unmarshal(doc, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *cloverRepository) ReplaceSession(session *Session) error {
+	doc, err := persist.NewSealedDocument(persist.Conversation, session)
+	if err != nil {
+		return err
+	}
+	doc.Set("_id", session.Id)
+	err = r.db.Query(persist.Conversation).ReplaceById(session.Id, doc)
+	if errors.Is(err, clover.ErrDocumentNotExist) {
+		return persist.ErrNotFound
+	}
+	return err
+}
+
+// UpdateSession 读取会话、合并字段后整体写回,启用加密时文档中的字段不能单独更新
+func (r *cloverRepository) UpdateSession(id string, fields map[string]any) error {
+	cloverSessionMu.Lock()
+	defer cloverSessionMu.Unlock()
+
+	doc, err := r.db.Query(persist.Conversation).FindById(id)
+	if err != nil {
+		return err
+	}
+	if doc == nil {
+		return persist.ErrNotFound
+	}
+	merged := make(map[string]any)
+	if err := persist.Unmarshal(doc, &merged); err != nil {
+		return err
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	b, err := json.Marshal(merged)
+	if err != nil {
+		return err
+	}
+	var session Session
+	if err := json.Unmarshal(b, &session); err != nil {
+		return err
+	}
+	return r.ReplaceSession(&session)
+}
+
+func (r *cloverRepository) ListSessions() ([]*Session, error) {
+	return r.sessions(r.db.Query(persist.Conversation))
+}
+
+func (r *cloverRepository) DeletedSessions(before int64) ([]*Session, error) {
+	criteria := clover.Field("deleted_at").Gt(0)
+	if before > 0 {
+		criteria = criteria.And(clover.Field("deleted_at").LtEq(before))
+	}
+	return r.sessions(r.db.Query(persist.Conversation).Where(criteria))
+}
+
+func (r *cloverRepository) sessions(q *clover.Query) ([]*Session, error) {
+	docs, err := q.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]*Session, 0, len(docs))
+	for _, doc := range docs {
+		var session Session
+		if err := persist.Unmarshal(doc, &session); err != nil {
+			continue
+		}
+		sessions = append(sessions, &session)
+	}
+	return sessions, nil
+}
+
+// PurgeSession 先删除消息、断点和附件,最后删除会话文档:
+// 中途失败时会话仍在,再次删除即可完成,不会留下找不到会话的孤儿数据
+func (r *cloverRepository) PurgeSession(id string) error {
+	for _, collection := range []string{persist.Message, persist.SessionCheckpoint, persist.Attachment} {
+		if err := r.db.Query(collection).Where(clover.Field("session").Eq(id)).Delete(); err != nil {
+			return err
+		}
+	}
+	return r.db.Query(persist.Conversation).DeleteById(id)
+}
+
+func (r *cloverRepository) AppendMessage(message *Message) error {
+	cloverMessageMu.Lock()
+	defer cloverMessageMu.Unlock()
+
+	doc, err := r.db.Query(persist.Message).Where(clover.Field("session").Eq(message.Session)).
+		Sort(clover.SortOption{Field: "seq", Direction: -1}).FindFirst()
+	if err != nil {
+		return err
+	}
+	var last Message
+	if doc != nil {
+		if err := persist.Unmarshal(doc, &last); err != nil {
+			return err
+		}
+	}
+	message.Seq = last.Seq + 1
+	return r.InsertMessage(message)
+}
+
+func (r *cloverRepository) InsertMessage(message *Message) error {
+	return r.insert(persist.Message, message.Id, message)
+}
+
+func (r *cloverRepository) Messages(session string) ([]*Message, error) {
+	docs, err := r.db.Query(persist.Message).Where(clover.Field("session").Eq(session)).FindAll()
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]*Message, 0, len(docs))
+	for _, doc := range docs {
+		var message Message
+		if err := persist.Unmarshal(doc, &message); err == nil {
+			messages = append(messages, &message)
+		}
+	}
+	return messages, nil
+}
+
+func (r *cloverRepository) SearchMessages(query string) (map[string]bool, error) {
+	output := make(map[string]bool)
+	var parseErr error
+	err := r.db.Query(persist.Message).ForEach(func(doc *clover.Document) bool {
+		var m struct {
+			Session string `json:"session"`
+			Content string `json:"content"`
+		}
+		if parseErr = persist.Unmarshal(doc, &m); parseErr != nil {
+			return false
+		}
+		if strings.Contains(strings.ToLower(m.Content), query) {
+			output[m.Session] = true
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return output, parseErr
+}
+
+func (r *cloverRepository) InsertAttachment(session string, message string, a *Attachment) error {
+	return r.insert(persist.Attachment, a.Id, &attachmentBlob{
+		Id:       a.Id,
+		Session:  session,
+		Message:  message,
+		Kind:     a.Kind,
+		Name:     a.Name,
+		MimeType: a.MimeType,
+		Data:     a.Data,
+	})
+}
+
+func (r *cloverRepository) GetAttachment(id string) (*Attachment, error) {
+	doc, err := r.db.Query(persist.Attachment).FindById(id)
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	var blob attachmentBlob
+	if err := persist.Unmarshal(doc, &blob); err != nil {
+		return nil, err
+	}
+	return &Attachment{Id: blob.Id, Kind: blob.Kind, Name: blob.Name, MimeType: blob.MimeType, Size: len(blob.Data), Data: blob.Data}, nil
+}
+
+func (r *cloverRepository) SetCheckpoint(session string, key string, data []byte) error {
+	doc, err := persist.NewSealedDocument(persist.SessionCheckpoint, &checkpoint{
+		Session:      session,
+		CheckpointId: key,
+		Data:         data,
+		UpdatedTime:  time.Now().UnixMilli(),
+	})
+	if err != nil {
+		return err
+	}
+	id := checkpointDocId(session, key)
+	doc.Set("_id", id)
+
+	cloverCheckpointMu.Lock()
+	defer cloverCheckpointMu.Unlock()
+
+	old, err := r.db.Query(persist.SessionCheckpoint).FindById(id)
+	if err != nil {
+		return err
+	}
+	if old == nil {
+		_, err = r.db.InsertOne(persist.SessionCheckpoint, doc)
+		return err
+	}
+	return r.db.Query(persist.SessionCheckpoint).ReplaceById(id, doc)
+}
+
+func (r *cloverRepository) GetCheckpoint(session string, key string) ([]byte, bool, error) {
+	doc, err := r.db.Query(persist.SessionCheckpoint).FindById(checkpointDocId(session, key))
+	if err != nil || doc == nil {
+		return nil, false, err
+	}
+	var cp checkpoint
+	if err := persist.Unmarshal(doc, &cp); err != nil {
+		return nil, false, err
+	}
+	return cp.Data, true, nil
+}
+
+// Checkpoints 旧版本按会话存储的断点文档没有断点 ID,不会返回
+func (r *cloverRepository) Checkpoints(session string) (map[string][]byte, error) {
+	docs, err := r.db.Query(persist.SessionCheckpoint).Where(
+		clover.Field("session").Eq(session).And(clover.Field("checkpoint_id").Exists())).FindAll()
+	if err != nil {
+		return nil, err
+	}
+	output := make(map[string][]byte, len(docs))
+	for _, doc := range docs {
+		var cp checkpoint
+		if err := persist.Unmarshal(doc, &cp); err != nil {
+			return nil, err
+		}
+		output[cp.CheckpointId] = cp.Data
+	}
+	return output, nil
+}
+
+func (r *cloverRepository) DeleteCheckpoints(session string) error {
+	return r.db.Query(persist.SessionCheckpoint).Where(clover.Field("session").Eq(session)).Delete()
+}
+
+// CleanCheckpoints 同时清理旧版本遗留的按会话存储的断点文档
+func (r *cloverRepository) CleanCheckpoints(expired int64) (int, error) {
+	q := r.db.Query(persist.SessionCheckpoint).Where(
+		clover.Field("checkpoint_id").NotExists().Or(clover.Field("updated_time").Lt(expired)))
+
+	n, err := q.Count()
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	return n, q.Delete()
+}
+
+func (r *cloverRepository) CleanOrphans() (messages int, checkpoints int, attachments int, err error) {
+	docs, err := r.db.Query(persist.Conversation).FindAll()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	sessions := make(map[string]bool, len(docs))
+	for _, doc := range docs {
+		sessions[doc.ObjectId()] = true
+	}
+
+	if messages, err = r.cleanOrphans(persist.Message, sessions); err != nil {
+		return 0, 0, 0, err
+	}
+	if checkpoints, err = r.cleanOrphans(persist.SessionCheckpoint, sessions); err != nil {
+		return messages, 0, 0, err
+	}
+	if attachments, err = r.cleanOrphans(persist.Attachment, sessions); err != nil {
+		return messages, checkpoints, 0, err
+	}
+	return messages, checkpoints, attachments, nil
+}
+
+// cleanOrphans 删除集合中 session 字段不属于 sessions 的文档
+func (r *cloverRepository) cleanOrphans(collection string, sessions map[string]bool) (int, error) {
+	var orphans []string
+	err := r.db.Query(collection).ForEach(func(doc *clover.Document) bool {
+		if session, _ := doc.Get("session").(string); !sessions[session] {
+			orphans = append(orphans, doc.ObjectId())
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for i, id := range orphans {
+		if err := r.db.Query(collection).DeleteById(id); err != nil {
+			return i, err
+		}
+	}
+	return len(orphans), nil
+}
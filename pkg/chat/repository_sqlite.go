@@ -0,0 +1,372 @@
+package chat
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/AntNoHuabei/Remo/pkg/persist"
+)
+
+// NewSQLiteRepository 创建保存在 SQLite 中的存储,表结构见 persist.OpenSQLite。
+// 需要多条语句的操作在事务中执行
+func NewSQLiteRepository(db *sql.DB) Repository {
+	return &sqliteRepository{db: db}
+}
+
+type sqliteRepository struct {
+	db *sql.DB
+}
+
+// updateSession 参数顺序与 writeSession 一致
+const updateSession = `UPDATE sessions SET updated_at = ?2, deleted_at = ?3, data = ?4 WHERE id = ?1`
+
+// execer 同时适用于 *sql.DB 和 *sql.Tx
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// sealJSON 将 v 序列化为 data 列的值,启用加密时为密文
+func sealJSON(v any) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return persist.Seal(b)
+}
+
+// unsealJSON 解析 data 列的值,加密的数据先解密
+func unsealJSON(data []byte, v any) error {
+	b, err := persist.Unseal(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func (r *sqliteRepository) InsertSession(session *Session) error {
+	return writeSession(r.db, `INSERT INTO sessions (id, updated_at, deleted_at, data) VALUES (?, ?, ?, ?)`, session)
+}
+
+// writeSession 以 id、updated_at、deleted_at、data 为参数执行语句,没有写入任何行时返回 persist.ErrNotFound
+func writeSession(db execer, stmt string, session *Session) error {
+	data, err := sealJSON(session)
+	if err != nil {
+		return err
+	}
+	res, err := db.Exec(stmt, session.Id, session.UpdatedAt, session.DeletedAt, data)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return persist.ErrNotFound
+	}
+	return nil
+}
+
+func (r *sqliteRepository) GetSession(id string) (*Session, error) {
+	return getSession(r.db.QueryRow(`SELECT data FROM sessions WHERE id = ?`, id))
+}
+
+func getSession(row *sql.Row) (*Session, error) {
+	var data []byte
+	if err := row.Scan(&data); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var session Session
+	if err := unsealJSON(data, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *sqliteRepository) ReplaceSession(session *Session) error {
+	return writeSession(r.db, updateSession, session)
+}
+
+// UpdateSession 在事务中读取会话的 json,合并字段后写回
+func (r *sqliteRepository) UpdateSession(id string, fields map[string]any) error {
+	return persist.Tx(r.db, func(tx *sql.Tx) error {
+		var data []byte
+		if err := tx.QueryRow(`SELECT data FROM sessions WHERE id = ?`, id).Scan(&data); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return persist.ErrNotFound
+			}
+			return err
+		}
+
+		doc := make(map[string]any)
+		if err := unsealJSON(data, &doc); err != nil {
+			return err
+		}
+		for k, v := range fields {
+			doc[k] = v
+		}
+		b, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		var session Session
+		if err := json.Unmarshal(b, &session); err != nil {
+			return err
+		}
+		return writeSession(tx, updateSession, &session)
+	})
+}
+
+func (r *sqliteRepository) ListSessions() ([]*Session, error) {
+	return r.sessions(`SELECT data FROM sessions`)
+}
+
+func (r *sqliteRepository) DeletedSessions(before int64) ([]*Session, error) {
+	if before > 0 {
+		return r.sessions(`SELECT data FROM sessions WHERE deleted_at > 0 AND deleted_at <= ?`, before)
+	}
+	return r.sessions(`SELECT data FROM sessions WHERE deleted_at > 0`)
+}
+
+func (r *sqliteRepository) sessions(query string, args ...any) ([]*Session, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make([]*Session, 0)
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var session Session
+		if err := unsealJSON(data, &session); err != nil {
+			if errors.Is(err, persist.ErrLocked) {
+				return nil, err
+			}
+			continue
+		}
+		sessions = append(sessions, &session)
+	}
+	return sessions, rows.Err()
+}
+
+func (r *sqliteRepository) PurgeSession(id string) error {
+	return persist.Tx(r.db, func(tx *sql.Tx) error {
+		for _, stmt := range []string{
+			`DELETE FROM messages WHERE session = ?`,
+			`DELETE FROM checkpoints WHERE session = ?`,
+			`DELETE FROM attachments WHERE session = ?`,
+			`DELETE FROM sessions WHERE id = ?`,
+		} {
+			if _, err := tx.Exec(stmt, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// AppendMessage 在同一事务中读取最大序号并保存消息
+func (r *sqliteRepository) AppendMessage(message *Message) error {
+	return persist.Tx(r.db, func(tx *sql.Tx) error {
+		var seq int64
+		if err := tx.QueryRow(`SELECT COALESCE(MAX(seq), 0) FROM messages WHERE session = ?`, message.Session).Scan(&seq); err != nil {
+			return err
+		}
+		message.Seq = seq + 1
+		return insertMessage(tx, message)
+	})
+}
+
+func (r *sqliteRepository) InsertMessage(message *Message) error {
+	return insertMessage(r.db, message)
+}
+
+// insertMessage 启用加密时不保存 content 列,搜索时从解密后的 data 中读取
+func insertMessage(db execer, message *Message) error {
+	data, err := sealJSON(message)
+	if err != nil {
+		return err
+	}
+	content := message.Content
+	if persist.IsSealed(data) {
+		content = ""
+	}
+	_, err = db.Exec(`INSERT INTO messages (id, session, seq, created_time, content, data) VALUES (?, ?, ?, ?, ?, ?)`,
+		message.Id, message.Session, message.Seq, message.CreatedTime, content, data)
+	return err
+}
+
+func (r *sqliteRepository) Messages(session string) ([]*Message, error) {
+	rows, err := r.db.Query(`SELECT data FROM messages WHERE session = ?`, session)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := make([]*Message, 0)
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var message Message
+		if err := unsealJSON(data, &message); err == nil {
+			messages = append(messages, &message)
+		} else if errors.Is(err, persist.ErrLocked) {
+			return nil, err
+		}
+	}
+	return messages, rows.Err()
+}
+
+// SearchMessages SQLite 的 lower 只转换 ASCII 字符,因此在 Go 中比较。
+// 加密的消息没有 content 列,解密 data 后比较
+func (r *sqliteRepository) SearchMessages(query string) (map[string]bool, error) {
+	rows, err := r.db.Query(`SELECT session, content, CASE WHEN content = '' THEN data END FROM messages`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	output := make(map[string]bool)
+	for rows.Next() {
+		var session, content string
+		var data []byte
+		if err := rows.Scan(&session, &content, &data); err != nil {
+			return nil, err
+		}
+		if output[session] {
+			continue
+		}
+		if content == "" && persist.IsSealed(data) {
+			var message struct {
+				Content string `json:"content"`
+			}
+			if err := unsealJSON(data, &message); err != nil {
+				return nil, err
+			}
+			content = message.Content
+		}
+		if strings.Contains(strings.ToLower(content), query) {
+			output[session] = true
+		}
+	}
+	return output, rows.Err()
+}
+
+func (r *sqliteRepository) InsertAttachment(session string, message string, a *Attachment) error {
+	data, err := persist.Seal(a.Data)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`INSERT INTO attachments (id, session, message, kind, name, mime_type, data) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		a.Id, session, message, a.Kind, a.Name, a.MimeType, data)
+	return err
+}
+
+func (r *sqliteRepository) GetAttachment(id string) (*Attachment, error) {
+	a := &Attachment{Id: id}
+	err := r.db.QueryRow(`SELECT kind, name, mime_type, data FROM attachments WHERE id = ?`, id).
+		Scan(&a.Kind, &a.Name, &a.MimeType, &a.Data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if a.Data, err = persist.Unseal(a.Data); err != nil {
+		return nil, err
+	}
+	a.Size = len(a.Data)
+	return a, nil
+}
+
+func (r *sqliteRepository) SetCheckpoint(session string, key string, data []byte) error {
+	data, err := persist.Seal(data)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`INSERT INTO checkpoints (session, checkpoint_id, data, updated_time) VALUES (?, ?, ?, ?)
+		ON CONFLICT (session, checkpoint_id) DO UPDATE SET data = excluded.data, updated_time = excluded.updated_time`,
+		session, key, data, time.Now().UnixMilli())
+	return err
+}
+
+func (r *sqliteRepository) GetCheckpoint(session string, key string) ([]byte, bool, error) {
+	var data []byte
+	err := r.db.QueryRow(`SELECT data FROM checkpoints WHERE session = ? AND checkpoint_id = ?`, session, key).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if data, err = persist.Unseal(data); err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+func (r *sqliteRepository) Checkpoints(session string) (map[string][]byte, error) {
+	rows, err := r.db.Query(`SELECT checkpoint_id, data FROM checkpoints WHERE session = ?`, session)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	output := make(map[string][]byte)
+	for rows.Next() {
+		var key string
+		var data []byte
+		if err := rows.Scan(&key, &data); err != nil {
+			return nil, err
+		}
+		if data, err = persist.Unseal(data); err != nil {
+			return nil, err
+		}
+		output[key] = data
+	}
+	return output, rows.Err()
+}
+
+func (r *sqliteRepository) DeleteCheckpoints(session string) error {
+	_, err := r.db.Exec(`DELETE FROM checkpoints WHERE session = ?`, session)
+	return err
+}
+
+func (r *sqliteRepository) CleanCheckpoints(expired int64) (int, error) {
+	res, err := r.db.Exec(`DELETE FROM checkpoints WHERE updated_time < ?`, expired)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (r *sqliteRepository) CleanOrphans() (messages int, checkpoints int, attachments int, err error) {
+	counts := make([]int, 3)
+	err = persist.Tx(r.db, func(tx *sql.Tx) error {
+		for i, table := range []string{"messages", "checkpoints", "attachments"} {
+			res, err := tx.Exec(`DELETE FROM ` + table + ` WHERE session NOT IN (SELECT id FROM sessions)`)
+			if err != nil {
+				return err
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			counts[i] = int(n)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return counts[0], counts[1], counts[2], nil
+}
@@ -0,0 +1,279 @@
+package chat
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/AntNoHuabei/Remo/pkg/persist"
+	"github.com/google/uuid"
+)
+
+// openSQLiteDB 在 openTestDB 的基础上打开 SQLite 并作为会话数据的存储
+func openSQLiteDB(t *testing.T) string {
+	dir := openTestDB(t)
+	db, err := persist.OpenSQLite(dir)
+	if err != nil {
+		t.Fatalf("Failed to open sqlite: %v", err)
+	}
+	persist.SQL = db
+	UseRepository(NewSQLiteRepository(db))
+	t.Cleanup(func() {
+		UseRepository(nil)
+		persist.SQL = nil
+		db.Close()
+	})
+	return dir
+}
+
+var testBackends = []struct {
+	name string
+	open func(t *testing.T) string
+}{
+	{"clover", openTestDB},
+	{"sqlite", openSQLiteDB},
+}
+
+func TestRepository(t *testing.T) {
+	for _, backend := range testBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			backend.open(t)
+			r := repo()
+
+			// clover 要求文档 ID 为 uuid
+			s1, s2, missing := uuid.NewString(), uuid.NewString(), uuid.NewString()
+			s := &Session{Id: s1, Title: "a", CreatedAt: 1, UpdatedAt: 1}
+			if err := r.InsertSession(s); err != nil {
+				t.Fatalf("InsertSession failed: %v", err)
+			}
+			if got, err := r.GetSession(missing); got != nil || err != nil {
+				t.Errorf("Expected nil for a missing session, got %v %v", got, err)
+			}
+			if err := r.UpdateSession(s1, map[string]any{"deleted_at": 5, "title": "b"}); err != nil {
+				t.Fatalf("UpdateSession failed: %v", err)
+			}
+			if got, _ := r.GetSession(s1); got.Title != "b" || got.DeletedAt != 5 || got.CreatedAt != 1 {
+				t.Errorf("Unexpected session after update %+v", got)
+			}
+			if err := r.UpdateSession(missing, map[string]any{"title": "x"}); !errors.Is(err, persist.ErrNotFound) {
+				t.Errorf("Expected ErrNotFound, got %v", err)
+			}
+			if err := r.ReplaceSession(&Session{Id: missing}); !errors.Is(err, persist.ErrNotFound) {
+				t.Errorf("Expected ErrNotFound, got %v", err)
+			}
+			if trashed, _ := r.DeletedSessions(4); len(trashed) != 0 {
+				t.Errorf("Expected no sessions deleted before 4, got %d", len(trashed))
+			}
+			if trashed, _ := r.DeletedSessions(0); len(trashed) != 1 {
+				t.Errorf("Expected 1 trashed session, got %d", len(trashed))
+			}
+
+			// 序号按会话递增
+			for _, session := range []string{s1, s1, s2} {
+				if err := r.AppendMessage(&Message{Id: uuid.NewString(), Session: session, Content: "Hello " + session}); err != nil {
+					t.Fatalf("AppendMessage failed: %v", err)
+				}
+			}
+			messages, _ := r.Messages(s1)
+			sortMessages(messages)
+			if len(messages) != 2 || messages[0].Seq != 1 || messages[1].Seq != 2 {
+				t.Errorf("Unexpected messages %+v", messages)
+			}
+			if matched, _ := r.SearchMessages("hello " + s2); !matched[s2] || matched[s1] {
+				t.Errorf("Unexpected search result %v", matched)
+			}
+
+			a := &Attachment{Id: uuid.NewString(), Kind: AttachmentImage, Name: "cat.png", MimeType: "image/png", Data: pngHeader}
+			if err := r.InsertAttachment(s1, messages[0].Id, a); err != nil {
+				t.Fatalf("InsertAttachment failed: %v", err)
+			}
+			if got, _ := r.GetAttachment(a.Id); got == nil || string(got.Data) != string(pngHeader) || got.Size != len(pngHeader) {
+				t.Errorf("Unexpected attachment %+v", got)
+			}
+
+			r.SetCheckpoint(s1, "cp", []byte("1"))
+			r.SetCheckpoint(s1, "cp", []byte("2"))
+			if checkpoints, _ := r.Checkpoints(s1); len(checkpoints) != 1 || string(checkpoints["cp"]) != "2" {
+				t.Errorf("Unexpected checkpoints %v", checkpoints)
+			}
+			if n, _ := r.CleanCheckpoints(time.Now().Add(time.Hour).UnixMilli()); n != 1 {
+				t.Errorf("Expected 1 expired checkpoint, got %d", n)
+			}
+
+			// s2 没有会话,其消息为孤儿数据
+			if messages, checkpoints, attachments, err := r.CleanOrphans(); err != nil || messages != 1 || checkpoints != 0 || attachments != 0 {
+				t.Errorf("Unexpected orphans %d %d %d %v", messages, checkpoints, attachments, err)
+			}
+			if err := r.PurgeSession(s1); err != nil {
+				t.Fatalf("PurgeSession failed: %v", err)
+			}
+			if messages, _ := r.Messages(s1); len(messages) != 0 {
+				t.Errorf("Expected messages to be purged, got %d", len(messages))
+			}
+			if got, _ := r.GetAttachment(a.Id); got != nil {
+				t.Error("Expected attachments to be purged")
+			}
+			if sessions, _ := r.ListSessions(); len(sessions) != 0 {
+				t.Errorf("Expected no sessions, got %d", len(sessions))
+			}
+		})
+	}
+}
+
+func TestChatWithSQLite(t *testing.T) {
+	openSQLiteDB(t)
+	agent := newTestAgent(t, echoModel(), "", nil)
+
+	collect(mustChat(t, agent, "a"))
+	collect(mustChat(t, agent, "b"))
+	if got := threadContents(t, agent.session); got != "a|re a #1|b|re b #2" {
+		t.Fatalf("Unexpected thread %q", got)
+	}
+	session, _ := GetSession(agent.session)
+	if session.LastMessagePreview != "re b #2" || session.CurrentMessage == "" {
+		t.Errorf("Expected the session to be touched, got %+v", session)
+	}
+	if page, _ := SessionList(SessionFilter{Query: "RE B"}); page.Total != 1 {
+		t.Errorf("Expected the session to be found by message content, got %d", page.Total)
+	}
+
+	if err := DeleteSession(agent.session); err != nil {
+		t.Fatalf("DeleteSession failed: %v", err)
+	}
+	if n, err := PurgeTrash(0); err != nil || n != 1 {
+		t.Fatalf("Expected 1 purged session, got %d %v", n, err)
+	}
+	if messages, _ := Messages(agent.session); len(messages) != 0 {
+		t.Errorf("Expected messages to be purged, got %d", len(messages))
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	dir := openTestDB(t)
+
+	// 在 clover 中准备数据:一个编辑过的带附件的会话和一个回收站中的会话
+	s := CreateSession()
+	image, _ := NewAttachment(AttachmentImage, "cat.png", "", pngHeader)
+	first := &Message{Role: "user", Content: "look", Attachments: []*Attachment{image}}
+	MessageAppend(s.Id, first)
+	MessageAppend(s.Id, &Message{Role: "assistant", Content: "a cat"})
+	edited := &Message{Role: "user", Content: "look again", Attachments: first.Attachments}
+	saveMessage(s.Id, edited)
+	store, _ := NewStore(s.Id)
+	store.Set(t.Context(), "cp", []byte("data"))
+	trashed := newSessionWithData(t)
+	DeleteSession(trashed.Id)
+
+	db, err := persist.OpenSQLite(dir)
+	if err != nil {
+		t.Fatalf("Failed to open sqlite: %v", err)
+	}
+	persist.SQL = db
+	t.Cleanup(func() {
+		UseRepository(nil)
+		persist.SQL = nil
+		db.Close()
+	})
+	if err := InitRepository(config.StorageConfig{Backend: config.StorageSQLite, Dir: dir}); err != nil {
+		t.Fatalf("InitRepository failed: %v", err)
+	}
+
+	// 迁移后通过 SQLite 读取
+	if thread, _ := CurrentThread(s.Id); len(thread.Messages) != 1 || thread.Messages[0].Id != edited.Id {
+		t.Errorf("Expected the current branch to be kept, got %+v", thread.Messages)
+	}
+	messages, _ := Messages(s.Id)
+	if len(messages) != 3 || messages[2].Seq != 3 {
+		t.Errorf("Expected messages with their sequence numbers, got %+v", messages)
+	}
+	if a, err := GetAttachment(image.Id); err != nil || string(a.Data) != string(pngHeader) {
+		t.Errorf("Expected the attachment to be migrated, got %v", err)
+	}
+	if v, ok, _ := store.Get(t.Context(), "cp"); !ok || string(v) != "data" {
+		t.Error("Expected the checkpoint to be migrated")
+	}
+	if trash, _ := TrashList(); len(trash) != 1 || trash[0].Id != trashed.Id {
+		t.Errorf("Expected the trashed session to stay in the trash, got %+v", trash)
+	}
+
+	// 只迁移一次,之后 clover 中新增的会话不再复制
+	if _, ok, _ := persist.GetSettings().Get(settingCloverMigrated); !ok {
+		t.Error("Expected the migration to be recorded")
+	}
+	late := uuid.NewString()
+	NewCloverRepository(persist.DB).InsertSession(&Session{Id: late})
+	if err := InitRepository(config.StorageConfig{Backend: config.StorageSQLite, Dir: dir}); err != nil {
+		t.Fatalf("InitRepository failed: %v", err)
+	}
+	if got, _ := repo().GetSession(late); got != nil {
+		t.Error("Expected the migration to run only once")
+	}
+
+	// 直接迁移时跳过已存在的会话
+	result, err := Migrate(NewCloverRepository(persist.DB), repo())
+	if err != nil || result.Sessions != 1 || result.Messages != 0 {
+		t.Errorf("Expected only the new session to be migrated, got %+v %v", result, err)
+	}
+}
+
+func TestEncryptedRepository(t *testing.T) {
+	for _, backend := range testBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			backend.open(t)
+			s := CreateSession()
+			image, _ := NewAttachment(AttachmentImage, "cat.png", "", pngHeader)
+			MessageAppend(s.Id, &Message{Role: "user", Content: "Before encryption", Attachments: []*Attachment{image}})
+			if err := repo().SetCheckpoint(s.Id, "cp", []byte("state")); err != nil {
+				t.Fatalf("SetCheckpoint failed: %v", err)
+			}
+
+			if err := persist.EnableEncryption(persist.KeyOptions{Source: persist.KeySourcePassphrase, Passphrase: "pass"}); err != nil {
+				t.Fatalf("EnableEncryption failed: %v", err)
+			}
+			t.Cleanup(func() {
+				persist.Unlock("pass")
+				if err := persist.DisableEncryption("pass"); err != nil {
+					t.Errorf("DisableEncryption failed: %v", err)
+				}
+			})
+
+			RenameSession(s.Id, "Encrypted title")
+			MessageAppend(s.Id, &Message{Role: "assistant", Content: "After encryption"})
+			if got := threadContents(t, s.Id); got != "Before encryption|After encryption" {
+				t.Errorf("Unexpected thread %q", got)
+			}
+			if got, _ := GetSession(s.Id); got.Title != "Encrypted title" {
+				t.Errorf("Expected the title to be updated, got %q", got.Title)
+			}
+			if found, err := repo().SearchMessages("after"); err != nil || !found[s.Id] {
+				t.Errorf("Expected encrypted messages to be searchable, got %v %v", found, err)
+			}
+			if a, err := GetAttachment(image.Id); err != nil || string(a.Data) != string(pngHeader) {
+				t.Errorf("Expected the attachment to be readable, got %v", err)
+			}
+			if data, ok, _ := repo().GetCheckpoint(s.Id, "cp"); !ok || string(data) != "state" {
+				t.Errorf("Expected the checkpoint to be readable, got %q", data)
+			}
+
+			// 内容不以明文保存
+			if persist.SQL != nil {
+				var plain int
+				persist.SQL.QueryRow(`SELECT COUNT(*) FROM messages WHERE content != '' OR data LIKE '%encryption%'`).Scan(&plain)
+				if plain != 0 {
+					t.Errorf("Expected no plaintext messages, got %d", plain)
+				}
+			} else {
+				doc, _ := persist.DB.Query(persist.Conversation).FindById(s.Id)
+				if doc.Has("title") {
+					t.Error("Expected the session title to be encrypted")
+				}
+			}
+
+			persist.Lock()
+			if _, err := repo().GetSession(s.Id); !errors.Is(err, persist.ErrLocked) {
+				t.Errorf("Expected ErrLocked, got %v", err)
+			}
+		})
+	}
+}
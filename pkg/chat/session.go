@@ -1,51 +1,172 @@
 package chat
 
 import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
 	"github.com/AntNoHuabei/Remo/pkg/persist"
+	"github.com/AntNoHuabei/Remo/pkg/provider"
 	"github.com/google/uuid"
-	"github.com/ostafen/clover"
 )
 
+// previewLength 最后一条消息预览的最大字符数
+const previewLength = 100
+
 type Session struct {
-	Id    string `json:"id"`
-	Title string `json:"title"`
+	Id                 string                   `json:"id"`
+	Title              string                   `json:"title"`
+	Model              string                   `json:"model"`                // 会话使用的模型,为空则使用默认模型
+	Instruction        string                   `json:"instruction"`          // 系统提示词
+	Options            provider.GenerateOptions `json:"options"`              // 生成参数
+	CreatedAt          int64                    `json:"created_at"`           // 毫秒时间戳
+	UpdatedAt          int64                    `json:"updated_at"`           // 最后一条消息的时间
+	LastMessagePreview string                   `json:"last_message_preview"` // 最后一条消息的开头部分
+	Pinned             bool                     `json:"pinned"`               // 置顶
+	Archived           bool                     `json:"archived"`             // 已归档的会话默认不在列表中显示
+	DeletedAt          int64                    `json:"deleted_at"`           // 移入回收站的时间,为 0 表示未删除
+	CurrentMessage     string                   `json:"current_message"`      // 当前分支的最后一条消息,为空表示最新的消息
+}
+
+// SessionFilter 会话列表的查询条件
+type SessionFilter struct {
+	Query    string // 不为空时只返回标题或消息内容包含该字符串的会话,不区分大小写
+	Archived bool   // 为 true 时只返回已归档的会话,否则只返回未归档的会话
+	Offset   int
+	Limit    int // 小于等于 0 时不限制
+}
+
+// SessionPage 会话列表的一页
+type SessionPage struct {
+	Sessions []Session `json:"sessions"`
+	Total    int       `json:"total"` // 满足条件的会话总数
 }
 
 func CreateSession() *Session {
 
 	id := uuid.New().String()
+	now := time.Now().UnixMilli()
 
 	var session = &Session{
-		Id:    id,
-		Title: "New Session",
+		Id:        id,
+		Title:     DefaultSessionTitle,
+		CreatedAt: now,
+		UpdatedAt: now,
 	}
 
-	doc := clover.NewDocumentOf(session)
-	doc.Set("_id", id)
-	persist.DB.InsertOne(persist.Conversation, doc)
+	// 避免清理孤儿数据时误删新会话的消息
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	repo().InsertSession(session)
 
 	return session
 
 }
 
-func DeleteSession(id string) error {
+// GetSession 获取会话
+func GetSession(id string) (*Session, error) {
+	session, err := repo().GetSession(id)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, fmt.Errorf("session not found: %s", id)
+	}
+	return session, nil
+}
+
+// UpdateSession 按 json 字段名更新会话的部分字段并返回更新后的会话,回收站中的会话不能修改。
+// 只写入 fields 中的字段,对话期间更新的当前分支、更新时间和标题不会被覆盖
+func UpdateSession(id string, fields map[string]any) (*Session, error) {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
 
-	return persist.DB.Query(persist.Conversation).DeleteById(id)
+	session, err := GetSession(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := session.CheckActive(); err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return session, nil
+	}
+	if err := repo().UpdateSession(id, fields); err != nil {
+		return nil, err
+	}
+	return GetSession(id)
 }
 
-func SessionList(offset, limit int) ([]Session, error) {
-	docs, err := persist.DB.Query(persist.Conversation).Skip(offset).Limit(limit).FindAll()
+// SessionList 按置顶、最近更新时间排序返回会话,不包括回收站中的会话
+func SessionList(filter SessionFilter) (*SessionPage, error) {
+	all, err := repo().ListSessions()
 	if err != nil {
 		return nil, err
 	}
 
-	var output = make([]Session, 0)
-	for _, doc := range docs {
-		var session = Session{}
-		if err := doc.Unmarshal(&session); err == nil {
+	query := strings.ToLower(strings.TrimSpace(filter.Query))
+	var matched map[string]bool
+	if query != "" {
+		if matched, err = repo().SearchMessages(query); err != nil {
+			return nil, err
+		}
+	}
 
-			output = append(output, session)
+	var sessions = make([]Session, 0)
+	for _, s := range all {
+		session := *s
+		if session.DeletedAt != 0 || session.Archived != filter.Archived {
+			continue
+		}
+		if query != "" && !matched[session.Id] && !strings.Contains(strings.ToLower(session.Title), query) {
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		a, b := sessions[i], sessions[j]
+		if a.Pinned != b.Pinned {
+			return a.Pinned
+		}
+		if a.UpdatedAt != b.UpdatedAt {
+			return a.UpdatedAt > b.UpdatedAt
 		}
+		return a.CreatedAt > b.CreatedAt
+	})
+
+	page := &SessionPage{Total: len(sessions)}
+	start := min(max(filter.Offset, 0), len(sessions))
+	end := len(sessions)
+	if filter.Limit > 0 {
+		end = min(start+filter.Limit, end)
+	}
+	page.Sessions = sessions[start:end]
+	return page, nil
+}
+
+// touchSession 新消息加入后将其设为当前分支的最后一条消息,并更新会话的更新时间和预览
+func touchSession(message *Message) error {
+	fields := map[string]any{"current_message": message.Id}
+	if content := strings.TrimSpace(message.Content + " " + attachmentNotes(message)); content != "" {
+		fields["updated_at"] = message.CreatedTime
+		fields["last_message_preview"] = preview(content)
+	}
+	err := repo().UpdateSession(message.Session, fields)
+	// 通过 OpenAI 兼容接口对话时会话可能不存在
+	if errors.Is(err, persist.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// preview 合并空白并截取开头部分
+func preview(content string) string {
+	content = strings.Join(strings.Fields(content), " ")
+	if runes := []rune(content); len(runes) > previewLength {
+		return string(runes[:previewLength]) + "…"
 	}
-	return output, nil
+	return content
 }
@@ -0,0 +1,142 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+)
+
+func sessionTitles(page *SessionPage) string {
+	ids := make([]string, 0, len(page.Sessions))
+	for _, s := range page.Sessions {
+		ids = append(ids, s.Title)
+	}
+	return strings.Join(ids, ",")
+}
+
+func newTitledSession(t *testing.T, title string, updatedAt int64) *Session {
+	s := CreateSession()
+	s.Title = title
+	s.UpdatedAt = updatedAt
+	if err := repo().ReplaceSession(s); err != nil {
+		t.Fatalf("ReplaceSession failed: %v", err)
+	}
+	return s
+}
+
+func TestSessionListOrder(t *testing.T) {
+	openTestDB(t)
+	newTitledSession(t, "old", 100)
+	pinned := newTitledSession(t, "pinned", 50)
+	newTitledSession(t, "new", 300)
+	archived := newTitledSession(t, "archived", 400)
+
+	UpdateSession(pinned.Id, map[string]any{"pinned": true})
+	UpdateSession(archived.Id, map[string]any{"archived": true})
+
+	page, err := SessionList(SessionFilter{})
+	if err != nil {
+		t.Fatalf("SessionList failed: %v", err)
+	}
+	if got := sessionTitles(page); got != "pinned,new,old" || page.Total != 3 {
+		t.Errorf("Unexpected order %q, total %d", got, page.Total)
+	}
+
+	page, _ = SessionList(SessionFilter{Offset: 1, Limit: 1})
+	if got := sessionTitles(page); got != "new" || page.Total != 3 {
+		t.Errorf("Unexpected page %q, total %d", got, page.Total)
+	}
+	page, _ = SessionList(SessionFilter{Offset: 10, Limit: 1})
+	if len(page.Sessions) != 0 || page.Total != 3 {
+		t.Errorf("Expected an empty page, got %q", sessionTitles(page))
+	}
+
+	page, _ = SessionList(SessionFilter{Archived: true})
+	if got := sessionTitles(page); got != "archived" {
+		t.Errorf("Expected only archived sessions, got %q", got)
+	}
+}
+
+func TestSessionListSearch(t *testing.T) {
+	openTestDB(t)
+	tea := newTitledSession(t, "Tea notes", 1)
+	travel := newTitledSession(t, "Travel", 2)
+	newTitledSession(t, "Other", 3)
+
+	if err := MessageAppend(travel.Id, &Message{Role: "user", Content: "Which TEA should I bring to Kyoto?"}); err != nil {
+		t.Fatalf("MessageAppend failed: %v", err)
+	}
+
+	page, err := SessionList(SessionFilter{Query: " tea "})
+	if err != nil {
+		t.Fatalf("SessionList failed: %v", err)
+	}
+	if got := sessionTitles(page); got != "Travel,Tea notes" || page.Total != 2 {
+		t.Errorf("Expected title and content matches, got %q", got)
+	}
+	if page.Sessions[1].Id != tea.Id {
+		t.Errorf("Unexpected session %q", page.Sessions[1].Id)
+	}
+}
+
+func TestMessageAppendTouchesSession(t *testing.T) {
+	openTestDB(t)
+	s := CreateSession()
+	if s.CreatedAt == 0 || s.UpdatedAt != s.CreatedAt {
+		t.Fatalf("Expected timestamps on a new session, got %+v", s)
+	}
+
+	long := strings.Repeat("a ", 80) + "\n\nend"
+	message := &Message{Role: "assistant", Content: long, CreatedTime: s.CreatedAt + 1000}
+	if err := MessageAppend(s.Id, message); err != nil {
+		t.Fatalf("MessageAppend failed: %v", err)
+	}
+	if message.Session != s.Id {
+		t.Errorf("Expected the message session to be set, got %q", message.Session)
+	}
+
+	got, _ := GetSession(s.Id)
+	if got.UpdatedAt != s.CreatedAt+1000 {
+		t.Errorf("Expected updated_at %d, got %d", s.CreatedAt+1000, got.UpdatedAt)
+	}
+	if len([]rune(got.LastMessagePreview)) != previewLength+1 || strings.Contains(got.LastMessagePreview, "\n") {
+		t.Errorf("Unexpected preview %q", got.LastMessagePreview)
+	}
+
+	// 摘要不更新会话
+	MessageAppend(s.Id, &Message{Role: "system", Content: "summary", Type: MessageTypeSummary, CreatedTime: s.CreatedAt + 2000})
+	if got, _ := GetSession(s.Id); got.UpdatedAt != s.CreatedAt+1000 {
+		t.Errorf("Expected summaries not to touch the session, got %d", got.UpdatedAt)
+	}
+
+	// 会话不存在时只保存消息
+	if err := MessageAppend("missing", &Message{Role: "user", Content: "hi"}); err != nil {
+		t.Errorf("Expected messages of unknown sessions to be saved, got %v", err)
+	}
+}
+
+func TestUpdateSessionFields(t *testing.T) {
+	openTestDB(t)
+	s := CreateSession()
+
+	// 对话期间保存的字段不会被设置的修改覆盖
+	if err := repo().UpdateSession(s.Id, map[string]any{"current_message": "m2", "updated_at": 200}); err != nil {
+		t.Fatalf("UpdateSession failed: %v", err)
+	}
+	updated, err := UpdateSession(s.Id, map[string]any{"pinned": true, "model": "deepseek-chat"})
+	if err != nil {
+		t.Fatalf("UpdateSession failed: %v", err)
+	}
+	if !updated.Pinned || updated.Model != "deepseek-chat" || updated.CurrentMessage != "m2" || updated.UpdatedAt != 200 {
+		t.Errorf("Unexpected session after update: %+v", updated)
+	}
+
+	if err := DeleteSession(s.Id); err != nil {
+		t.Fatalf("DeleteSession failed: %v", err)
+	}
+	if _, err := UpdateSession(s.Id, map[string]any{"pinned": false}); err == nil {
+		t.Error("Expected sessions in the trash to be rejected")
+	}
+	if _, err := RenameSession(s.Id, "x"); err == nil {
+		t.Error("Expected renaming a session in the trash to fail")
+	}
+}
@@ -0,0 +1,120 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/AntNoHuabei/Remo/pkg/api/response"
+)
+
+// StreamRetention 生成结束后事件保留的时间,期间客户端可以重新连接获取
+const StreamRetention = 10 * time.Minute
+
+// streams 每个请求的事件缓存,request_id -> *stream
+var streams sync.Map
+
+// stream 缓存一次生成的事件,生成与 HTTP 连接无关,断线后可以从任意位置续传
+type stream struct {
+	mu     sync.Mutex
+	base   int // 第一个事件的序号,同一请求确认工具调用后继续生成时接着之前的序号
+	events []response.ChatResponse
+	closed bool
+	notify chan struct{} // 有新事件或结束时关闭并替换
+}
+
+// newStream 为请求创建新的事件缓存,替换之前的缓存
+func newStream(requestId string) *stream {
+	s := &stream{notify: make(chan struct{})}
+	if v, ok := streams.Load(requestId); ok {
+		s.base = v.(*stream).nextIndex()
+	}
+	streams.Store(requestId, s)
+	return s
+}
+
+func (s *stream) nextIndex() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.base + len(s.events)
+}
+
+// append 设置事件序号并加入缓存
+func (s *stream) append(res response.ChatResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res.Index = s.base + len(s.events)
+	s.events = append(s.events, res)
+	s.wake()
+}
+
+// close 结束事件流,保留 StreamRetention 后删除
+func (s *stream) close(requestId string) {
+	s.mu.Lock()
+	s.closed = true
+	s.wake()
+	s.mu.Unlock()
+
+	time.AfterFunc(StreamRetention, func() {
+		streams.CompareAndDelete(requestId, s)
+	})
+}
+
+func (s *stream) wake() {
+	close(s.notify)
+	s.notify = make(chan struct{})
+}
+
+// subscribe 返回序号大于 after 的事件,先回放已缓存的事件再继续接收新事件,ctx 结束时停止
+func (s *stream) subscribe(ctx context.Context, after int) <-chan response.ChatResponse {
+	ch := make(chan response.ChatResponse)
+
+	go func() {
+		defer close(ch)
+
+		next := after + 1
+		for {
+			s.mu.Lock()
+			start := max(next-s.base, 0)
+			pending := make([]response.ChatResponse, 0)
+			if start < len(s.events) {
+				pending = append(pending, s.events[start:]...)
+			}
+			closed := s.closed
+			notify := s.notify
+			s.mu.Unlock()
+
+			for _, res := range pending {
+				select {
+				case ch <- res:
+					next = res.Index + 1
+				case <-ctx.Done():
+					return
+				}
+			}
+			if len(pending) > 0 {
+				continue
+			}
+			if closed {
+				return
+			}
+			select {
+			case <-notify:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch
+}
+
+// Subscribe 订阅请求的事件,返回序号大于 lastEventId 的事件,lastEventId 为 -1 时从头开始
+func Subscribe(ctx context.Context, requestId string, lastEventId int) (<-chan response.ChatResponse, error) {
+	v, ok := streams.Load(requestId)
+	if !ok {
+		return nil, fmt.Errorf("stream not found: %s", requestId)
+	}
+	return v.(*stream).subscribe(ctx, lastEventId), nil
+}
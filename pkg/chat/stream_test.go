@@ -0,0 +1,114 @@
+package chat
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/AntNoHuabei/Remo/pkg/api/response"
+)
+
+func indexes(ch <-chan response.ChatResponse) []int {
+	output := make([]int, 0)
+	for res := range ch {
+		output = append(output, res.Index)
+	}
+	return output
+}
+
+// resetStream 测试结束后删除请求的事件缓存,避免影响重复运行时的序号
+func resetStream(t *testing.T, requestId string) {
+	streams.Delete(requestId)
+	t.Cleanup(func() {
+		streams.Delete(requestId)
+	})
+}
+
+func TestStreamReplayAndLive(t *testing.T) {
+	resetStream(t, "req-stream")
+	s := newStream("req-stream")
+	s.append(response.ChatResponse{Event: response.EventStart})
+	s.append(response.ChatResponse{Event: response.EventContentDelta, Content: "a"})
+
+	ch, err := Subscribe(context.Background(), "req-stream", 0)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if res := <-ch; res.Index != 1 || res.Content != "a" {
+		t.Fatalf("Expected replay of event 1, got %+v", res)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		s.append(response.ChatResponse{Event: response.EventContentDelta, Content: "b"})
+		s.close("req-stream")
+	}()
+	if got := indexes(ch); len(got) != 1 || got[0] != 2 {
+		t.Errorf("Expected live event 2, got %v", got)
+	}
+
+	// 同一请求的新一轮生成接着之前的序号
+	next := newStream("req-stream")
+	next.append(response.ChatResponse{Event: response.EventStart})
+	next.close("req-stream")
+	ch, _ = Subscribe(context.Background(), "req-stream", -1)
+	if got := indexes(ch); len(got) != 1 || got[0] != 3 {
+		t.Errorf("Expected index to continue at 3, got %v", got)
+	}
+
+	if _, err := Subscribe(context.Background(), "req-missing", -1); err == nil {
+		t.Error("Expected error for unknown request")
+	}
+}
+
+func TestStreamSubscriberCancel(t *testing.T) {
+	resetStream(t, "req-cancel")
+	s := newStream("req-cancel")
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := s.subscribe(ctx, -1)
+	cancel()
+
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Error("Expected no events after cancel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Subscription was not closed after cancel")
+	}
+}
+
+func TestChatSurvivesDisconnect(t *testing.T) {
+	openTestDB(t)
+	resetStream(t, "req-reconnect")
+	session := CreateSession()
+	agent := newTestAgent(t, toolCallingModel(), session.Id, &config.ToolsConfig{DefaultPolicy: config.ToolPolicyAuto})
+
+	// 模拟客户端在生成开始后立即断开
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := agent.Chat(ctx, &Message{Content: "hi", Role: "user", Session: session.Id, RequestId: "req-reconnect"}); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	cancel()
+
+	ch, err := Subscribe(context.Background(), "req-reconnect", 2)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	var content string
+	var last response.ChatResponse
+	for res := range ch {
+		if res.Index <= 2 {
+			t.Errorf("Unexpected replay of event %d", res.Index)
+		}
+		content += res.Content
+		last = res
+	}
+	if content != `result: echo {"text":"hi"}` {
+		t.Errorf("Unexpected content: %q", content)
+	}
+	if last.Done == nil || last.Done.Status != MessageStatusCompleted {
+		t.Errorf("Expected completed generation, got %+v", last)
+	}
+}
@@ -0,0 +1,146 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AntNoHuabei/Remo/internal/log"
+	"github.com/AntNoHuabei/Remo/pkg/provider"
+	"github.com/AntNoHuabei/Remo/pkg/usage"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// DefaultSessionTitle 新会话的标题,标题为该值时会在第一轮对话结束后自动生成标题
+const DefaultSessionTitle = "New Session"
+
+const (
+	titleTimeout   = 30 * time.Second
+	maxTitleLength = 50
+)
+
+const titlePrompt = `Write a short title for the conversation below, in the language %s.
+Use at most 8 words, or at most 16 characters for Chinese, Japanese and Korean. Output only the title, without quotes or trailing punctuation.`
+
+// TitleListener 会话标题变更时调用
+type TitleListener func(session *Session)
+
+var (
+	titleListeners []TitleListener
+	titleMu        sync.RWMutex
+)
+
+// OnTitleChanged 注册会话标题变更的监听,自动生成和手动修改标题时都会调用
+func OnTitleChanged(listener TitleListener) {
+	titleMu.Lock()
+	defer titleMu.Unlock()
+	titleListeners = append(titleListeners, listener)
+}
+
+func notifyTitleChanged(session *Session) {
+	titleMu.RLock()
+	defer titleMu.RUnlock()
+	for _, listener := range titleListeners {
+		listener(session)
+	}
+}
+
+// RenameSession 修改会话标题
+func RenameSession(id string, title string) (*Session, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return nil, fmt.Errorf("title is empty")
+	}
+
+	session, err := UpdateSession(id, map[string]any{"title": title})
+	if err != nil {
+		return nil, err
+	}
+	notifyTitleChanged(session)
+	return session, nil
+}
+
+// autoTitle 在后台根据第一轮对话生成会话标题,只执行一次
+func (agent *ContinuousAgent) autoTitle(assistant *Message) {
+	if !agent.untitled || agent.chatModel == nil {
+		return
+	}
+	agent.untitled = false
+
+	var user *Message
+	for _, m := range agent.history {
+		if m.Role == "user" {
+			user = m
+			break
+		}
+	}
+	if user == nil {
+		return
+	}
+
+	cm, m, titleModel, language, session := agent.chatModel, agent.model, agent.titleModel, agent.language, agent.session
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), titleTimeout)
+		defer cancel()
+
+		// 优先使用配置的低成本模型
+		if titleModel != "" {
+			if tcm, tm, err := provider.Get().NewChatModel(ctx, titleModel); err == nil {
+				cm, m = tcm, tm
+			} else {
+				log.Warn("failed to create title model", "model", titleModel, "error", err)
+			}
+		}
+
+		title, err := generateTitle(ctx, Meter(cm, m, session, usage.PurposeTitle), language, user.Content, assistant.Content)
+		if err != nil {
+			log.Warn("failed to generate session title", "session", session, "error", err)
+			return
+		}
+
+		// 生成期间用户可能已经手动修改了标题
+		s, err := GetSession(session)
+		if err != nil || s.Title != DefaultSessionTitle {
+			return
+		}
+		// 只更新标题,避免覆盖生成期间对会话其他字段的修改
+		if err := repo().UpdateSession(session, map[string]any{"title": title}); err != nil {
+			log.Warn("failed to save session title", "session", session, "error", err)
+			return
+		}
+		s.Title = title
+		notifyTitleChanged(s)
+	}()
+}
+
+func generateTitle(ctx context.Context, cm model.BaseChatModel, language string, user string, assistant string) (string, error) {
+	if language == "" {
+		language = "zh-CN"
+	}
+	msg, err := cm.Generate(ctx, []*schema.Message{
+		schema.SystemMessage(fmt.Sprintf(titlePrompt, language)),
+		schema.UserMessage("User: " + user + "\n\nAssistant: " + assistant),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	title := strings.TrimSpace(msg.Content)
+	if i := strings.IndexByte(title, '\n'); i >= 0 {
+		title = title[:i]
+	}
+	// 标点可能在引号内外
+	title = strings.TrimRight(title, " .。!！?？")
+	title = strings.Trim(title, " \t\"'“”‘’「」《》#*")
+	title = strings.TrimRight(title, " .。!！?？")
+	if runes := []rune(title); len(runes) > maxTitleLength {
+		title = string(runes[:maxTitleLength])
+	}
+	if title == "" {
+		return "", fmt.Errorf("model returned an empty title")
+	}
+	return title, nil
+}
@@ -0,0 +1,90 @@
+package chat
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/AntNoHuabei/Remo/pkg/provider"
+)
+
+// watchTitle 返回指定会话的标题变更
+func watchTitle(session string) <-chan string {
+	ch := make(chan string, 1)
+	OnTitleChanged(func(s *Session) {
+		if s.Id == session {
+			ch <- s.Title
+		}
+	})
+	return ch
+}
+
+func TestGenerateTitle(t *testing.T) {
+	cases := map[string]string{
+		"Tea preferences":         "Tea preferences",
+		"“Tea preferences”.":      "Tea preferences",
+		"## 喝茶偏好。\nmore text":     "喝茶偏好",
+		" \"Planning a trip\"!  ": "Planning a trip",
+	}
+	for reply, expected := range cases {
+		title, err := generateTitle(context.Background(), replyModel(reply, nil), "en-US", "hi", "hello")
+		if err != nil || title != expected {
+			t.Errorf("generateTitle(%q) = %q %v, expected %q", reply, title, err, expected)
+		}
+	}
+	if _, err := generateTitle(context.Background(), replyModel(`""`, nil), "en-US", "hi", "hello"); err == nil {
+		t.Error("Expected an error for an empty title")
+	}
+}
+
+func TestAutoTitle(t *testing.T) {
+	openTestDB(t)
+	session := CreateSession()
+	titles := watchTitle(session.Id)
+
+	agent := &ContinuousAgent{
+		chatModel: replyModel("Tea preferences", nil),
+		model:     &provider.Model{Name: "test/title"},
+		session:   session.Id,
+		untitled:  true,
+		history:   []*Message{{Role: "user", Content: "I like tea"}},
+	}
+	agent.autoTitle(&Message{Role: "assistant", Content: "Noted"})
+
+	select {
+	case title := <-titles:
+		if title != "Tea preferences" {
+			t.Errorf("Unexpected title %q", title)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the title")
+	}
+	if s, _ := GetSession(session.Id); s.Title != "Tea preferences" {
+		t.Errorf("Expected the title to be saved, got %q", s.Title)
+	}
+
+	// 只生成一次
+	if agent.untitled {
+		t.Error("Expected the agent to be marked as titled")
+	}
+}
+
+func TestRenameSession(t *testing.T) {
+	openTestDB(t)
+	session := CreateSession()
+	titles := watchTitle(session.Id)
+
+	if _, err := RenameSession(session.Id, "  "); err == nil {
+		t.Error("Expected an empty title to be rejected")
+	}
+	renamed, err := RenameSession(session.Id, " My notes ")
+	if err != nil {
+		t.Fatalf("RenameSession failed: %v", err)
+	}
+	if renamed.Title != "My notes" || <-titles != "My notes" {
+		t.Errorf("Unexpected title %q", renamed.Title)
+	}
+	if _, err := RenameSession("missing", "x"); err == nil {
+		t.Error("Expected renaming a missing session to fail")
+	}
+}
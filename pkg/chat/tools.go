@@ -0,0 +1,114 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/schema"
+)
+
+// ToolConfirmation 等待用户确认的工具调用,作为中断信息返回给调用方
+type ToolConfirmation struct {
+	Tool      string `json:"tool"`
+	Arguments string `json:"arguments"`
+}
+
+// ToolDecision 用户对工具调用的决定,作为恢复数据传入
+type ToolDecision struct {
+	Approved bool   `json:"approved"`
+	Reason   string `json:"reason"`
+}
+
+func init() {
+	// 中断信息会随断点一起序列化
+	schema.RegisterName[*ToolConfirmation]("_remo_tool_confirmation")
+}
+
+// ToolSource 动态提供工具的来源,如 MCP 服务器
+type ToolSource func(ctx context.Context) []tool.InvokableTool
+
+var (
+	registeredTools []tool.InvokableTool
+	toolSources     []ToolSource
+	toolsMu         sync.RWMutex
+)
+
+// RegisterTool 注册可供智能体使用的工具
+func RegisterTool(tools ...tool.InvokableTool) {
+	toolsMu.Lock()
+	defer toolsMu.Unlock()
+	registeredTools = append(registeredTools, tools...)
+}
+
+// RegisterToolSource 注册工具来源,每次构建智能体时重新获取其中的工具
+func RegisterToolSource(source ToolSource) {
+	toolsMu.Lock()
+	defer toolsMu.Unlock()
+	toolSources = append(toolSources, source)
+}
+
+// agentTools 按工具策略返回智能体可用的工具,deny 的工具不会提供给模型,ask 的工具执行前需要确认
+func agentTools(ctx context.Context, cfg config.ToolsConfig) ([]tool.BaseTool, error) {
+	toolsMu.RLock()
+	defer toolsMu.RUnlock()
+
+	all := append([]tool.InvokableTool{}, registeredTools...)
+	for _, source := range toolSources {
+		all = append(all, source(ctx)...)
+	}
+
+	output := make([]tool.BaseTool, 0, len(all))
+	for _, t := range all {
+		info, err := t.Info(ctx)
+		if err != nil {
+			return nil, err
+		}
+		switch cfg.Policy(info.Name) {
+		case config.ToolPolicyDeny:
+			continue
+		case config.ToolPolicyAuto:
+			output = append(output, t)
+		default:
+			output = append(output, &confirmTool{InvokableTool: t, name: info.Name})
+		}
+	}
+	return output, nil
+}
+
+// confirmTool 执行前中断运行,等待用户通过 Confirm 作出决定
+type confirmTool struct {
+	tool.InvokableTool
+	name string
+}
+
+func (t *confirmTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	info := &ToolConfirmation{
+		Tool:      t.name,
+		Arguments: argumentsInJSON,
+	}
+
+	wasInterrupted, _, _ := compose.GetInterruptState[any](ctx)
+	if !wasInterrupted {
+		return "", compose.StatefulInterrupt(ctx, info, argumentsInJSON)
+	}
+
+	isTarget, hasData, decision := compose.GetResumeContext[*ToolDecision](ctx)
+	if !isTarget {
+		// 恢复的是其他工具调用,继续等待确认
+		return "", compose.StatefulInterrupt(ctx, info, argumentsInJSON)
+	}
+
+	if !hasData || decision == nil || !decision.Approved {
+		reason := "no reason given"
+		if decision != nil && decision.Reason != "" {
+			reason = decision.Reason
+		}
+		return fmt.Sprintf("The user denied the call to %s: %s", t.name, reason), nil
+	}
+
+	return t.InvokableTool.InvokableRun(ctx, argumentsInJSON, opts...)
+}
@@ -0,0 +1,84 @@
+package chat
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/cloudwego/eino/components/tool"
+)
+
+func TestToolConfirmation(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	session := CreateSession()
+
+	agent := newTestAgent(t, toolCallingModel(), session.Id, &config.ToolsConfig{DefaultPolicy: config.ToolPolicyAsk})
+	out, _ := agent.Chat(ctx, &Message{Content: "hi", Role: "user", Session: session.Id, RequestId: "req-1"})
+	c := collect(out)
+	if len(c.confirmations) != 1 || c.confirmations[0].Tool != "echo" {
+		t.Fatalf("Expected one confirmation for echo, got %+v", c.confirmations)
+	}
+	if len(c.toolCalls) != 1 || c.toolCalls[0].Arguments != `{"text":"hi"}` {
+		t.Errorf("Expected one tool_call event, got %+v", c.toolCalls)
+	}
+	confirmations := c.confirmations
+
+	// 新的智能体实例从断点恢复,模拟 /chat/confirm 请求
+	agent = newTestAgent(t, toolCallingModel(), session.Id, &config.ToolsConfig{DefaultPolicy: config.ToolPolicyAsk})
+	out, err := agent.Confirm(ctx, "req-1", confirmations[0].InterruptId, &ToolDecision{Approved: true})
+	if err != nil {
+		t.Fatalf("Failed to confirm: %v", err)
+	}
+	c = collect(out)
+	if c.content != `result: echo {"text":"hi"}` {
+		t.Errorf("Unexpected content after approval: %q", c.content)
+	}
+	if len(c.toolResults) != 1 || c.toolResults[0].Id != "call-1" || c.toolResults[0].Name != "echo" {
+		t.Errorf("Expected one tool_result event for call-1, got %+v", c.toolResults)
+	}
+}
+
+func TestToolConfirmationDenied(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	session := CreateSession()
+
+	agent := newTestAgent(t, toolCallingModel(), session.Id, &config.ToolsConfig{})
+	out, _ := agent.Chat(ctx, &Message{Content: "hi", Role: "user", Session: session.Id, RequestId: "req-1"})
+	confirmations := collect(out).confirmations
+	if len(confirmations) != 1 {
+		t.Fatalf("Expected one confirmation, got %d", len(confirmations))
+	}
+
+	out, err := agent.Confirm(ctx, "req-1", confirmations[0].InterruptId, &ToolDecision{Approved: false, Reason: "not now"})
+	if err != nil {
+		t.Fatalf("Failed to confirm: %v", err)
+	}
+	content := collect(out).content
+	if !strings.Contains(content, "denied") || !strings.Contains(content, "not now") {
+		t.Errorf("Expected denial to be passed to the model, got %q", content)
+	}
+}
+
+func TestToolPolicyAutoAndDeny(t *testing.T) {
+	ctx := context.Background()
+	registeredTools = []tool.InvokableTool{echoTool{}}
+	defer func() {
+		registeredTools = nil
+	}()
+
+	tools, _ := agentTools(ctx, config.ToolsConfig{Policies: map[string]config.ToolPolicy{"echo": config.ToolPolicyAuto}})
+	if len(tools) != 1 {
+		t.Fatalf("Expected 1 tool, got %d", len(tools))
+	}
+	if _, ok := tools[0].(*confirmTool); ok {
+		t.Errorf("Expected echo to run without confirmation")
+	}
+
+	tools, _ = agentTools(ctx, config.ToolsConfig{Policies: map[string]config.ToolPolicy{"echo": config.ToolPolicyDeny}})
+	if len(tools) != 0 {
+		t.Errorf("Expected denied tool to be removed, got %d tools", len(tools))
+	}
+}
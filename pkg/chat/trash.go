@@ -0,0 +1,140 @@
+package chat
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AntNoHuabei/Remo/internal/log"
+	"github.com/AntNoHuabei/Remo/pkg/persist"
+)
+
+// maintenanceInterval 定期维护的间隔
+const maintenanceInterval = 24 * time.Hour
+
+// sessionMu 保证删除、恢复和彻底删除会话时三个集合的状态一致
+var sessionMu sync.Mutex
+
+// DeleteSession 将会话移入回收站,消息和断点保留到会话被彻底删除
+func DeleteSession(id string) error {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+
+	return setDeletedAt(id, time.Now().UnixMilli())
+}
+
+// RestoreSession 从回收站恢复会话
+func RestoreSession(id string) error {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+
+	return setDeletedAt(id, 0)
+}
+
+func setDeletedAt(id string, deletedAt int64) error {
+	if _, err := GetSession(id); err != nil {
+		return err
+	}
+	return repo().UpdateSession(id, map[string]any{
+		"deleted_at": deletedAt,
+	})
+}
+
+// PurgeSession 彻底删除会话及其消息、断点和附件
+func PurgeSession(id string) error {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+
+	return repo().PurgeSession(id)
+}
+
+// TrashList 返回回收站中的会话,最近删除的在前
+func TrashList() ([]Session, error) {
+	trashed, err := repo().DeletedSessions(0)
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions = make([]Session, 0, len(trashed))
+	for _, session := range trashed {
+		sessions = append(sessions, *session)
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].DeletedAt > sessions[j].DeletedAt
+	})
+	return sessions, nil
+}
+
+// PurgeTrash 彻底删除在回收站中超过 retention 的会话,返回删除的数量
+func PurgeTrash(retention time.Duration) (int, error) {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+
+	expired, err := repo().DeletedSessions(time.Now().Add(-retention).UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+
+	for i, session := range expired {
+		if err := repo().PurgeSession(session.Id); err != nil {
+			return i, err
+		}
+	}
+	return len(expired), nil
+}
+
+// CleanOrphans 清理会话已不存在的消息、断点和附件,包括旧版本删除会话时遗留的数据和没有会话的消息,
+// 返回清理的消息数、断点数和附件数
+func CleanOrphans() (messages int, checkpoints int, attachments int, err error) {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+
+	return repo().CleanOrphans()
+}
+
+// Maintain 清理过期断点、回收站中过期的会话和孤儿数据,retention 小于等于 0 时不自动清空回收站。
+// 存储没有解锁时跳过,等下一次维护
+func Maintain(retention time.Duration) {
+	if persist.Locked() {
+		log.Info("storage is locked, skipping maintenance")
+		return
+	}
+	if n, err := CleanCheckpoints(DefaultCheckpointTTL); err != nil {
+		log.Warn("failed to clean checkpoints", "error", err)
+	} else if n > 0 {
+		log.Info("cleaned expired checkpoints", "count", n)
+	}
+
+	if retention > 0 {
+		if n, err := PurgeTrash(retention); err != nil {
+			log.Warn("failed to purge trash", "error", err)
+		} else if n > 0 {
+			log.Info("purged expired sessions from trash", "count", n)
+		}
+	}
+
+	if messages, checkpoints, attachments, err := CleanOrphans(); err != nil {
+		log.Warn("failed to clean orphans", "error", err)
+	} else if messages > 0 || checkpoints > 0 || attachments > 0 {
+		log.Info("cleaned orphaned data", "messages", messages, "checkpoints", checkpoints, "attachments", attachments)
+	}
+}
+
+// StartMaintenance 立即执行一次维护,之后每天执行一次
+func StartMaintenance(retention func() time.Duration) {
+	go func() {
+		for {
+			Maintain(retention())
+			time.Sleep(maintenanceInterval)
+		}
+	}()
+}
+
+// CheckActive 会话在回收站中时返回错误,回收站中的会话不能继续对话
+func (s *Session) CheckActive() error {
+	if s.DeletedAt != 0 {
+		return fmt.Errorf("session is in the trash: %s", s.Id)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package chat
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/AntNoHuabei/Remo/pkg/persist"
+	"github.com/google/uuid"
+)
+
+// newSessionWithData 创建带消息和断点的会话
+func newSessionWithData(t *testing.T) *Session {
+	s := CreateSession()
+	if err := MessageAppend(s.Id, &Message{Role: "user", Content: "hello"}); err != nil {
+		t.Fatalf("MessageAppend failed: %v", err)
+	}
+	store, _ := NewStore(s.Id)
+	if err := store.Set(context.Background(), "cp", []byte("data")); err != nil {
+		t.Fatalf("Failed to save checkpoint: %v", err)
+	}
+	return s
+}
+
+func countDocs(t *testing.T, collection string) int {
+	n, err := persist.DB.Query(collection).Count()
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	return n
+}
+
+func TestDeleteAndRestoreSession(t *testing.T) {
+	openTestDB(t)
+	s := newSessionWithData(t)
+
+	if err := DeleteSession(s.Id); err != nil {
+		t.Fatalf("DeleteSession failed: %v", err)
+	}
+	if page, _ := SessionList(SessionFilter{}); page.Total != 0 {
+		t.Errorf("Expected trashed sessions to be hidden, got %d", page.Total)
+	}
+	trash, err := TrashList()
+	if err != nil || len(trash) != 1 || trash[0].DeletedAt == 0 {
+		t.Fatalf("Expected the session in the trash, got %v %+v", err, trash)
+	}
+	if err := trash[0].CheckActive(); err == nil {
+		t.Error("Expected trashed sessions to be inactive")
+	}
+	if messages, _ := Messages(s.Id); len(messages) != 1 {
+		t.Errorf("Expected messages to be kept in the trash, got %d", len(messages))
+	}
+
+	if err := RestoreSession(s.Id); err != nil {
+		t.Fatalf("RestoreSession failed: %v", err)
+	}
+	if page, _ := SessionList(SessionFilter{}); page.Total != 1 {
+		t.Errorf("Expected the restored session to be listed, got %d", page.Total)
+	}
+	if trash, _ := TrashList(); len(trash) != 0 {
+		t.Errorf("Expected an empty trash, got %+v", trash)
+	}
+	if err := DeleteSession("missing"); err == nil {
+		t.Error("Expected deleting a missing session to fail")
+	}
+}
+
+func TestPurgeSession(t *testing.T) {
+	openTestDB(t)
+	s := newSessionWithData(t)
+	other := newSessionWithData(t)
+
+	if err := PurgeSession(s.Id); err != nil {
+		t.Fatalf("PurgeSession failed: %v", err)
+	}
+	if _, err := GetSession(s.Id); err == nil {
+		t.Error("Expected the session to be deleted")
+	}
+	if n := countDocs(t, persist.Message); n != 1 {
+		t.Errorf("Expected only the other session's message, got %d", n)
+	}
+	if n := countDocs(t, persist.SessionCheckpoint); n != 1 {
+		t.Errorf("Expected only the other session's checkpoint, got %d", n)
+	}
+	if messages, _ := Messages(other.Id); len(messages) != 1 {
+		t.Errorf("Expected other sessions to be untouched, got %d messages", len(messages))
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	openTestDB(t)
+	expired := newSessionWithData(t)
+	recent := newSessionWithData(t)
+	newSessionWithData(t)
+
+	DeleteSession(expired.Id)
+	DeleteSession(recent.Id)
+	persist.DB.Query(persist.Conversation).UpdateById(expired.Id, map[string]any{
+		"deleted_at": time.Now().Add(-48 * time.Hour).UnixMilli(),
+	})
+
+	n, err := PurgeTrash(24 * time.Hour)
+	if err != nil || n != 1 {
+		t.Fatalf("Expected one purged session, got %d %v", n, err)
+	}
+	if _, err := GetSession(expired.Id); err == nil {
+		t.Error("Expected the expired session to be purged")
+	}
+	if trash, _ := TrashList(); len(trash) != 1 || trash[0].Id != recent.Id {
+		t.Errorf("Expected the recent session to stay in the trash, got %+v", trash)
+	}
+	if n := countDocs(t, persist.Message); n != 2 {
+		t.Errorf("Expected 2 messages left, got %d", n)
+	}
+}
+
+func TestCleanOrphans(t *testing.T) {
+	openTestDB(t)
+	s := newSessionWithData(t)
+	legacy := newSessionWithData(t)
+
+	// 旧版本删除会话时只删除会话文档
+	persist.DB.Query(persist.Conversation).DeleteById(legacy.Id)
+	// 旧版本保存的助手消息没有会话
+	id := uuid.New().String()
+	doc, _ := persist.NewDocument(&Message{Id: id, Role: "assistant", Content: "hi"})
+	doc.Set("_id", id)
+	persist.DB.InsertOne(persist.Message, doc)
+
+	messages, checkpoints, _, err := CleanOrphans()
+	if err != nil {
+		t.Fatalf("CleanOrphans failed: %v", err)
+	}
+	if messages != 2 || checkpoints != 1 {
+		t.Errorf("Expected 2 messages and 1 checkpoint, got %d and %d", messages, checkpoints)
+	}
+	if got, _ := Messages(s.Id); len(got) != 1 {
+		t.Errorf("Expected messages of existing sessions to be kept, got %d", len(got))
+	}
+	if n := countDocs(t, persist.SessionCheckpoint); n != 1 {
+		t.Errorf("Expected 1 checkpoint left, got %d", n)
+	}
+}
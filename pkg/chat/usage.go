@@ -0,0 +1,127 @@
+package chat
+
+import (
+	"context"
+	"io"
+
+	"github.com/AntNoHuabei/Remo/internal/log"
+	"github.com/AntNoHuabei/Remo/pkg/provider"
+	"github.com/AntNoHuabei/Remo/pkg/usage"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// meteredModel 将每次模型调用的 token 用量记入账本
+type meteredModel struct {
+	cm      model.BaseChatModel
+	model   *provider.Model
+	session string
+	purpose string
+}
+
+// Meter 包装模型,每次调用后将用量记入账本
+func Meter(cm model.BaseChatModel, m *provider.Model, session string, purpose string) model.BaseChatModel {
+	return &meteredModel{cm: cm, model: m, session: session, purpose: purpose}
+}
+
+// MeterTools 与 Meter 相同,绑定工具后的模型同样记录用量
+func MeterTools(cm model.ToolCallingChatModel, m *provider.Model, session string, purpose string) model.ToolCallingChatModel {
+	return &meteredToolModel{meteredModel: meteredModel{cm: cm, model: m, session: session, purpose: purpose}, tcm: cm}
+}
+
+func (m *meteredModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	msg, err := m.cm.Generate(ctx, input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	m.record(msg)
+	return msg, nil
+}
+
+// Stream 转发模型的输出,在流结束或被关闭时记录用量,读取方收到 io.EOF 前用量已经记录
+func (m *meteredModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	sr, err := m.cm.Stream(ctx, input, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, writer := schema.Pipe[*schema.Message](1)
+	go func() {
+		defer sr.Close()
+		defer writer.Close()
+
+		chunks := make([]*schema.Message, 0)
+		defer func() {
+			if full, err := ConcatMessages(chunks); err == nil {
+				m.record(full)
+			}
+		}()
+		for {
+			chunk, err := sr.Recv()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				writer.Send(nil, err)
+				return
+			}
+			chunks = append(chunks, chunk)
+			if closed := writer.Send(chunk, nil); closed {
+				return
+			}
+		}
+	}()
+	return reader, nil
+}
+
+func (m *meteredModel) record(msg *schema.Message) {
+	if m.model == nil {
+		return
+	}
+	r := &usage.Record{
+		Session:  m.session,
+		Provider: m.model.Provider.Name,
+		Model:    m.model.Name,
+		Purpose:  m.purpose,
+	}
+	if msg != nil && msg.ResponseMeta != nil && msg.ResponseMeta.Usage != nil {
+		u := msg.ResponseMeta.Usage
+		r.PromptTokens = u.PromptTokens
+		r.CompletionTokens = u.CompletionTokens
+		r.ReasoningTokens = u.CompletionTokensDetails.ReasoningTokens
+		r.TotalTokens = u.TotalTokens
+	}
+	r.Cost = usage.Cost(m.model.Define, r.PromptTokens, r.CompletionTokens)
+
+	if err := usage.Get().Add(r); err != nil {
+		log.Warn("failed to record usage", "model", r.Model, "error", err)
+	}
+}
+
+type meteredToolModel struct {
+	meteredModel
+	tcm model.ToolCallingChatModel
+}
+
+func (m *meteredToolModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	tcm, err := m.tcm.WithTools(tools)
+	if err != nil {
+		return nil, err
+	}
+	return MeterTools(tcm, m.model, m.session, m.purpose), nil
+}
+
+// checkBudget 检查预算,超出预算时按配置返回错误或在本轮发送提醒
+func (agent *ContinuousAgent) checkBudget() error {
+	ledger := usage.Get()
+	budget, err := ledger.CheckBudget()
+	if err != nil {
+		log.Warn("failed to check budget", "error", err)
+		return nil
+	}
+	if budget != nil && ledger.Blocking() {
+		return budget
+	}
+	agent.budget = budget
+	return nil
+}
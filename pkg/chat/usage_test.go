@@ -0,0 +1,105 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/AntNoHuabei/Remo/pkg/api/response"
+	"github.com/AntNoHuabei/Remo/pkg/persist"
+	"github.com/AntNoHuabei/Remo/pkg/provider"
+	"github.com/AntNoHuabei/Remo/pkg/usage"
+)
+
+func initLedger(t *testing.T, cfg config.UsageConfig) {
+	usage.Init(cfg)
+	t.Cleanup(func() {
+		usage.Init(config.UsageConfig{})
+	})
+}
+
+func usageRecords(t *testing.T) []*usage.Record {
+	docs, err := persist.DB.Query(persist.Usage).FindAll()
+	if err != nil {
+		t.Fatalf("Failed to read usage: %v", err)
+	}
+	output := make([]*usage.Record, 0, len(docs))
+	for _, doc := range docs {
+		var r usage.Record
+		persist.Unmarshal(doc, &r)
+		output = append(output, &r)
+	}
+	return output
+}
+
+func TestMeterStream(t *testing.T) {
+	openTestDB(t)
+	m := &provider.Model{
+		Name:     "test/reasoning",
+		Provider: config.ProviderConfig{Name: "test"},
+		Define:   config.ChatModelDefine{InputPrice: 1, OutputPrice: 2},
+	}
+
+	sr, err := MeterTools(reasoningModel(), m, "s1", usage.PurposeChat).Stream(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	content := ""
+	for {
+		chunk, err := sr.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		content += chunk.Content
+	}
+	sr.Close()
+	if content != "Hello!" {
+		t.Errorf("Expected the stream to be forwarded, got %q", content)
+	}
+
+	// 读到流结束时用量已经记录
+	records := usageRecords(t)
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 usage record, got %d", len(records))
+	}
+	r := records[0]
+	if r.Session != "s1" || r.Provider != "test" || r.Model != "test/reasoning" || r.Purpose != usage.PurposeChat {
+		t.Errorf("Unexpected record %+v", r)
+	}
+	if r.PromptTokens != 12 || r.CompletionTokens != 8 || r.ReasoningTokens != 5 || r.TotalTokens != 20 {
+		t.Errorf("Unexpected tokens %+v", r)
+	}
+	if expected := (12*1 + 8*2) / 1e6; r.Cost != expected {
+		t.Errorf("Expected cost %v, got %v", expected, r.Cost)
+	}
+}
+
+func TestChatBudget(t *testing.T) {
+	openTestDB(t)
+	usage.Get().Add(&usage.Record{Cost: 5})
+	agent := newTestAgent(t, echoModel(), "", nil)
+
+	// 提醒后继续对话
+	initLedger(t, config.UsageConfig{DailyBudget: 1, BudgetAction: config.BudgetActionWarn})
+	events := collectEvents(mustChat(t, agent, "hi"))
+	if events[1].Event != response.EventBudget || events[1].Budget.Spent != 5 || events[1].Budget.Period != "daily" {
+		t.Errorf("Expected a budget warning after start, got %+v", events[1])
+	}
+	if done := events[len(events)-1].Done; done == nil || done.Status != MessageStatusCompleted {
+		t.Errorf("Expected the reply to complete, got %+v", done)
+	}
+
+	// 拒绝对话,不保存用户消息
+	initLedger(t, config.UsageConfig{DailyBudget: 1, BudgetAction: config.BudgetActionBlock})
+	if _, err := agent.Chat(context.Background(), &Message{Role: "user", Content: "again"}); !errors.Is(err, usage.ErrBudgetExceeded) {
+		t.Fatalf("Expected the budget error, got %v", err)
+	}
+	if messages, _ := Messages(agent.session); len(messages) != 2 {
+		t.Errorf("Expected the refused message not to be saved, got %d messages", len(messages))
+	}
+}
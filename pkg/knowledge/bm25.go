@@ -0,0 +1,115 @@
+package knowledge
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// BM25 参数
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// bm25 片段的关键词索引
+type bm25 struct {
+	docs   []map[string]int // 每个片段的词频
+	lens   []int
+	df     map[string]int // 包含该词的片段数
+	avgLen float64
+}
+
+func newBM25(texts []string) *bm25 {
+	idx := &bm25{
+		docs: make([]map[string]int, len(texts)),
+		lens: make([]int, len(texts)),
+		df:   make(map[string]int),
+	}
+	total := 0
+	for i, text := range texts {
+		tf := make(map[string]int)
+		tokens := tokenize(text)
+		for _, token := range tokens {
+			tf[token]++
+		}
+		for token := range tf {
+			idx.df[token]++
+		}
+		idx.docs[i] = tf
+		idx.lens[i] = len(tokens)
+		total += len(tokens)
+	}
+	if len(texts) > 0 {
+		idx.avgLen = float64(total) / float64(len(texts))
+	}
+	return idx
+}
+
+// scores 返回每个片段与查询的 BM25 分数
+func (idx *bm25) scores(query string) []float64 {
+	output := make([]float64, len(idx.docs))
+	n := float64(len(idx.docs))
+	seen := make(map[string]bool)
+	for _, token := range tokenize(query) {
+		if seen[token] || idx.df[token] == 0 {
+			continue
+		}
+		seen[token] = true
+
+		df := float64(idx.df[token])
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for i, tf := range idx.docs {
+			f := float64(tf[token])
+			if f == 0 {
+				continue
+			}
+			norm := 1 - bm25B + bm25B*float64(idx.lens[i])/idx.avgLen
+			output[i] += idf * f * (bm25K1 + 1) / (f + bm25K1*norm)
+		}
+	}
+	return output
+}
+
+// tokenize 英文等按词切分并转为小写,中日韩文字按相邻两字切分
+func tokenize(text string) []string {
+	output := make([]string, 0)
+	var word []rune
+	var cjk []rune
+	flushWord := func() {
+		if len(word) > 0 {
+			output = append(output, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+	flushCJK := func() {
+		if len(cjk) == 1 {
+			output = append(output, string(cjk))
+		}
+		for i := 0; i+1 < len(cjk); i++ {
+			output = append(output, string(cjk[i:i+2]))
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			flushCJK()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return output
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
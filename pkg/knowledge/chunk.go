@@ -0,0 +1,63 @@
+package knowledge
+
+import (
+	"strings"
+	"unicode"
+)
+
+// splitChunks 将文本切分为不超过 size 个字符的片段,相邻片段重叠 overlap 个字符
+// 优先在段落、换行、句末和空白处切分
+func splitChunks(text string, size int, overlap int) []string {
+	if size <= 0 {
+		size = defaultChunkSize
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+
+	runes := []rune(strings.TrimSpace(text))
+	output := make([]string, 0)
+	for start := 0; start < len(runes); {
+		end := start + size
+		if end >= len(runes) {
+			end = len(runes)
+		} else {
+			end = breakPoint(runes, start+size/2, end)
+		}
+
+		if chunk := strings.TrimSpace(string(runes[start:end])); chunk != "" {
+			output = append(output, chunk)
+		}
+		if end == len(runes) {
+			break
+		}
+
+		next := end - overlap
+		if next <= start {
+			next = end
+		}
+		// 重叠部分从词的开头开始
+		for next < end && !unicode.IsSpace(runes[next-1]) && !isCJK(runes[next]) {
+			next++
+		}
+		start = next
+	}
+	return output
+}
+
+// breakPoint 在 [min, max) 之间从后往前寻找最合适的切分位置,找不到时返回 max
+func breakPoint(runes []rune, min int, max int) int {
+	for _, sep := range []func(i int) bool{
+		func(i int) bool { return i >= 2 && runes[i-1] == '\n' && runes[i-2] == '\n' },
+		func(i int) bool { return runes[i-1] == '\n' },
+		func(i int) bool { return strings.ContainsRune(".!?。！？；;", runes[i-1]) },
+		func(i int) bool { return unicode.IsSpace(runes[i-1]) },
+	} {
+		for i := max; i > min; i-- {
+			if sep(i) {
+				return i
+			}
+		}
+	}
+	return max
+}
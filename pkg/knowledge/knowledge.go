@@ -0,0 +1,242 @@
+package knowledge
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/AntNoHuabei/Remo/internal/log"
+	"github.com/AntNoHuabei/Remo/pkg/persist"
+	"github.com/AntNoHuabei/Remo/pkg/provider"
+	"github.com/google/uuid"
+	"github.com/ostafen/clover"
+)
+
+const (
+	defaultChunkSize   = 800
+	defaultTopK        = 4
+	defaultMaxFileSize = 20
+	// embedBatch 每次请求向量模型的片段数,部分服务商限制为 10
+	embedBatch = 10
+)
+
+var (
+	globalBase *Base
+	baseMu     sync.RWMutex
+)
+
+// Document 知识库中的一个文件
+type Document struct {
+	Id             string `json:"id"`
+	Name           string `json:"name"`
+	Size           int    `json:"size"`            // 文件大小(字节)
+	Chunks         int    `json:"chunks"`          // 片段数
+	EmbeddingModel string `json:"embedding_model"` // 为空表示没有向量,只能通过关键词检索
+	CreatedTime    int64  `json:"created_time"`
+}
+
+// Chunk 文件的一个片段
+type Chunk struct {
+	Id             string    `json:"id"`
+	Document       string    `json:"document"`
+	Index          int       `json:"index"` // 片段在文件中的序号
+	Content        string    `json:"content"`
+	Embedding      []float64 `json:"embedding"`
+	EmbeddingModel string    `json:"embedding_model"`
+}
+
+// Base 本地知识库,文件切分后的片段和向量保存在 clover 中,检索时在内存中建立索引
+type Base struct {
+	cfg      config.KnowledgeConfig
+	embedder provider.EmbedderFunc
+	mu       sync.Mutex
+	index    *index // 为空时在下次检索前重建
+}
+
+// NewBase 根据配置创建知识库,向量模型从 provider 注册表获取
+func NewBase(cfg config.KnowledgeConfig) *Base {
+	return &Base{
+		cfg:      cfg,
+		embedder: provider.RegistryEmbedder(cfg.Embedding),
+	}
+}
+
+// Init 创建全局知识库
+func Init(cfg config.KnowledgeConfig) *Base {
+	b := NewBase(cfg)
+
+	baseMu.Lock()
+	globalBase = b
+	baseMu.Unlock()
+	return b
+}
+
+// Get 获取全局知识库,未初始化时返回禁用的知识库
+func Get() *Base {
+	baseMu.RLock()
+	defer baseMu.RUnlock()
+	if globalBase == nil {
+		return &Base{}
+	}
+	return globalBase
+}
+
+// Enabled 是否启用知识库
+func (b *Base) Enabled() bool {
+	return b.cfg.Enabled
+}
+
+// Add 提取文件文本,切分并生成向量后加入知识库,向量模型不可用时只建立关键词索引
+func (b *Base) Add(ctx context.Context, name string, data []byte) (*Document, error) {
+	if !b.cfg.Enabled {
+		return nil, fmt.Errorf("knowledge base is disabled")
+	}
+	if err := b.checkSize(name, int64(len(data))); err != nil {
+		return nil, err
+	}
+
+	text, err := extractText(name, data)
+	if err != nil {
+		return nil, err
+	}
+	contents := splitChunks(text, b.cfg.ChunkSize, b.cfg.ChunkOverlap)
+
+	doc := &Document{
+		Id:          uuid.New().String(),
+		Name:        name,
+		Size:        len(data),
+		Chunks:      len(contents),
+		CreatedTime: time.Now().UnixMilli(),
+	}
+	vectors, modelName, err := b.embed(ctx, contents)
+	if err != nil {
+		log.Warn("failed to embed document, only keyword search is available", "document", name, "error", err)
+	} else {
+		doc.EmbeddingModel = modelName
+	}
+
+	docs := make([]*clover.Document, 0, len(contents))
+	for i, content := range contents {
+		chunk := &Chunk{
+			Id:       uuid.New().String(),
+			Document: doc.Id,
+			Index:    i,
+			Content:  content,
+		}
+		if vectors != nil {
+			chunk.Embedding = vectors[i]
+			chunk.EmbeddingModel = modelName
+		}
+		d, err := persist.NewSealedDocument(persist.KnowledgeChunk, chunk)
+		if err != nil {
+			return nil, err
+		}
+		d.Set("_id", chunk.Id)
+		docs = append(docs, d)
+	}
+
+	d, err := persist.NewSealedDocument(persist.KnowledgeDocument, doc)
+	if err != nil {
+		return nil, err
+	}
+	d.Set("_id", doc.Id)
+	// 先保存文件记录,片段保存失败时删除文件记录,避免留下找不到所属文件的片段
+	if _, err := persist.DB.InsertOne(persist.KnowledgeDocument, d); err != nil {
+		return nil, err
+	}
+	if err := persist.DB.Insert(persist.KnowledgeChunk, docs...); err != nil {
+		if derr := persist.DB.Query(persist.KnowledgeDocument).DeleteById(doc.Id); derr != nil {
+			log.Error("failed to remove document after chunks were not saved", "document", doc.Id, "error", derr)
+		}
+		return nil, err
+	}
+
+	b.invalidate()
+	return doc, nil
+}
+
+// List 返回所有文件,最近加入的在前
+func (b *Base) List() ([]*Document, error) {
+	docs, err := persist.DB.Query(persist.KnowledgeDocument).FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	output := make([]*Document, 0, len(docs))
+	for _, d := range docs {
+		var doc Document
+		if err := persist.Unmarshal(d, &doc); err != nil {
+			return nil, err
+		}
+		output = append(output, &doc)
+	}
+	sort.SliceStable(output, func(i, j int) bool {
+		return output[i].CreatedTime > output[j].CreatedTime
+	})
+	return output, nil
+}
+
+// Delete 删除文件及其片段
+func (b *Base) Delete(id string) error {
+	d, err := persist.DB.Query(persist.KnowledgeDocument).FindById(id)
+	if err != nil {
+		return err
+	}
+	if d == nil {
+		return fmt.Errorf("document not found: %s", id)
+	}
+
+	if err := persist.DB.Query(persist.KnowledgeChunk).Where(clover.Field("document").Eq(id)).Delete(); err != nil {
+		return err
+	}
+	if err := persist.DB.Query(persist.KnowledgeDocument).DeleteById(id); err != nil {
+		return err
+	}
+	b.invalidate()
+	return nil
+}
+
+func (b *Base) checkSize(name string, size int64) error {
+	limit := b.cfg.MaxFileSize
+	if limit <= 0 {
+		limit = defaultMaxFileSize
+	}
+	if size > int64(limit)<<20 {
+		return fmt.Errorf("%s is larger than %d MB", name, limit)
+	}
+	return nil
+}
+
+// embed 分批生成向量
+func (b *Base) embed(ctx context.Context, texts []string) ([][]float64, string, error) {
+	if b.embedder == nil {
+		return nil, "", fmt.Errorf("no embedding model available")
+	}
+	e, name, err := b.embedder(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	output := make([][]float64, 0, len(texts))
+	for start := 0; start < len(texts); start += embedBatch {
+		end := min(start+embedBatch, len(texts))
+		vectors, err := e.EmbedStrings(ctx, texts[start:end])
+		if err != nil {
+			return nil, "", err
+		}
+		if len(vectors) != end-start {
+			return nil, "", fmt.Errorf("expected %d embeddings, got %d", end-start, len(vectors))
+		}
+		output = append(output, vectors...)
+	}
+	return output, name, nil
+}
+
+func (b *Base) invalidate() {
+	b.mu.Lock()
+	b.index = nil
+	b.mu.Unlock()
+}
@@ -0,0 +1,204 @@
+package knowledge
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/AntNoHuabei/Remo/internal/log"
+	"github.com/AntNoHuabei/Remo/pkg/persist"
+	"github.com/cloudwego/eino/components/embedding"
+)
+
+func TestMain(m *testing.M) {
+	// 只输出到控制台,避免在包目录下生成日志文件
+	if err := log.Init(&log.Config{Level: "error"}); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+var vocabulary = []string{"pasta", "noodle", "invoice", "payment", "rocket"}
+
+// wordEmbedder 按词表中的词是否出现生成向量,noodle 与 pasta 视为同义
+type wordEmbedder struct{}
+
+func (wordEmbedder) EmbedStrings(ctx context.Context, texts []string, opts ...embedding.Option) ([][]float64, error) {
+	output := make([][]float64, 0, len(texts))
+	for _, text := range texts {
+		text = strings.ReplaceAll(strings.ToLower(text), "noodle", "pasta")
+		vector := make([]float64, len(vocabulary))
+		for i, word := range vocabulary {
+			if strings.Contains(text, word) {
+				vector[i] = 1
+			}
+		}
+		output = append(output, vector)
+	}
+	return output, nil
+}
+
+func newTestBase(t *testing.T, e embedding.Embedder) *Base {
+	if err := persist.Open(t.TempDir()); err != nil {
+		t.Fatalf("Failed to open db: %v", err)
+	}
+	t.Cleanup(func() {
+		persist.DB.Close()
+	})
+
+	b := NewBase(config.KnowledgeConfig{Enabled: true, TopK: 2, MinScore: 0.3, ChunkSize: 80, ChunkOverlap: 10})
+	b.embedder = func(ctx context.Context) (embedding.Embedder, string, error) {
+		if e == nil {
+			return nil, "", errors.New("no embedding model available")
+		}
+		return e, "test/words", nil
+	}
+	return b
+}
+
+func TestSplitChunks(t *testing.T) {
+	text := strings.Repeat("The quick brown fox jumps over the lazy dog. ", 10)
+	chunks := splitChunks(text, 100, 20)
+	if len(chunks) < 5 {
+		t.Fatalf("Expected at least 5 chunks, got %d", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if len([]rune(chunk)) > 100 {
+			t.Errorf("Chunk %d is longer than 100 characters", i)
+		}
+		if !strings.HasSuffix(chunk, ".") && i < len(chunks)-1 {
+			t.Errorf("Expected chunk %d to end at a sentence, got %q", i, chunk)
+		}
+	}
+
+	if chunks := splitChunks("第一段内容。\n\n第二段很长很长很长。", 14, 2); len(chunks) != 2 || chunks[0] != "第一段内容。" {
+		t.Errorf("Expected a split at the paragraph, got %q", chunks)
+	}
+	if chunks := splitChunks("  ", 100, 10); len(chunks) != 0 {
+		t.Errorf("Expected no chunks for blank text, got %q", chunks)
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	got := strings.Join(tokenize("Hello, World_1! 知识库"), "|")
+	if got != "hello|world_1|知识|识库" {
+		t.Errorf("Unexpected tokens %q", got)
+	}
+}
+
+func TestExtractText(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("word/document.xml")
+	w.Write([]byte(`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+		`<w:p><w:r><w:t>Hello</w:t></w:r><w:r><w:tab/><w:t>docx</w:t></w:r></w:p><w:p><w:r><w:t>Second</w:t></w:r></w:p>` +
+		`</w:body></w:document>`))
+	zw.Close()
+
+	text, err := extractText("a.DOCX", buf.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to read docx: %v", err)
+	}
+	if text != "Hello\tdocx\nSecond\n" {
+		t.Errorf("Unexpected docx text %q", text)
+	}
+
+	if text, err := extractText("main.go", []byte("\xef\xbb\xbfpackage main\r\n")); err != nil || text != "package main\n" {
+		t.Errorf("Unexpected source text %q %v", text, err)
+	}
+	if _, err := extractText("image.png", []byte{0x89, 'P', 'N', 'G', 0, 0}); err == nil {
+		t.Error("Expected binary files to be rejected")
+	}
+}
+
+func TestSearchHybrid(t *testing.T) {
+	ctx := context.Background()
+	b := newTestBase(t, wordEmbedder{})
+
+	recipes, err := b.Add(ctx, "recipes.md", []byte("# Recipes\n\nBoil the pasta for ten minutes and add salt."))
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if recipes.EmbeddingModel != "test/words" || recipes.Chunks != 1 {
+		t.Errorf("Unexpected document %+v", recipes)
+	}
+	b.Add(ctx, "billing.txt", []byte("Every invoice must be paid within 30 days. Late payment adds a fee."))
+	b.Add(ctx, "space.txt", []byte("The rocket launches at dawn."))
+
+	// 只能通过向量找到同义词
+	results, err := b.Search(ctx, "how long to cook noodle", 0)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Document.Name != "recipes.md" {
+		t.Fatalf("Expected the recipe, got %+v", results)
+	}
+
+	// 只能通过关键词找到
+	results, _ = b.Search(ctx, "late fee", 0)
+	if len(results) != 1 || results[0].Document.Name != "billing.txt" {
+		t.Fatalf("Expected the billing document, got %+v", results)
+	}
+
+	// 关键词和向量都命中的排在前面
+	results, _ = b.Search(ctx, "rocket pasta fee", 0)
+	if len(results) != 2 {
+		t.Fatalf("Expected results capped at top_k, got %d", len(results))
+	}
+	if results[0].Document.Name == "billing.txt" {
+		t.Errorf("Expected documents matched by both keyword and vector first, got %q", results[0].Document.Name)
+	}
+
+	if err := b.Delete(recipes.Id); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	results, _ = b.Search(ctx, "pasta", 0)
+	if len(results) != 0 {
+		t.Errorf("Expected deleted document to be gone, got %+v", results)
+	}
+	docs, _ := b.List()
+	if len(docs) != 2 {
+		t.Errorf("Expected 2 documents, got %d", len(docs))
+	}
+	if err := b.Delete(recipes.Id); err == nil {
+		t.Error("Expected deleting a missing document to fail")
+	}
+}
+
+func TestSearchKeywordOnly(t *testing.T) {
+	ctx := context.Background()
+	b := newTestBase(t, nil)
+
+	doc, err := b.Add(ctx, "notes.txt", []byte("知识库支持离线检索。"))
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if doc.EmbeddingModel != "" {
+		t.Errorf("Expected no embedding model, got %q", doc.EmbeddingModel)
+	}
+
+	results, err := b.Search(ctx, "离线", 0)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("Expected a keyword match, got %v %+v", err, results)
+	}
+	if results, _ := b.Search(ctx, "在线", 0); len(results) != 0 {
+		t.Errorf("Expected no match, got %+v", results)
+	}
+}
+
+func TestAddLimits(t *testing.T) {
+	b := newTestBase(t, nil)
+	b.cfg.MaxFileSize = 1
+
+	if _, err := b.Add(context.Background(), "big.txt", bytes.Repeat([]byte("a"), 1<<20+1)); err == nil {
+		t.Error("Expected files over the size limit to be rejected")
+	}
+	if _, err := b.Add(context.Background(), "empty.md", []byte("\n\n")); err == nil {
+		t.Error("Expected files without text to be rejected")
+	}
+}
@@ -0,0 +1,125 @@
+package knowledge
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// extractText 按文件类型提取文本,pdf 和 docx 只提取文字,其它文件需要是 UTF-8 文本
+func extractText(name string, data []byte) (string, error) {
+	var text string
+	var err error
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".pdf":
+		text, err = pdfText(data)
+	case ".docx":
+		text, err = docxText(data)
+	default:
+		text, err = plainText(data)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if strings.TrimSpace(text) == "" {
+		return "", fmt.Errorf("no text found in %s", name)
+	}
+	return text, nil
+}
+
+func plainText(data []byte) (string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+		return "", fmt.Errorf("unsupported file type, only text, markdown, source code, pdf and docx are supported")
+	}
+	return strings.ReplaceAll(string(data), "\r\n", "\n"), nil
+}
+
+func pdfText(data []byte) (string, error) {
+	r, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for i := 1; i <= r.NumPage(); i++ {
+		page := r.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		text, err := page.GetPlainText(nil)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(text)
+		sb.WriteString("\n\n")
+	}
+	return sb.String(), nil
+}
+
+// docxText 读取 word/document.xml 中的文字,段落之间换行
+func docxText(data []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+
+	var document *zip.File
+	for _, f := range zr.File {
+		if f.Name == "word/document.xml" {
+			document = f
+			break
+		}
+	}
+	if document == nil {
+		return "", fmt.Errorf("word/document.xml not found")
+	}
+	rc, err := document.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	var sb strings.Builder
+	decoder := xml.NewDecoder(rc)
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				sb.WriteString("\t")
+			case "br":
+				sb.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				sb.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				sb.Write(t)
+			}
+		}
+	}
+	return sb.String(), nil
+}
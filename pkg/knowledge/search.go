@@ -0,0 +1,163 @@
+package knowledge
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/AntNoHuabei/Remo/internal/log"
+	"github.com/AntNoHuabei/Remo/pkg/persist"
+	"github.com/AntNoHuabei/Remo/pkg/provider"
+)
+
+// rrfK 倒数排名融合的平滑参数
+const rrfK = 60
+
+// Result 检索结果
+type Result struct {
+	Document *Document
+	Chunk    *Chunk
+	Score    float64 // 关键词和向量排名融合后的分数,只用于排序
+}
+
+// index 所有片段的内存索引
+type index struct {
+	chunks []*Chunk
+	docs   map[string]*Document
+	bm25   *bm25
+}
+
+// Search 混合检索与 query 最相关的 k 个片段,k 小于等于 0 时使用配置的数量
+// 关键词(BM25)和向量相似度分别排名后按倒数排名融合,向量模型不可用时只使用关键词
+func (b *Base) Search(ctx context.Context, query string, k int) ([]Result, error) {
+	if !b.cfg.Enabled || strings.TrimSpace(query) == "" {
+		return nil, nil
+	}
+	if k <= 0 {
+		k = b.cfg.TopK
+	}
+	if k <= 0 {
+		k = defaultTopK
+	}
+
+	idx, err := b.load()
+	if err != nil || len(idx.chunks) == 0 {
+		return nil, err
+	}
+
+	keyword := idx.bm25.scores(query)
+	vector := b.similarities(ctx, idx, query)
+
+	fused := make([]float64, len(idx.chunks))
+	rank(keyword, 0, func(i, r int) { fused[i] += 1 / float64(rrfK+r) })
+	if vector != nil {
+		rank(vector, b.cfg.MinScore, func(i, r int) { fused[i] += 1 / float64(rrfK+r) })
+	}
+
+	output := make([]Result, 0)
+	for i, score := range fused {
+		if score == 0 {
+			continue
+		}
+		chunk := idx.chunks[i]
+		output = append(output, Result{Document: idx.docs[chunk.Document], Chunk: chunk, Score: score})
+	}
+	sort.SliceStable(output, func(i, j int) bool {
+		return output[i].Score > output[j].Score
+	})
+	if len(output) > k {
+		output = output[:k]
+	}
+	return output, nil
+}
+
+// similarities 返回查询与每个片段的余弦相似度,没有可用的向量时返回 nil
+func (b *Base) similarities(ctx context.Context, idx *index, query string) []float64 {
+	if b.embedder == nil {
+		return nil
+	}
+	e, name, err := b.embedder(ctx)
+	if err != nil {
+		return nil
+	}
+	found := false
+	for _, chunk := range idx.chunks {
+		if chunk.EmbeddingModel == name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	vectors, err := e.EmbedStrings(ctx, []string{query})
+	if err != nil || len(vectors) != 1 {
+		log.Debug("failed to embed query, only keyword search is used", "error", err)
+		return nil
+	}
+	output := make([]float64, len(idx.chunks))
+	for i, chunk := range idx.chunks {
+		// 不同向量模型生成的向量无法比较
+		if chunk.EmbeddingModel == name {
+			output[i] = provider.Cosine(vectors[0], chunk.Embedding)
+		}
+	}
+	return output
+}
+
+// rank 将分数大于 threshold 的项按分数从高到低排名,排名从 1 开始
+func rank(scores []float64, threshold float64, fn func(i int, rank int)) {
+	order := make([]int, 0, len(scores))
+	for i, score := range scores {
+		if score > threshold {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return scores[order[a]] > scores[order[b]]
+	})
+	for r, i := range order {
+		fn(i, r+1)
+	}
+}
+
+// load 返回内存索引,索引失效时从数据库重建
+func (b *Base) load() (*index, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.index != nil {
+		return b.index, nil
+	}
+
+	docs, err := b.List()
+	if err != nil {
+		return nil, err
+	}
+	idx := &index{docs: make(map[string]*Document, len(docs))}
+	for _, doc := range docs {
+		idx.docs[doc.Id] = doc
+	}
+
+	records, err := persist.DB.Query(persist.KnowledgeChunk).FindAll()
+	if err != nil {
+		return nil, err
+	}
+	texts := make([]string, 0, len(records))
+	for _, record := range records {
+		var chunk Chunk
+		if err := persist.Unmarshal(record, &chunk); err != nil {
+			return nil, err
+		}
+		// 忽略文件已删除的片段
+		if idx.docs[chunk.Document] == nil {
+			continue
+		}
+		idx.chunks = append(idx.chunks, &chunk)
+		texts = append(texts, chunk.Content)
+	}
+	idx.bm25 = newBM25(texts)
+
+	b.index = idx
+	return idx, nil
+}
@@ -0,0 +1,127 @@
+package mcp
+
+import (
+	"context"
+	"sync"
+
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/cloudwego/eino/components/tool"
+)
+
+var (
+	globalManager *Manager
+	managerMu     sync.RWMutex
+)
+
+// Manager 管理所有 MCP 服务器的连接
+type Manager struct {
+	servers []*server
+
+	mu       sync.Mutex
+	cancel   context.CancelFunc // 停止健康检查
+	closed   bool
+	watchers sync.WaitGroup
+}
+
+// NewManager 根据配置创建管理器,此时不会连接服务器
+func NewManager(cfg config.MCPConfig) *Manager {
+	m := &Manager{}
+	for _, sc := range cfg.Servers {
+		m.servers = append(m.servers, newServer(sc))
+	}
+	return m
+}
+
+// Init 创建全局管理器并关闭之前的管理器,需要调用 Start 连接服务器
+func Init(cfg config.MCPConfig) *Manager {
+	m := NewManager(cfg)
+
+	managerMu.Lock()
+	old := globalManager
+	globalManager = m
+	managerMu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	return m
+}
+
+// Get 获取全局管理器,未初始化时返回空的管理器
+func Get() *Manager {
+	managerMu.RLock()
+	defer managerMu.RUnlock()
+	if globalManager == nil {
+		return &Manager{}
+	}
+	return globalManager
+}
+
+// Start 并发连接所有启用的服务器,连接失败的服务器会记录在状态中,
+// 之后在后台定期检查,断开的服务器按退避间隔重新连接,直到 Close
+func (m *Manager) Start(ctx context.Context) {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return
+	}
+	ctx, m.cancel = context.WithCancel(ctx)
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, s := range m.servers {
+		if !s.cfg.Enabled {
+			continue
+		}
+		wg.Add(1)
+		go func(s *server) {
+			defer wg.Done()
+			s.connect(ctx)
+		}(s)
+	}
+	wg.Wait()
+
+	for _, s := range m.servers {
+		if s.cfg.Enabled {
+			m.watchers.Add(1)
+			go func(s *server) {
+				defer m.watchers.Done()
+				s.watch(ctx)
+			}(s)
+		}
+	}
+}
+
+// Close 停止健康检查并断开所有服务器
+func (m *Manager) Close() {
+	m.mu.Lock()
+	m.closed = true
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.mu.Unlock()
+	m.watchers.Wait()
+
+	for _, s := range m.servers {
+		s.close()
+	}
+}
+
+// Servers 检查已连接服务器的健康状况并返回所有服务器的状态
+func (m *Manager) Servers(ctx context.Context) []ServerStatus {
+	output := make([]ServerStatus, 0, len(m.servers))
+	for _, s := range m.servers {
+		s.ping(ctx)
+		output = append(output, s.status())
+	}
+	return output
+}
+
+// Tools 返回所有已连接服务器提供的工具
+func (m *Manager) Tools(ctx context.Context) []tool.InvokableTool {
+	output := make([]tool.InvokableTool, 0)
+	for _, s := range m.servers {
+		output = append(output, s.agentTools()...)
+	}
+	return output
+}
@@ -0,0 +1,211 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/AntNoHuabei/Remo/internal/log"
+	mcpgo "github.com/mark3labs/mcp-go/mcp"
+	mcpserver "github.com/mark3labs/mcp-go/server"
+)
+
+// stubEnv 设置后测试程序作为 stdio MCP 服务器运行
+const stubEnv = "REMO_MCP_STUB_SERVER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(stubEnv) == "1" {
+		runStubServer()
+		return
+	}
+	// 只输出到控制台,避免在包目录下生成日志文件
+	if err := log.Init(&log.Config{Level: "error"}); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func runStubServer() {
+	s := mcpserver.NewMCPServer("stub", "0.1.0",
+		mcpserver.WithToolCapabilities(false),
+		mcpserver.WithPromptCapabilities(false),
+	)
+	s.AddTool(mcpgo.NewTool("echo",
+		mcpgo.WithDescription("Echo the text back"),
+		mcpgo.WithString("text", mcpgo.Required(), mcpgo.Description("text to echo")),
+	), func(ctx context.Context, req mcpgo.CallToolRequest) (*mcpgo.CallToolResult, error) {
+		text, err := req.RequireString("text")
+		if err != nil {
+			return mcpgo.NewToolResultError(err.Error()), nil
+		}
+		return mcpgo.NewToolResultText("echo: " + text), nil
+	})
+	s.AddPrompt(mcpgo.NewPrompt("greet",
+		mcpgo.WithPromptDescription("Greet someone"),
+		mcpgo.WithArgument("name", mcpgo.RequiredArgument()),
+	), func(ctx context.Context, req mcpgo.GetPromptRequest) (*mcpgo.GetPromptResult, error) {
+		return mcpgo.NewGetPromptResult("greet", []mcpgo.PromptMessage{
+			mcpgo.NewPromptMessage(mcpgo.RoleUser, mcpgo.NewTextContent("hello "+req.Params.Arguments["name"])),
+		}), nil
+	})
+	if err := mcpserver.ServeStdio(s); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func stubServerConfig(t *testing.T, name string) config.MCPServerConfig {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("Failed to get test executable: %v", err)
+	}
+	return config.MCPServerConfig{
+		Name:      name,
+		Transport: config.MCPTransportStdio,
+		Command:   exe,
+		Env:       map[string]string{stubEnv: "1"},
+		Timeout:   10,
+		Enabled:   true,
+	}
+}
+
+func TestStdioServer(t *testing.T) {
+	m := NewManager(config.MCPConfig{Servers: []config.MCPServerConfig{stubServerConfig(t, "stub")}})
+	defer m.Close()
+	m.Start(context.Background())
+
+	servers := m.Servers(context.Background())
+	if len(servers) != 1 {
+		t.Fatalf("Expected 1 server, got %d", len(servers))
+	}
+	status := servers[0]
+	if status.Status != StatusConnected {
+		t.Fatalf("Expected status connected, got %s (%s)", status.Status, status.Error)
+	}
+	if status.ServerName != "stub" || status.CheckedAt == nil {
+		t.Errorf("Unexpected server status: %+v", status)
+	}
+	if len(status.Tools) != 1 || status.Tools[0].Name != "echo" || status.Tools[0].AgentName != "stub__echo" {
+		t.Errorf("Unexpected tools: %+v", status.Tools)
+	}
+	if len(status.Prompts) != 1 || status.Prompts[0].Name != "greet" || !status.Prompts[0].Arguments[0].Required {
+		t.Errorf("Unexpected prompts: %+v", status.Prompts)
+	}
+
+	tools := m.Tools(context.Background())
+	if len(tools) != 1 {
+		t.Fatalf("Expected 1 tool, got %d", len(tools))
+	}
+	info, err := tools[0].Info(context.Background())
+	if err != nil {
+		t.Fatalf("Info failed: %v", err)
+	}
+	params, err := info.ParamsOneOf.ToJSONSchema()
+	if err != nil {
+		t.Fatalf("ToJSONSchema failed: %v", err)
+	}
+	if _, ok := params.Properties.Get("text"); !ok || len(params.Required) != 1 {
+		t.Errorf("Unexpected params schema: %+v", params)
+	}
+
+	output, err := tools[0].InvokableRun(context.Background(), `{"text":"hi"}`)
+	if err != nil {
+		t.Fatalf("InvokableRun failed: %v", err)
+	}
+	if output != "echo: hi" {
+		t.Errorf("Expected 'echo: hi', got %q", output)
+	}
+
+	output, err = tools[0].InvokableRun(context.Background(), `{}`)
+	if err != nil {
+		t.Fatalf("InvokableRun failed: %v", err)
+	}
+	if !strings.Contains(output, "tool returned an error") {
+		t.Errorf("Expected tool error in output, got %q", output)
+	}
+}
+
+func TestServerStatus(t *testing.T) {
+	disabled := stubServerConfig(t, "disabled")
+	disabled.Enabled = false
+	broken := config.MCPServerConfig{Name: "broken", Command: "/nonexistent/mcp-server", Enabled: true}
+	unsupported := config.MCPServerConfig{Name: "ws", Transport: "ws", Enabled: true}
+
+	m := NewManager(config.MCPConfig{Servers: []config.MCPServerConfig{disabled, broken, unsupported}})
+	defer m.Close()
+	m.Start(context.Background())
+
+	servers := m.Servers(context.Background())
+	if servers[0].Status != StatusDisabled {
+		t.Errorf("Expected disabled, got %s", servers[0].Status)
+	}
+	for _, s := range servers[1:] {
+		if s.Status != StatusError || s.Error == "" {
+			t.Errorf("Expected error status for %s, got %+v", s.Name, s)
+		}
+	}
+	if tools := m.Tools(context.Background()); len(tools) != 0 {
+		t.Errorf("Expected no tools, got %d", len(tools))
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	healthInterval, minRetryDelay = 20*time.Millisecond, 10*time.Millisecond
+	t.Cleanup(func() {
+		healthInterval, minRetryDelay = 30*time.Second, 5*time.Second
+	})
+
+	m := NewManager(config.MCPConfig{Servers: []config.MCPServerConfig{stubServerConfig(t, "stub")}})
+	defer m.Close()
+	m.Start(context.Background())
+
+	s := m.servers[0]
+	s.mu.RLock()
+	old := s.client
+	s.mu.RUnlock()
+	s.fail(errors.New("connection lost"))
+
+	deadline := time.Now().Add(10 * time.Second)
+	for s.status().Status != StatusConnected {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the server to reconnect, got %+v", s.status())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.mu.RLock()
+	reconnected := s.client
+	s.mu.RUnlock()
+	if reconnected == old || old.Ping(context.Background()) == nil {
+		t.Error("Expected the failed client to be closed and replaced")
+	}
+	if tools := m.Tools(context.Background()); len(tools) != 1 {
+		t.Errorf("Expected the tools after reconnecting, got %d", len(tools))
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	s := newServer(config.MCPServerConfig{Name: "broken", Enabled: true})
+	for i, expected := range []time.Duration{minRetryDelay, 2 * minRetryDelay, 4 * minRetryDelay} {
+		s.fail(fmt.Errorf("failure %d", i))
+		if delay := s.retryDelay(); delay != expected {
+			t.Errorf("Expected %s after %d failures, got %s", expected, i+1, delay)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		s.fail(errors.New("still broken"))
+	}
+	if delay := s.retryDelay(); delay != maxRetryDelay {
+		t.Errorf("Expected the delay to be capped at %s, got %s", maxRetryDelay, delay)
+	}
+}
+
+func TestAgentToolName(t *testing.T) {
+	if name := agentToolName("my server", "read.file"); name != "my_server__read_file" {
+		t.Errorf("Unexpected tool name: %s", name)
+	}
+}
@@ -0,0 +1,332 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/AntNoHuabei/Remo/internal/log"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	mcpgo "github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	defaultTimeout = 30 * time.Second
+	pingTimeout    = 5 * time.Second
+	clientName     = "Remo"
+	clientVersion  = "1.0.0"
+)
+
+// 健康检查和重新连接的间隔,测试中会缩短
+var (
+	healthInterval = 30 * time.Second
+	minRetryDelay  = 5 * time.Second
+	maxRetryDelay  = 5 * time.Minute
+)
+
+// server 单个 MCP 服务器的连接
+type server struct {
+	cfg config.MCPServerConfig
+
+	mu          sync.RWMutex
+	client      *client.Client
+	state       string
+	err         string
+	serverName  string
+	version     string
+	connectedAt *time.Time
+	checkedAt   *time.Time
+	tools       []*mcpTool
+	prompts     []Prompt
+	failures    int // 连续失败的次数,决定下次重新连接前的等待时间
+}
+
+func newServer(cfg config.MCPServerConfig) *server {
+	s := &server{cfg: cfg, state: StatusDisabled}
+	if cfg.Enabled {
+		s.state = StatusConnecting
+	}
+	return s
+}
+
+func (s *server) timeout() time.Duration {
+	if s.cfg.Timeout > 0 {
+		return time.Duration(s.cfg.Timeout) * time.Second
+	}
+	return defaultTimeout
+}
+
+// connect 连接服务器并读取工具和提示词列表,ctx 结束后不再保存连接
+func (s *server) connect(ctx context.Context) {
+	s.mu.Lock()
+	s.state = StatusConnecting
+	s.err = ""
+	s.mu.Unlock()
+
+	cli, err := s.newClient()
+	if err != nil {
+		s.fail(err)
+		return
+	}
+
+	initCtx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+
+	tools, prompts, info, err := s.initialize(initCtx, cli)
+	if err != nil {
+		cli.Close()
+		s.fail(err)
+		return
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	// 连接期间管理器已关闭
+	if ctx.Err() != nil {
+		s.mu.Unlock()
+		cli.Close()
+		return
+	}
+	s.client = cli
+	s.failures = 0
+	s.state = StatusConnected
+	s.serverName = info.Name
+	s.version = info.Version
+	s.connectedAt = &now
+	s.checkedAt = &now
+	s.tools = tools
+	s.prompts = prompts
+	s.mu.Unlock()
+}
+
+// newClient 按传输方式创建并启动客户端
+func (s *server) newClient() (*client.Client, error) {
+	switch s.cfg.Transport {
+	case config.MCPTransportStdio, "":
+		if s.cfg.Command == "" {
+			return nil, fmt.Errorf("command is required for stdio transport")
+		}
+		env := make([]string, 0, len(s.cfg.Env))
+		for k, v := range s.cfg.Env {
+			env = append(env, k+"="+v)
+		}
+		// 进程的生命周期与连接一致,不能使用连接超时的 ctx
+		cli := client.NewClient(transport.NewStdioWithOptions(s.cfg.Command, env, s.cfg.Args))
+		if err := cli.Start(context.Background()); err != nil {
+			return nil, err
+		}
+		if stderr, ok := client.GetStderr(cli); ok {
+			go func() {
+				scanner := bufio.NewScanner(stderr)
+				for scanner.Scan() {
+					log.Debug("mcp server stderr", "server", s.cfg.Name, "line", scanner.Text())
+				}
+			}()
+		}
+		return cli, nil
+	case config.MCPTransportHTTP:
+		if s.cfg.URL == "" {
+			return nil, fmt.Errorf("url is required for http transport")
+		}
+		cli, err := client.NewStreamableHttpClient(s.cfg.URL,
+			transport.WithHTTPHeaders(s.cfg.Headers),
+			transport.WithHTTPTimeout(s.timeout()),
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := cli.Start(context.Background()); err != nil {
+			return nil, err
+		}
+		return cli, nil
+	default:
+		return nil, fmt.Errorf("unsupported transport: %s", s.cfg.Transport)
+	}
+}
+
+// initialize 完成握手并读取工具和提示词列表
+func (s *server) initialize(ctx context.Context, cli *client.Client) ([]*mcpTool, []Prompt, mcpgo.Implementation, error) {
+	req := mcpgo.InitializeRequest{}
+	req.Params.ProtocolVersion = mcpgo.LATEST_PROTOCOL_VERSION
+	req.Params.ClientInfo = mcpgo.Implementation{Name: clientName, Version: clientVersion}
+	result, err := cli.Initialize(ctx, req)
+	if err != nil {
+		return nil, nil, mcpgo.Implementation{}, fmt.Errorf("initialize: %w", err)
+	}
+
+	tools := make([]*mcpTool, 0)
+	if result.Capabilities.Tools != nil {
+		list, err := cli.ListTools(ctx, mcpgo.ListToolsRequest{})
+		if err != nil {
+			return nil, nil, result.ServerInfo, fmt.Errorf("list tools: %w", err)
+		}
+		for _, t := range list.Tools {
+			mt, err := newMCPTool(cli, s.cfg.Name, t, s.timeout())
+			if err != nil {
+				return nil, nil, result.ServerInfo, err
+			}
+			tools = append(tools, mt)
+		}
+	}
+
+	prompts := make([]Prompt, 0)
+	if result.Capabilities.Prompts != nil {
+		list, err := cli.ListPrompts(ctx, mcpgo.ListPromptsRequest{})
+		if err != nil {
+			return nil, nil, result.ServerInfo, fmt.Errorf("list prompts: %w", err)
+		}
+		for _, p := range list.Prompts {
+			prompt := Prompt{Name: p.Name, Description: p.Description, Arguments: make([]PromptArgument, 0, len(p.Arguments))}
+			for _, a := range p.Arguments {
+				prompt.Arguments = append(prompt.Arguments, PromptArgument{Name: a.Name, Description: a.Description, Required: a.Required})
+			}
+			prompts = append(prompts, prompt)
+		}
+	}
+	return tools, prompts, result.ServerInfo, nil
+}
+
+// fail 记录错误并断开连接,之后由 watch 重新连接
+func (s *server) fail(err error) {
+	log.Warn("mcp server unavailable", "server", s.cfg.Name, "error", err)
+	now := time.Now()
+	s.mu.Lock()
+	cli := s.client
+	s.client = nil
+	s.state = StatusError
+	s.err = err.Error()
+	s.checkedAt = &now
+	s.tools = nil
+	s.failures++
+	s.mu.Unlock()
+	if cli != nil {
+		cli.Close()
+	}
+}
+
+// retryDelay 下一次检查前的等待时间,失败后从 minRetryDelay 开始倍增,最多 maxRetryDelay
+func (s *server) retryDelay() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.state != StatusError {
+		return healthInterval
+	}
+	delay := minRetryDelay
+	for i := 1; i < s.failures && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
+
+// watch 定期检查服务器,不可用时按退避间隔重新连接,直到 ctx 结束
+func (s *server) watch(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.retryDelay()):
+		}
+
+		s.mu.RLock()
+		failed := s.state == StatusError
+		s.mu.RUnlock()
+		if failed {
+			s.connect(ctx)
+		} else {
+			s.ping(ctx)
+		}
+	}
+}
+
+// ping 检查已连接的服务器是否可用
+func (s *server) ping(ctx context.Context) {
+	s.mu.RLock()
+	cli := s.client
+	connected := s.state == StatusConnected
+	s.mu.RUnlock()
+	if !connected || cli == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	if err := cli.Ping(ctx); err != nil {
+		s.fail(fmt.Errorf("ping: %w", err))
+		return
+	}
+	now := time.Now()
+	s.mu.Lock()
+	s.checkedAt = &now
+	s.mu.Unlock()
+}
+
+func (s *server) close() {
+	s.mu.Lock()
+	cli := s.client
+	s.client = nil
+	s.tools = nil
+	if s.state == StatusConnected {
+		s.state = StatusDisabled
+	}
+	s.mu.Unlock()
+	if cli != nil {
+		cli.Close()
+	}
+}
+
+func (s *server) status() ServerStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	transportName := string(s.cfg.Transport)
+	if transportName == "" {
+		transportName = string(config.MCPTransportStdio)
+	}
+	tools := make([]ToolInfo, 0, len(s.tools))
+	for _, t := range s.tools {
+		tools = append(tools, ToolInfo{Name: t.remoteName, AgentName: t.info.Name, Description: t.info.Desc})
+	}
+	prompts := s.prompts
+	if prompts == nil {
+		prompts = []Prompt{}
+	}
+	return ServerStatus{
+		Name:        s.cfg.Name,
+		Transport:   transportName,
+		Enabled:     s.cfg.Enabled,
+		Status:      s.state,
+		Error:       s.err,
+		ServerName:  s.serverName,
+		Version:     s.version,
+		ConnectedAt: s.connectedAt,
+		CheckedAt:   s.checkedAt,
+		Tools:       tools,
+		Prompts:     prompts,
+	}
+}
+
+func (s *server) agentTools() []tool.InvokableTool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.state != StatusConnected {
+		return nil
+	}
+	output := make([]tool.InvokableTool, 0, len(s.tools))
+	for _, t := range s.tools {
+		output = append(output, t)
+	}
+	return output
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// agentToolName 生成提供给模型的工具名,加上服务器名前缀避免与其它工具重名
+func agentToolName(serverName, toolName string) string {
+	return invalidNameChars.ReplaceAllString(serverName, "_") + "__" + invalidNameChars.ReplaceAllString(toolName, "_")
+}
@@ -0,0 +1,47 @@
+package mcp
+
+import "time"
+
+// 服务器连接状态
+const (
+	StatusDisabled   = "disabled"
+	StatusConnecting = "connecting"
+	StatusConnected  = "connected"
+	StatusError      = "error"
+)
+
+// ServerStatus MCP 服务器状态
+type ServerStatus struct {
+	Name        string     `json:"name"`
+	Transport   string     `json:"transport"`
+	Enabled     bool       `json:"enabled"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	ServerName  string     `json:"server_name,omitempty"` // 服务器自报的名称
+	Version     string     `json:"version,omitempty"`
+	ConnectedAt *time.Time `json:"connected_at,omitempty"`
+	CheckedAt   *time.Time `json:"checked_at,omitempty"` // 最近一次健康检查时间
+	Tools       []ToolInfo `json:"tools"`
+	Prompts     []Prompt   `json:"prompts"`
+}
+
+// ToolInfo 服务器提供的工具
+type ToolInfo struct {
+	Name        string `json:"name"`       // 服务器上的名称
+	AgentName   string `json:"agent_name"` // 提供给模型的名称,工具策略按此名称配置
+	Description string `json:"description"`
+}
+
+// Prompt 服务器提供的提示词模板
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Arguments   []PromptArgument `json:"arguments"`
+}
+
+// PromptArgument 提示词模板参数
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+}
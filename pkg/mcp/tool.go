@@ -0,0 +1,95 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/eino-contrib/jsonschema"
+	"github.com/mark3labs/mcp-go/client"
+	mcpgo "github.com/mark3labs/mcp-go/mcp"
+)
+
+// mcpTool 将 MCP 服务器上的工具适配为智能体工具
+type mcpTool struct {
+	client     *client.Client
+	remoteName string
+	info       *schema.ToolInfo
+	timeout    time.Duration
+}
+
+func newMCPTool(cli *client.Client, serverName string, t mcpgo.Tool, timeout time.Duration) (*mcpTool, error) {
+	raw := t.RawInputSchema
+	if len(raw) == 0 {
+		var err error
+		if raw, err = json.Marshal(t.InputSchema); err != nil {
+			return nil, fmt.Errorf("marshal input schema of %s: %w", t.Name, err)
+		}
+	}
+	params := &jsonschema.Schema{}
+	if err := json.Unmarshal(raw, params); err != nil {
+		return nil, fmt.Errorf("unmarshal input schema of %s: %w", t.Name, err)
+	}
+
+	return &mcpTool{
+		client:     cli,
+		remoteName: t.Name,
+		info: &schema.ToolInfo{
+			Name:        agentToolName(serverName, t.Name),
+			Desc:        t.Description,
+			ParamsOneOf: schema.NewParamsOneOfByJSONSchema(params),
+		},
+		timeout: timeout,
+	}, nil
+}
+
+func (t *mcpTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return t.info, nil
+}
+
+func (t *mcpTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	var args map[string]any
+	if argumentsInJSON != "" {
+		if err := json.Unmarshal([]byte(argumentsInJSON), &args); err != nil {
+			return "", fmt.Errorf("invalid arguments: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	req := mcpgo.CallToolRequest{}
+	req.Params.Name = t.remoteName
+	req.Params.Arguments = args
+	result, err := t.client.CallTool(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("call %s: %w", t.remoteName, err)
+	}
+
+	output := contentText(result.Content)
+	if result.IsError {
+		return output + "\n(tool returned an error)", nil
+	}
+	return output, nil
+}
+
+// contentText 将工具返回的内容转为文本,非文本内容使用 json 表示
+func contentText(contents []mcpgo.Content) string {
+	parts := make([]string, 0, len(contents))
+	for _, c := range contents {
+		if text, ok := mcpgo.AsTextContent(c); ok {
+			parts = append(parts, text.Text)
+			continue
+		}
+		data, err := json.Marshal(c)
+		if err != nil {
+			continue
+		}
+		parts = append(parts, string(data))
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// extractContext 提取时作为参考的已有记忆数
+const extractContext = 10
+
+const extractPrompt = `You maintain long-term memories about a user across conversations.
+Read the latest exchange and decide which durable facts are worth remembering: the user's name, background, preferences, goals, projects, relationships and explicit requests to remember something.
+Do not store small talk, one-off questions, facts about the world or anything only relevant to this exchange. Each memory is one short standalone sentence in the language of the conversation.
+Existing memories are listed with their ids. Update a memory instead of adding a duplicate, and delete memories the user contradicts or asks to forget.
+Reply with JSON only, in the form {"add": ["..."], "update": [{"id": "...", "content": "..."}], "delete": ["..."]}. Use empty lists when nothing changes.`
+
+// extraction 模型返回的记忆变更
+type extraction struct {
+	Add    []string `json:"add"`
+	Update []struct {
+		Id      string `json:"id"`
+		Content string `json:"content"`
+	} `json:"update"`
+	Delete []string `json:"delete"`
+}
+
+// Extract 从一轮对话中提取记忆并保存,返回新增或修改的记忆,向量模型不可用时不做任何事
+func (s *Store) Extract(ctx context.Context, cm model.BaseChatModel, session string, user string, assistant string) ([]*Memory, error) {
+	if !s.cfg.Enabled || strings.TrimSpace(user) == "" {
+		return nil, nil
+	}
+	// 向量模型不可用时无法检索,不再调用模型提取
+	if _, _, err := s.embedder(ctx); err != nil {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	related, err := s.Search(ctx, user+"\n"+assistant, extractContext)
+	if err != nil {
+		return nil, err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Existing memories:\n")
+	if len(related) == 0 {
+		sb.WriteString("(none)\n")
+	}
+	known := make(map[string]bool, len(related))
+	for _, r := range related {
+		known[r.Memory.Id] = true
+		fmt.Fprintf(&sb, "[%s] %s\n", r.Memory.Id, r.Memory.Content)
+	}
+	sb.WriteString("\nLatest exchange:\nUser: ")
+	sb.WriteString(user)
+	sb.WriteString("\nAssistant: ")
+	sb.WriteString(assistant)
+
+	msg, err := cm.Generate(ctx, []*schema.Message{
+		schema.SystemMessage(extractPrompt),
+		schema.UserMessage(sb.String()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	ext, err := parseExtraction(msg.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	// 只允许修改提供给模型的记忆
+	output := make([]*Memory, 0)
+	for _, u := range ext.Update {
+		if !known[u.Id] || strings.TrimSpace(u.Content) == "" {
+			continue
+		}
+		m, err := s.Edit(ctx, u.Id, u.Content)
+		if err != nil {
+			return output, err
+		}
+		output = append(output, m)
+	}
+	for _, id := range ext.Delete {
+		if !known[id] {
+			continue
+		}
+		if err := s.Delete(id); err != nil {
+			return output, err
+		}
+	}
+	for _, content := range ext.Add {
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+		m, err := s.Add(ctx, session, content)
+		if err != nil {
+			return output, err
+		}
+		output = append(output, m)
+	}
+	return output, nil
+}
+
+// parseExtraction 解析模型输出,允许 JSON 外有代码块标记或说明文字
+func parseExtraction(content string) (*extraction, error) {
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("invalid memory extraction: %s", content)
+	}
+	var ext extraction
+	if err := json.Unmarshal([]byte(content[start:end+1]), &ext); err != nil {
+		return nil, fmt.Errorf("invalid memory extraction: %w", err)
+	}
+	return &ext, nil
+}
@@ -0,0 +1,209 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/AntNoHuabei/Remo/pkg/persist"
+	"github.com/cloudwego/eino/components/embedding"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+var vocabulary = []string{"tea", "coffee", "go", "rust", "cat"}
+
+// wordEmbedder 按词表中的词是否出现生成向量
+type wordEmbedder struct{}
+
+func (e *wordEmbedder) EmbedStrings(ctx context.Context, texts []string, opts ...embedding.Option) ([][]float64, error) {
+	output := make([][]float64, 0, len(texts))
+	for _, text := range texts {
+		vector := make([]float64, len(vocabulary))
+		for i, word := range vocabulary {
+			if strings.Contains(strings.ToLower(text), word) {
+				vector[i] = 1
+			}
+		}
+		output = append(output, vector)
+	}
+	return output, nil
+}
+
+// replyModel 返回固定的回复,并记录收到的输入
+type replyModel struct {
+	reply string
+	input []*schema.Message
+}
+
+func (m *replyModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	m.input = input
+	return schema.AssistantMessage(m.reply, nil), nil
+}
+
+func (m *replyModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return nil, errors.New("not implemented")
+}
+
+func newTestStore(t *testing.T, e embedding.Embedder) *Store {
+	if err := persist.Open(t.TempDir()); err != nil {
+		t.Fatalf("Failed to open db: %v", err)
+	}
+	t.Cleanup(func() {
+		persist.DB.Close()
+	})
+
+	s := NewStore(config.MemoryConfig{Enabled: true, TopK: 2, MinScore: 0.3, AutoExtract: true})
+	s.embedder = func(ctx context.Context) (embedding.Embedder, string, error) {
+		if e == nil {
+			return nil, "", errors.New("no embedding model available")
+		}
+		return e, "test/words", nil
+	}
+	return s
+}
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	e := &wordEmbedder{}
+	s := newTestStore(t, e)
+
+	for _, content := range []string{"The user drinks tea", "The user writes Go and Rust", "The user has a cat"} {
+		if _, err := s.Add(ctx, "s1", content); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	results, err := s.Search(ctx, "which tea or coffee?", 0)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Memory.Content != "The user drinks tea" {
+		t.Fatalf("Expected only the tea memory, got %+v", results)
+	}
+
+	results, _ = s.Search(ctx, "tea, go and a cat", 0)
+	if len(results) != 2 {
+		t.Errorf("Expected results capped at top_k, got %d", len(results))
+	}
+}
+
+func TestSearchRefreshesEmbeddings(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t, nil)
+
+	// 向量模型不可用时只保存内容
+	m, err := s.Add(ctx, "s1", "The user likes coffee")
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if len(m.Embedding) != 0 {
+		t.Fatal("Expected no embedding without an embedding model")
+	}
+	if _, err := s.Search(ctx, "coffee", 0); err == nil {
+		t.Error("Expected search to fail without an embedding model")
+	}
+
+	e := &wordEmbedder{}
+	s.embedder = func(ctx context.Context) (embedding.Embedder, string, error) {
+		return e, "test/words", nil
+	}
+	results, err := s.Search(ctx, "coffee", 0)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("Expected the coffee memory, got %v %+v", err, results)
+	}
+	stored, _ := s.Find(m.Id)
+	if len(stored.Embedding) == 0 || stored.EmbeddingModel != "test/words" {
+		t.Errorf("Expected the embedding to be saved, got %+v", stored)
+	}
+}
+
+func TestEditAndDelete(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t, &wordEmbedder{})
+
+	m, _ := s.Add(ctx, "s1", "The user likes tea")
+	edited, err := s.Edit(ctx, m.Id, "The user likes coffee")
+	if err != nil {
+		t.Fatalf("Edit failed: %v", err)
+	}
+	if edited.Content != "The user likes coffee" || edited.Embedding[1] != 1 || edited.Embedding[0] != 0 {
+		t.Errorf("Expected content and embedding to change, got %+v", edited)
+	}
+	if _, err := s.Edit(ctx, m.Id, " "); err == nil {
+		t.Error("Expected empty content to be rejected")
+	}
+
+	if err := s.Delete(m.Id); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := s.Delete(m.Id); err == nil {
+		t.Error("Expected deleting a missing memory to fail")
+	}
+	memories, _ := s.List()
+	if len(memories) != 0 {
+		t.Errorf("Expected no memories, got %d", len(memories))
+	}
+}
+
+func TestExtract(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t, &wordEmbedder{})
+
+	tea, _ := s.Add(ctx, "s1", "The user drinks tea")
+	cat, _ := s.Add(ctx, "s1", "The user has a cat")
+
+	cm := &replyModel{reply: "```json\n{\"add\": [\"The user writes Go\"], \"update\": [{\"id\": \"" + tea.Id +
+		"\", \"content\": \"The user drinks green tea\"}, {\"id\": \"" + cat.Id + "\", \"content\": \"ignored\"}], \"delete\": [\"unknown\"]}\n```"}
+	changed, err := s.Extract(ctx, cm, "s2", "I drink green tea and write Go", "Nice!")
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if len(changed) != 2 {
+		t.Fatalf("Expected 2 changed memories, got %d", len(changed))
+	}
+	if !strings.Contains(cm.input[1].Content, tea.Id) || strings.Contains(cm.input[1].Content, cat.Id) {
+		t.Errorf("Expected only related memories in the prompt, got %q", cm.input[1].Content)
+	}
+
+	stored, _ := s.Find(tea.Id)
+	if stored.Content != "The user drinks green tea" {
+		t.Errorf("Expected tea memory to be updated, got %q", stored.Content)
+	}
+	// 未提供给模型的记忆不会被修改
+	stored, _ = s.Find(cat.Id)
+	if stored.Content != "The user has a cat" {
+		t.Errorf("Expected cat memory to be unchanged, got %q", stored.Content)
+	}
+	memories, _ := s.List()
+	if len(memories) != 3 {
+		t.Errorf("Expected 3 memories, got %d", len(memories))
+	}
+	for _, m := range memories {
+		if m.Content == "The user writes Go" && m.Session != "s2" {
+			t.Errorf("Expected the new memory to come from s2, got %q", m.Session)
+		}
+	}
+}
+
+func TestExtractWithoutEmbedding(t *testing.T) {
+	s := newTestStore(t, nil)
+	cm := &replyModel{reply: `{"add": ["x"]}`}
+
+	changed, err := s.Extract(context.Background(), cm, "s1", "hello", "hi")
+	if err != nil || len(changed) != 0 || cm.input != nil {
+		t.Errorf("Expected extraction to be skipped, got %v %v", changed, err)
+	}
+}
+
+func TestParseExtraction(t *testing.T) {
+	if _, err := parseExtraction("nothing to remember"); err == nil {
+		t.Error("Expected an error without JSON")
+	}
+	ext, err := parseExtraction(`Sure: {"add": [], "update": [], "delete": ["a"]}`)
+	if err != nil || len(ext.Delete) != 1 {
+		t.Errorf("Unexpected result %+v %v", ext, err)
+	}
+}
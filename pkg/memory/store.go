@@ -0,0 +1,284 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/AntNoHuabei/Remo/pkg/persist"
+	"github.com/AntNoHuabei/Remo/pkg/provider"
+	"github.com/cloudwego/eino/components/embedding"
+	"github.com/google/uuid"
+)
+
+const defaultTopK = 5
+
+var (
+	globalStore *Store
+	storeMu     sync.RWMutex
+)
+
+// Memory 一条长期记忆
+type Memory struct {
+	Id             string    `json:"id"`
+	Content        string    `json:"content"`
+	Session        string    `json:"session"` // 提取记忆的会话
+	Embedding      []float64 `json:"embedding"`
+	EmbeddingModel string    `json:"embedding_model"` // 生成 Embedding 的向量模型,与当前模型不一致时重新生成
+	CreatedTime    int64     `json:"created_time"`
+	UpdatedTime    int64     `json:"updated_time"`
+}
+
+// Result 检索结果
+type Result struct {
+	Memory *Memory
+	Score  float64 // 余弦相似度
+}
+
+// Store 长期记忆存储,记忆保存在 persist.Memory 集合中,跨会话共享
+type Store struct {
+	cfg      config.MemoryConfig
+	embedder provider.EmbedderFunc
+	mu       sync.Mutex // 串行化记忆提取,避免并发提取出重复的记忆
+}
+
+// NewStore 根据配置创建存储,向量模型从 provider 注册表获取
+func NewStore(cfg config.MemoryConfig) *Store {
+	return &Store{
+		cfg:      cfg,
+		embedder: provider.RegistryEmbedder(cfg.Embedding),
+	}
+}
+
+// Init 创建全局存储
+func Init(cfg config.MemoryConfig) *Store {
+	s := NewStore(cfg)
+
+	storeMu.Lock()
+	globalStore = s
+	storeMu.Unlock()
+	return s
+}
+
+// Get 获取全局存储,未初始化时返回禁用的存储
+func Get() *Store {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+	if globalStore == nil {
+		return &Store{}
+	}
+	return globalStore
+}
+
+// Enabled 是否启用长期记忆
+func (s *Store) Enabled() bool {
+	return s.cfg.Enabled
+}
+
+// AutoExtract 是否在对话结束后自动提取记忆
+func (s *Store) AutoExtract() bool {
+	return s.cfg.Enabled && s.cfg.AutoExtract
+}
+
+// List 返回所有记忆,最近更新的在前
+func (s *Store) List() ([]*Memory, error) {
+	docs, err := persist.DB.Query(persist.Memory).FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	output := make([]*Memory, 0, len(docs))
+	for _, doc := range docs {
+		var m Memory
+		if err := persist.Unmarshal(doc, &m); err != nil {
+			return nil, err
+		}
+		output = append(output, &m)
+	}
+	sort.SliceStable(output, func(i, j int) bool {
+		return output[i].UpdatedTime > output[j].UpdatedTime
+	})
+	return output, nil
+}
+
+// Find 获取记忆
+func (s *Store) Find(id string) (*Memory, error) {
+	doc, err := persist.DB.Query(persist.Memory).FindById(id)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, fmt.Errorf("memory not found: %s", id)
+	}
+	var m Memory
+	if err := persist.Unmarshal(doc, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Add 保存一条记忆,向量模型不可用时先保存内容,检索时再补充向量
+func (s *Store) Add(ctx context.Context, session string, content string) (*Memory, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, fmt.Errorf("memory content is empty")
+	}
+
+	now := time.Now().UnixMilli()
+	m := &Memory{
+		Id:          uuid.New().String(),
+		Content:     content,
+		Session:     session,
+		CreatedTime: now,
+		UpdatedTime: now,
+	}
+	s.embed(ctx, m)
+
+	doc, err := persist.NewSealedDocument(persist.Memory, m)
+	if err != nil {
+		return nil, err
+	}
+	doc.Set("_id", m.Id)
+	if _, err := persist.DB.InsertOne(persist.Memory, doc); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Edit 修改记忆内容并重新生成向量
+func (s *Store) Edit(ctx context.Context, id string, content string) (*Memory, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, fmt.Errorf("memory content is empty")
+	}
+
+	m, err := s.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	m.Content = content
+	m.UpdatedTime = time.Now().UnixMilli()
+	m.Embedding = nil
+	m.EmbeddingModel = ""
+	s.embed(ctx, m)
+
+	return m, save(m)
+}
+
+// Delete 删除记忆
+func (s *Store) Delete(id string) error {
+	if _, err := s.Find(id); err != nil {
+		return err
+	}
+	return persist.DB.Query(persist.Memory).DeleteById(id)
+}
+
+// Search 返回与 query 最相关的 k 条记忆,k 小于等于 0 时使用配置的数量
+func (s *Store) Search(ctx context.Context, query string, k int) ([]Result, error) {
+	if !s.cfg.Enabled || strings.TrimSpace(query) == "" {
+		return nil, nil
+	}
+	if k <= 0 {
+		k = s.cfg.TopK
+	}
+	if k <= 0 {
+		k = defaultTopK
+	}
+
+	memories, err := s.List()
+	if err != nil || len(memories) == 0 {
+		return nil, err
+	}
+
+	e, name, err := s.embedder(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := refresh(ctx, e, name, memories); err != nil {
+		return nil, err
+	}
+	vectors, err := e.EmbedStrings(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("expected 1 embedding, got %d", len(vectors))
+	}
+
+	output := make([]Result, 0, len(memories))
+	for _, m := range memories {
+		score := provider.Cosine(vectors[0], m.Embedding)
+		if score < s.cfg.MinScore {
+			continue
+		}
+		output = append(output, Result{Memory: m, Score: score})
+	}
+	sort.SliceStable(output, func(i, j int) bool {
+		return output[i].Score > output[j].Score
+	})
+	if len(output) > k {
+		output = output[:k]
+	}
+	return output, nil
+}
+
+// embed 为记忆生成向量,失败时保持为空
+func (s *Store) embed(ctx context.Context, m *Memory) {
+	if s.embedder == nil {
+		return
+	}
+	e, name, err := s.embedder(ctx)
+	if err != nil {
+		return
+	}
+	vectors, err := e.EmbedStrings(ctx, []string{m.Content})
+	if err != nil || len(vectors) != 1 {
+		return
+	}
+	m.Embedding = vectors[0]
+	m.EmbeddingModel = name
+}
+
+// refresh 为缺少向量或向量模型已变更的记忆重新生成向量并保存
+func refresh(ctx context.Context, e embedding.Embedder, name string, memories []*Memory) error {
+	stale := make([]*Memory, 0)
+	texts := make([]string, 0)
+	for _, m := range memories {
+		if len(m.Embedding) == 0 || m.EmbeddingModel != name {
+			stale = append(stale, m)
+			texts = append(texts, m.Content)
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	vectors, err := e.EmbedStrings(ctx, texts)
+	if err != nil {
+		return err
+	}
+	if len(vectors) != len(stale) {
+		return fmt.Errorf("expected %d embeddings, got %d", len(stale), len(vectors))
+	}
+	for i, m := range stale {
+		m.Embedding = vectors[i]
+		m.EmbeddingModel = name
+		if err := save(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func save(m *Memory) error {
+	doc, err := persist.NewSealedDocument(persist.Memory, m)
+	if err != nil {
+		return err
+	}
+	doc.Set("_id", m.Id)
+	return persist.DB.Query(persist.Memory).ReplaceById(m.Id, doc)
+}
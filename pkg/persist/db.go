@@ -1,38 +1,103 @@
 package persist
 
-import "github.com/ostafen/clover"
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/AntNoHuabei/Remo/internal/log"
+	"github.com/ostafen/clover"
+)
+
+// CloverDir clover 数据库的目录名
+const CloverDir = "clover.db"
 
 const Conversation = "conversation"
 const Message = "message"
 const SessionCheckpoint = "session_checkpoint"
+const Memory = "memory"
+const KnowledgeDocument = "knowledge_document"
+const KnowledgeChunk = "knowledge_chunk"
+const Usage = "usage"
+const Attachment = "attachment"
+const Setting = "setting"
+const Secret = "secret"
+
+// collections 打开数据库时需要存在的集合
+var collections = []string{Conversation, Message, SessionCheckpoint, Memory, KnowledgeDocument, KnowledgeChunk, Usage, Attachment, Setting, Secret}
 
 var DB *clover.DB
 
-func InitDB() error {
+// SQL 使用 SQLite 存储时的数据库,使用 clover 存储时为 nil
+var SQL *sql.DB
 
-	db, err := clover.Open("clover.db")
-	if err != nil {
+// InitDB 按配置打开数据目录中的数据库并执行未执行的迁移,clover 总是打开,SQLite 仅在配置为 SQLite 存储时打开。
+// 启用了加密时读取加密状态,密钥来自口令时需要调用 Unlock 后才能读写加密的数据,迁移也在解锁时执行
+func InitDB(cfg config.StorageConfig) error {
+	if err := Open(filepath.Join(cfg.Dir, CloverDir)); err != nil {
+		return err
+	}
+	if err := loadEncryption(cfg.Dir); err != nil {
 		return err
 	}
 
-	DB = db
-	if h, _ := db.HasCollection(Conversation); !h {
-		err = db.CreateCollection(Conversation)
+	switch cfg.Backend {
+	case config.StorageSQLite:
+		db, err := OpenSQLite(cfg.Dir)
 		if err != nil {
 			return err
 		}
+		SQL = db
+	case config.StorageClover:
+	default:
+		return fmt.Errorf("unsupported storage backend: %s", cfg.Backend)
 	}
-	if h, _ := db.HasCollection(Message); !h {
-		err = db.CreateCollection(Message)
-		if err != nil {
-			return err
-		}
+	// 启用加密且没有解锁时数据无法读取,迁移在解锁时执行,迁移失败时解锁失败
+	migrateData := func() error {
+		return migrate(cfg.Dir, migrations)
+	}
+	if deferUntilUnlock(migrateData) {
+		log.Info("storage is locked, data migration will run after unlocking")
+		return nil
+	}
+	return migrateData()
+}
+
+// GetSettings 返回当前存储后端中的设置
+func GetSettings() Settings {
+	if SQL != nil {
+		return NewSQLiteSettings(SQL)
 	}
-	if h, _ := db.HasCollection(SessionCheckpoint); !h {
-		err = db.CreateCollection(SessionCheckpoint)
+	return NewCloverSettings(DB)
+}
+
+// Open 打开指定目录的数据库并创建缺失的集合,新建的数据库记录为最新的迁移版本
+func Open(path string) error {
+
+	db, err := clover.Open(path)
+	if err != nil {
+		return err
+	}
+	existing, err := db.ListCollections()
+	if err != nil {
+		return err
+	}
+
+	DB = db
+	for _, name := range collections {
+		h, err := db.HasCollection(name)
 		if err != nil {
 			return err
 		}
+		if !h {
+			if err := db.CreateCollection(name); err != nil {
+				return err
+			}
+		}
+	}
+	if len(existing) == 0 {
+		return setSchemaVersion(NewCloverSettings(db), LatestVersion())
 	}
 	return nil
 }
@@ -0,0 +1,107 @@
+package persist
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/ostafen/clover"
+)
+
+// sealedField 加密后的文档中保存密文的字段,值为 base64
+const sealedField = "sealed"
+
+// sealedCollections 启用加密时加密的集合,值为需要保留明文的字段,查询、排序和清理用到的字段必须保留
+var sealedCollections = map[string][]string{
+	Conversation:      {"deleted_at"},
+	Message:           {"session", "seq"},
+	SessionCheckpoint: {"session", "checkpoint_id", "updated_time"},
+	Attachment:        {"session"},
+	Memory:            {},
+	KnowledgeDocument: {},
+	KnowledgeChunk:    {"document"},
+	Secret:            {"name"},
+}
+
+// NewDocument 按 json tag 将对象转换为文档
+// clover.NewDocumentOf 使用结构体字段名作为文档字段,与 json tag 不一致时无法通过 clover.Field 查询
+func NewDocument(v any) (*clover.Document, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return plainDocument(b)
+}
+
+// NewSealedDocument 创建保存到 collection 的文档,启用加密且集合需要加密时只保留索引字段的明文
+func NewSealedDocument(collection string, v any) (*clover.Document, error) {
+	fields, ok := sealedCollections[collection]
+	if !ok {
+		return NewDocument(v)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := Seal(b)
+	if err != nil {
+		return nil, err
+	}
+	if !IsSealed(sealed) {
+		return plainDocument(b)
+	}
+	return sealedDocument(b, sealed, fields)
+}
+
+func plainDocument(b []byte) (*clover.Document, error) {
+	var fields map[string]any
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	doc := clover.NewDocument()
+	for k, value := range fields {
+		doc.Set(k, value)
+	}
+	return doc, nil
+}
+
+// sealedDocument 创建保存密文的文档,plain 中 fields 对应的字段以明文保存
+func sealedDocument(plain []byte, sealed []byte, fields []string) (*clover.Document, error) {
+	var all map[string]any
+	if err := json.Unmarshal(plain, &all); err != nil {
+		return nil, err
+	}
+	doc := clover.NewDocument()
+	for _, k := range fields {
+		if value, ok := all[k]; ok {
+			doc.Set(k, value)
+		}
+	}
+	doc.Set(sealedField, base64.StdEncoding.EncodeToString(sealed))
+	return doc, nil
+}
+
+// Unmarshal 按 json tag 将文档解析到 v,加密的文档先解密
+func Unmarshal(doc *clover.Document, v any) error {
+	b, err := documentJSON(doc, Unseal)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// documentJSON 返回文档的 json,加密的文档用 open 解密
+func documentJSON(doc *clover.Document, open func([]byte) ([]byte, error)) ([]byte, error) {
+	if s, ok := doc.Get(sealedField).(string); ok {
+		sealed, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		return open(sealed)
+	}
+	var fields map[string]any
+	if err := doc.Unmarshal(&fields); err != nil {
+		return nil, err
+	}
+	delete(fields, "_id")
+	return json.Marshal(fields)
+}
@@ -0,0 +1,589 @@
+package persist
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/AntNoHuabei/Remo/internal/log"
+	"golang.org/x/crypto/argon2"
+)
+
+// 数据密钥的来源
+const (
+	KeySourcePassphrase = "passphrase" // 由口令经 Argon2id 派生的密钥加密,启动后需要输入口令解锁
+	KeySourceKeyring    = "keyring"    // 保存在系统钥匙串中,启动时自动解锁
+)
+
+// settingEncryption 加密状态保存在 clover 的设置中,使用 SQLite 存储时也是如此
+const settingEncryption = "encryption"
+
+var (
+	// ErrLocked 已启用加密但还没有解锁,无法读写加密的数据
+	ErrLocked = errors.New("storage is locked")
+	// ErrWrongPassphrase 口令错误
+	ErrWrongPassphrase = errors.New("wrong passphrase")
+	// ErrNotEncrypted 没有启用加密
+	ErrNotEncrypted = errors.New("encryption is not enabled")
+)
+
+// sealMagic 加密数据的前缀,没有前缀的数据按明文读取,因此启用加密前保存的数据仍然可以读取
+var sealMagic = []byte("\x00remo1")
+
+// argonParams Argon2id 的参数,保存在加密状态中,修改后已有的数据仍按原参数派生
+var argonParams = struct {
+	Time    uint32
+	Memory  uint32 // KiB
+	Threads uint8
+}{Time: 3, Memory: 64 * 1024, Threads: 4}
+
+// keyState 保存在设置中的加密状态,数据密钥用密钥加密密钥以 AES-GCM 加密后保存
+type keyState struct {
+	Source  string `json:"source"`
+	Salt    []byte `json:"salt,omitempty"`
+	Time    uint32 `json:"time,omitempty"`
+	Memory  uint32 `json:"memory,omitempty"`
+	Threads uint8  `json:"threads,omitempty"`
+	// Account 密钥加密密钥在系统钥匙串中的账户,每次生成新密钥时使用新的账户
+	Account string `json:"account,omitempty"`
+	Key     []byte `json:"key"`
+	// Previous 轮换密钥时的旧数据密钥,全部数据重新加密后删除,中途失败时仍能读取旧密钥加密的数据
+	Previous []byte `json:"previous,omitempty"`
+}
+
+// dataKey 加密数据使用的密钥
+type dataKey struct {
+	raw  []byte
+	aead cipher.AEAD
+}
+
+func newDataKey(raw []byte) (*dataKey, error) {
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &dataKey{raw: raw, aead: aead}, nil
+}
+
+// seal 返回 sealMagic、随机 nonce 和密文
+func (k *dataKey) seal(plain []byte) []byte {
+	nonce := randomBytes(k.aead.NonceSize())
+	out := make([]byte, 0, len(sealMagic)+len(nonce)+len(plain)+k.aead.Overhead())
+	out = append(out, sealMagic...)
+	out = append(out, nonce...)
+	return k.aead.Seal(out, nonce, plain, nil)
+}
+
+func (k *dataKey) open(data []byte) ([]byte, error) {
+	data = data[len(sealMagic):]
+	if len(data) < k.aead.NonceSize() {
+		return nil, errors.New("sealed data is too short")
+	}
+	return k.aead.Open(nil, data[:k.aead.NonceSize()], data[k.aead.NonceSize():], nil)
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// vault 当前的加密状态和解锁后的数据密钥。
+// 重新加密全部数据时持有写锁,期间的读写等待完成后使用新的密钥
+var vault struct {
+	sync.RWMutex
+	dir   string
+	state *keyState // nil 表示没有启用加密
+	key   *dataKey  // 没有解锁时为 nil
+	// previous 旧的数据密钥,轮换或关闭加密后仍用于读取期间写入的数据
+	previous *dataKey
+	// unlocked 解锁后执行的回调
+	unlocked []func()
+	// pending 解锁后、执行回调前必须完成的工作,如数据迁移,失败时重新锁定
+	pending func() error
+}
+
+// unlockMu 串行化 Unlock,pending 执行期间其他 Unlock 等待其完成
+var unlockMu sync.Mutex
+
+// IsSealed 判断数据是否为加密后的数据
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, sealMagic)
+}
+
+// Seal 启用加密时加密数据,没有启用时原样返回,没有解锁时返回 ErrLocked
+func Seal(plain []byte) ([]byte, error) {
+	vault.RLock()
+	defer vault.RUnlock()
+	if vault.state == nil {
+		return plain, nil
+	}
+	if vault.key == nil {
+		return nil, ErrLocked
+	}
+	return vault.key.seal(plain), nil
+}
+
+// Unseal 解密 Seal 加密的数据,明文数据原样返回
+func Unseal(data []byte) ([]byte, error) {
+	if !IsSealed(data) {
+		return data, nil
+	}
+	vault.RLock()
+	defer vault.RUnlock()
+	return openSealed(data)
+}
+
+// openSealed 依次尝试当前和旧的数据密钥,调用时需要持有 vault 的锁
+func openSealed(data []byte) ([]byte, error) {
+	if vault.key == nil && vault.previous == nil {
+		if vault.state != nil {
+			return nil, ErrLocked
+		}
+		return nil, errors.New("data is encrypted but encryption is not enabled")
+	}
+	var err error
+	for _, k := range []*dataKey{vault.key, vault.previous} {
+		if k == nil {
+			continue
+		}
+		var plain []byte
+		if plain, err = k.open(data); err == nil {
+			return plain, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to decrypt data: %w", err)
+}
+
+// Encrypted 是否启用了加密
+func Encrypted() bool {
+	vault.RLock()
+	defer vault.RUnlock()
+	return vault.state != nil
+}
+
+// Locked 已启用加密且没有解锁
+func Locked() bool {
+	vault.RLock()
+	defer vault.RUnlock()
+	return vault.state != nil && vault.key == nil
+}
+
+// EncryptionStatus 加密状态
+type EncryptionStatus struct {
+	Enabled bool   `json:"enabled"`
+	Locked  bool   `json:"locked"`
+	Source  string `json:"source,omitempty"`
+}
+
+// GetEncryptionStatus 返回加密状态
+func GetEncryptionStatus() EncryptionStatus {
+	vault.RLock()
+	defer vault.RUnlock()
+	if vault.state == nil {
+		return EncryptionStatus{}
+	}
+	return EncryptionStatus{Enabled: true, Locked: vault.key == nil, Source: vault.state.Source}
+}
+
+// AfterUnlock 在存储可以读写后执行 f:没有启用加密或已解锁时立即执行,否则在解锁后执行
+func AfterUnlock(f func()) {
+	vault.Lock()
+	if vault.state != nil && vault.key == nil {
+		vault.unlocked = append(vault.unlocked, f)
+		vault.Unlock()
+		return
+	}
+	vault.Unlock()
+	f()
+}
+
+// deferUntilUnlock 已启用加密且没有解锁时将 f 推迟到解锁时执行并返回 true,否则返回 false,由调用者立即执行。
+// f 失败时 Unlock 返回错误并重新锁定,下次解锁时再次执行
+func deferUntilUnlock(f func() error) bool {
+	vault.Lock()
+	defer vault.Unlock()
+	if vault.state == nil || vault.key != nil {
+		return false
+	}
+	vault.pending = f
+	return true
+}
+
+// loadEncryption 读取加密状态,密钥保存在系统钥匙串中时自动解锁
+func loadEncryption(dir string) error {
+	vault.Lock()
+	defer vault.Unlock()
+	vault.dir = dir
+	vault.state, vault.key, vault.previous, vault.pending = nil, nil, nil, nil
+
+	value, ok, err := NewCloverSettings(DB).Get(settingEncryption)
+	if err != nil || !ok {
+		return err
+	}
+	var state keyState
+	if err := json.Unmarshal([]byte(value), &state); err != nil {
+		return fmt.Errorf("invalid encryption state: %w", err)
+	}
+	vault.state = &state
+	if state.Source != KeySourceKeyring {
+		return nil
+	}
+	// 钥匙串暂时不可用时保持锁定,之后可以通过 Unlock 重试
+	kek, err := keyring.Get(state.Account)
+	if err != nil {
+		log.Error("failed to read the key from the system keyring, storage stays locked", "error", err)
+		return nil
+	}
+	return unwrapKeys(&state, kek)
+}
+
+// newKeyringAccount 生成系统钥匙串中的账户名,包含数据目录的摘要以区分不同的数据目录
+func newKeyringAccount(dir string) string {
+	sum := sha256.Sum256([]byte(dir))
+	return "data-key-" + hex.EncodeToString(sum[:8]) + "-" + hex.EncodeToString(randomBytes(4))
+}
+
+// deleteKeyringKey 删除不再使用的密钥加密密钥,失败时只记录日志
+func deleteKeyringKey(state *keyState) {
+	if state.Source != KeySourceKeyring {
+		return
+	}
+	if err := keyring.Delete(state.Account); err != nil {
+		log.Warn("failed to delete the key from the system keyring", "account", state.Account, "error", err)
+	}
+}
+
+// deriveKey 由口令派生密钥加密密钥
+func deriveKey(state *keyState, passphrase string) []byte {
+	return argon2.IDKey([]byte(passphrase), state.Salt, state.Time, state.Memory, state.Threads, 32)
+}
+
+// unwrapKeys 用密钥加密密钥解密 state 中的数据密钥并设置为当前密钥,调用时需要持有 vault 的写锁
+func unwrapKeys(state *keyState, kek []byte) error {
+	wrapper, err := newDataKey(kek)
+	if err != nil {
+		return err
+	}
+	raw, err := wrapper.open(state.Key)
+	if err != nil {
+		return ErrWrongPassphrase
+	}
+	key, err := newDataKey(raw)
+	if err != nil {
+		return err
+	}
+	var previous *dataKey
+	if len(state.Previous) > 0 {
+		raw, err := wrapper.open(state.Previous)
+		if err != nil {
+			return ErrWrongPassphrase
+		}
+		if previous, err = newDataKey(raw); err != nil {
+			return err
+		}
+	}
+	vault.key, vault.previous = key, previous
+	return nil
+}
+
+// Unlock 使用口令解锁,密钥保存在系统钥匙串中时忽略 passphrase 并重新读取钥匙串
+func Unlock(passphrase string) error {
+	unlockMu.Lock()
+	defer unlockMu.Unlock()
+	vault.Lock()
+	if vault.state == nil {
+		vault.Unlock()
+		return ErrNotEncrypted
+	}
+	if vault.key != nil {
+		vault.Unlock()
+		return nil
+	}
+	kek, err := keyEncryptionKey(vault.state, passphrase)
+	if err == nil {
+		err = unwrapKeys(vault.state, kek)
+	}
+	pending := vault.pending
+	vault.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// pending 需要读写加密的数据,不能持有 vault 的锁执行
+	if pending != nil {
+		if err := pending(); err != nil {
+			Lock()
+			return err
+		}
+	}
+	vault.Lock()
+	callbacks := vault.unlocked
+	vault.unlocked, vault.pending = nil, nil
+	vault.Unlock()
+
+	log.Info("storage unlocked")
+	for _, f := range callbacks {
+		f()
+	}
+	return nil
+}
+
+// Lock 从内存中清除数据密钥,之后需要重新解锁才能读写加密的数据
+func Lock() error {
+	vault.Lock()
+	defer vault.Unlock()
+	if vault.state == nil {
+		return ErrNotEncrypted
+	}
+	vault.key, vault.previous = nil, nil
+	return nil
+}
+
+// keyEncryptionKey 按 state 的来源获取密钥加密密钥
+func keyEncryptionKey(state *keyState, passphrase string) ([]byte, error) {
+	if state.Source == KeySourceKeyring {
+		return keyring.Get(state.Account)
+	}
+	if passphrase == "" {
+		return nil, ErrWrongPassphrase
+	}
+	return deriveKey(state, passphrase), nil
+}
+
+// KeyOptions 启用加密或轮换密钥时的密钥来源
+type KeyOptions struct {
+	Source     string // KeySourcePassphrase 或 KeySourceKeyring
+	Passphrase string // 来源为 KeySourcePassphrase 时的口令
+}
+
+// newKeyState 按 opts 生成新的数据密钥,返回加密状态、数据密钥和加密数据密钥用的密钥。
+// 来源为钥匙串时密钥加密密钥写入新的账户,不影响正在使用的密钥
+func newKeyState(opts KeyOptions) (*keyState, *dataKey, *dataKey, error) {
+	state := &keyState{Source: opts.Source}
+	var kek []byte
+	switch opts.Source {
+	case KeySourcePassphrase:
+		if opts.Passphrase == "" {
+			return nil, nil, nil, errors.New("passphrase is required")
+		}
+		state.Salt = randomBytes(16)
+		state.Time, state.Memory, state.Threads = argonParams.Time, argonParams.Memory, argonParams.Threads
+		kek = deriveKey(state, opts.Passphrase)
+	case KeySourceKeyring:
+		kek = randomBytes(32)
+		state.Account = newKeyringAccount(vault.dir)
+		if err := keyring.Set(state.Account, kek); err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to save the key to the system keyring: %w", err)
+		}
+	default:
+		return nil, nil, nil, fmt.Errorf("unsupported key source: %s", opts.Source)
+	}
+
+	wrapper, err := newDataKey(kek)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	key, err := newDataKey(randomBytes(32))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	state.Key = wrapper.seal(key.raw)
+	return state, key, wrapper, nil
+}
+
+func saveKeyState(state *keyState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return NewCloverSettings(DB).Set(settingEncryption, string(b))
+}
+
+// checkPassphrase 确认 passphrase 是当前的口令,密钥保存在钥匙串中时不需要口令。调用时需要持有 vault 的写锁
+func checkPassphrase(passphrase string) error {
+	if vault.state == nil {
+		return ErrNotEncrypted
+	}
+	if vault.key == nil {
+		return ErrLocked
+	}
+	if vault.state.Source == KeySourceKeyring {
+		return nil
+	}
+	wrapper, err := newDataKey(deriveKey(vault.state, passphrase))
+	if err != nil {
+		return err
+	}
+	if _, err := wrapper.open(vault.state.Key); err != nil {
+		return ErrWrongPassphrase
+	}
+	return nil
+}
+
+// commitTx 提交 exclusive 的事务,测试时替换以模拟提交失败
+var commitTx = (*sql.Tx).Commit
+
+// exclusive 在 SQLite 的写事务中持有 vault 的写锁执行 f,事务提交成功后仍持有锁执行 committed。
+// 先开始事务再加锁,已经开始的写事务可以完成加密后提交,不会与 f 互相等待。
+// 删除旧密钥等操作放在 committed 中,提交失败时 SQLite 中仍是旧密钥加密的数据,旧密钥不能丢失
+func exclusive(f func(tx *sql.Tx) error, committed func() error) error {
+	var tx *sql.Tx
+	if SQL != nil {
+		var err error
+		if tx, err = SQL.Begin(); err != nil {
+			return err
+		}
+	}
+	vault.Lock()
+	defer vault.Unlock()
+	if err := f(tx); err != nil {
+		if tx != nil {
+			tx.Rollback()
+		}
+		return err
+	}
+	if tx != nil {
+		if err := commitTx(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if committed == nil {
+		return nil
+	}
+	return committed()
+}
+
+// EnableEncryption 生成数据密钥并加密已有的数据。
+// 加密状态先于数据保存,中途失败时已加密和未加密的数据都可以读取,之后的写入使用新密钥加密
+func EnableEncryption(opts KeyOptions) error {
+	err := exclusive(func(tx *sql.Tx) error {
+		if vault.state != nil {
+			return errors.New("encryption is already enabled")
+		}
+		state, key, _, err := newKeyState(opts)
+		if err != nil {
+			return err
+		}
+		if err := saveKeyState(state); err != nil {
+			return err
+		}
+		vault.state, vault.key, vault.previous = state, key, nil
+		return reseal(tx, key)
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to enable encryption: %w", err)
+	}
+	log.Info("storage encryption enabled", "source", opts.Source)
+	return nil
+}
+
+// RotateKey 生成新的数据密钥并重新加密全部数据,opts 可以同时更换口令或密钥来源,passphrase 为当前的口令。
+// 旧的数据密钥保存到事务提交为止,中途失败时两个密钥加密的数据都可以读取,可以再次轮换
+func RotateKey(passphrase string, opts KeyOptions) error {
+	// 上次轮换没有完成时先用当前密钥重新加密,之后只需要保留一个旧密钥
+	if err := finishRotation(passphrase); err != nil {
+		return fmt.Errorf("failed to rotate key: %w", err)
+	}
+	var old, state *keyState
+	err := exclusive(func(tx *sql.Tx) error {
+		if err := checkPassphrase(passphrase); err != nil {
+			return err
+		}
+		old = vault.state
+		oldKey := vault.key
+		var key, wrapper *dataKey
+		var err error
+		if state, key, wrapper, err = newKeyState(opts); err != nil {
+			return err
+		}
+		state.Previous = wrapper.seal(oldKey.raw)
+		if err := saveKeyState(state); err != nil {
+			deleteKeyringKey(state)
+			return err
+		}
+		vault.state, vault.key, vault.previous = state, key, oldKey
+		return reseal(tx, key)
+	}, func() error {
+		// 数据都已用新密钥保存,删除旧密钥
+		deleteKeyringKey(old)
+		return dropPreviousKey()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to rotate key: %w", err)
+	}
+	log.Info("storage encryption key rotated", "source", opts.Source)
+	return nil
+}
+
+// finishRotation 用当前密钥重新加密没有完成轮换的数据并删除旧密钥,没有旧密钥时不做任何事
+func finishRotation(passphrase string) error {
+	vault.RLock()
+	pending := vault.previous != nil && vault.state != nil
+	vault.RUnlock()
+	if !pending {
+		return nil
+	}
+	return exclusive(func(tx *sql.Tx) error {
+		if err := checkPassphrase(passphrase); err != nil {
+			return err
+		}
+		if pending = vault.previous != nil; !pending {
+			return nil
+		}
+		return reseal(tx, vault.key)
+	}, func() error {
+		if !pending {
+			return nil
+		}
+		return dropPreviousKey()
+	})
+}
+
+// dropPreviousKey 从加密状态中删除旧的数据密钥,调用时需要持有 vault 的写锁
+func dropPreviousKey() error {
+	vault.state.Previous = nil
+	if err := saveKeyState(vault.state); err != nil {
+		return err
+	}
+	vault.previous = nil
+	return nil
+}
+
+// DisableEncryption 解密全部数据并删除加密状态,passphrase 为当前的口令
+func DisableEncryption(passphrase string) error {
+	err := exclusive(func(tx *sql.Tx) error {
+		if err := checkPassphrase(passphrase); err != nil {
+			return err
+		}
+		return reseal(tx, nil)
+	}, func() error {
+		// 数据都已解密保存后才删除加密状态,之前失败时仍可以用原来的密钥读取
+		if err := DB.Query(Setting).DeleteById(settingDocId(settingEncryption)); err != nil {
+			return err
+		}
+		deleteKeyringKey(vault.state)
+		vault.state, vault.key, vault.previous = nil, nil, vault.key
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to disable encryption: %w", err)
+	}
+	log.Info("storage encryption disabled")
+	return nil
+}
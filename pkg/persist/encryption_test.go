@@ -0,0 +1,395 @@
+package persist
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/ostafen/clover"
+)
+
+// memKeyring 测试用的内存钥匙串
+type memKeyring map[string][]byte
+
+func (k memKeyring) Get(account string) ([]byte, error) {
+	if secret, ok := k[account]; ok {
+		return secret, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+func (k memKeyring) Set(account string, secret []byte) error {
+	k[account] = secret
+	return nil
+}
+
+func (k memKeyring) Delete(account string) error {
+	delete(k, account)
+	return nil
+}
+
+// openEncryptionTestDB 打开测试数据库,使用内存钥匙串和较快的 Argon2 参数,结束时清除加密状态
+func openEncryptionTestDB(t *testing.T) (string, memKeyring) {
+	dir := openTestDB(t)
+	ring := memKeyring{}
+	keyring = ring
+	params := argonParams
+	argonParams.Time, argonParams.Memory, argonParams.Threads = 1, 64, 1
+	if err := loadEncryption(dir); err != nil {
+		t.Fatalf("loadEncryption failed: %v", err)
+	}
+	t.Cleanup(func() {
+		keyring = systemKeyring{}
+		argonParams = params
+		vault.state, vault.key, vault.previous, vault.unlocked, vault.pending = nil, nil, nil, nil, nil
+	})
+	return dir, ring
+}
+
+// testMessage 消息文档用到的字段
+type testMessage struct {
+	Session string `json:"session"`
+	Seq     int    `json:"seq"`
+	Content string `json:"content"`
+}
+
+// insertTestData 在 clover 和 SQLite 中各保存一条消息
+func insertTestData(t *testing.T) {
+	doc, err := NewSealedDocument(Message, &testMessage{Session: "s1", Seq: 1, Content: "secret text"})
+	if err != nil {
+		t.Fatalf("NewSealedDocument failed: %v", err)
+	}
+	doc.Set("_id", "8b0f6e9a-4d3c-4f7e-9a1b-2c3d4e5f6a7b")
+	if _, err := DB.InsertOne(Message, doc); err != nil {
+		t.Fatalf("InsertOne failed: %v", err)
+	}
+	if _, err := SQL.Exec(`INSERT INTO messages (id, session, seq, content, data) VALUES ('m1', 's1', 1, 'secret text', ?)`,
+		[]byte(`{"session":"s1","seq":1,"content":"secret text"}`)); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+}
+
+// readTestData 返回 clover 中的消息内容和 SQLite 中的 data、content 列
+func readTestData(t *testing.T) (string, []byte, string) {
+	doc, err := DB.Query(Message).FindById("8b0f6e9a-4d3c-4f7e-9a1b-2c3d4e5f6a7b")
+	if err != nil || doc == nil {
+		t.Fatalf("FindById failed: %v", err)
+	}
+	var m testMessage
+	if err := Unmarshal(doc, &m); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	var data []byte
+	var content string
+	if err := SQL.QueryRow(`SELECT data, content FROM messages WHERE id = 'm1'`).Scan(&data, &content); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	return m.Content, data, content
+}
+
+func TestEncryptionPassphrase(t *testing.T) {
+	dir, _ := openEncryptionTestDB(t)
+	insertTestData(t)
+
+	if err := EnableEncryption(KeyOptions{Source: KeySourcePassphrase}); err == nil {
+		t.Error("Expected an error without a passphrase")
+	}
+	if err := EnableEncryption(KeyOptions{Source: KeySourcePassphrase, Passphrase: "correct horse"}); err != nil {
+		t.Fatalf("EnableEncryption failed: %v", err)
+	}
+
+	// 索引字段保持明文,其他字段加密
+	doc, _ := DB.Query(Message).FindById("8b0f6e9a-4d3c-4f7e-9a1b-2c3d4e5f6a7b")
+	if doc.Get("session") != "s1" || doc.Has("content") || !doc.Has(sealedField) {
+		t.Errorf("Expected only index fields in plaintext, got %v", doc)
+	}
+	content, data, column := readTestData(t)
+	if content != "secret text" || !IsSealed(data) || column != "" {
+		t.Errorf("Unexpected data after enabling encryption: %q %q %q", content, data, column)
+	}
+
+	// 重新打开后需要解锁
+	if err := loadEncryption(dir); err != nil {
+		t.Fatalf("loadEncryption failed: %v", err)
+	}
+	if !Locked() {
+		t.Fatal("Expected the storage to be locked")
+	}
+	if _, err := Seal([]byte("x")); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected ErrLocked, got %v", err)
+	}
+	unlocked := false
+	AfterUnlock(func() { unlocked = true })
+	if err := Unlock("wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected ErrWrongPassphrase, got %v", err)
+	}
+	if err := Unlock("correct horse"); err != nil || !unlocked {
+		t.Fatalf("Expected Unlock to run callbacks, got %v %v", err, unlocked)
+	}
+	if content, _, _ := readTestData(t); content != "secret text" {
+		t.Errorf("Unexpected content after unlock: %q", content)
+	}
+}
+
+func TestDeferUntilUnlock(t *testing.T) {
+	dir, _ := openEncryptionTestDB(t)
+	insertTestData(t)
+	if deferUntilUnlock(func() error { return nil }) {
+		t.Error("Expected work to run immediately without encryption")
+	}
+	if err := EnableEncryption(KeyOptions{Source: KeySourcePassphrase, Passphrase: "pass"}); err != nil {
+		t.Fatalf("EnableEncryption failed: %v", err)
+	}
+
+	// 锁定时推迟到解锁,执行时可以读取加密的数据
+	loadEncryption(dir)
+	runs := 0
+	fail := true
+	deferred := deferUntilUnlock(func() error {
+		runs++
+		if content, _, _ := readTestData(t); content != "secret text" {
+			t.Errorf("Expected the data to be readable, got %q", content)
+		}
+		if fail {
+			return errors.New("migration failed")
+		}
+		return nil
+	})
+	if !deferred {
+		t.Fatal("Expected work to be deferred while locked")
+	}
+	unlocked := false
+	AfterUnlock(func() { unlocked = true })
+
+	// 失败时解锁失败并保持锁定,回调不执行
+	if err := Unlock("pass"); err == nil || !Locked() || unlocked {
+		t.Fatalf("Expected Unlock to fail and stay locked, got %v %v %v", err, Locked(), unlocked)
+	}
+	fail = false
+	if err := Unlock("pass"); err != nil || Locked() || !unlocked || runs != 2 {
+		t.Fatalf("Expected the deferred work to run again, got %v %v %v %d", err, Locked(), unlocked, runs)
+	}
+	Lock()
+	if err := Unlock("pass"); err != nil || runs != 2 {
+		t.Errorf("Expected the deferred work to run once after succeeding, got %v %d", err, runs)
+	}
+}
+
+func TestRotateKey(t *testing.T) {
+	dir, _ := openEncryptionTestDB(t)
+	insertTestData(t)
+	if err := EnableEncryption(KeyOptions{Source: KeySourcePassphrase, Passphrase: "old"}); err != nil {
+		t.Fatalf("EnableEncryption failed: %v", err)
+	}
+	_, before, _ := readTestData(t)
+
+	if err := RotateKey("wrong", KeyOptions{Source: KeySourcePassphrase, Passphrase: "new"}); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected ErrWrongPassphrase, got %v", err)
+	}
+	if err := RotateKey("old", KeyOptions{Source: KeySourcePassphrase, Passphrase: "new"}); err != nil {
+		t.Fatalf("RotateKey failed: %v", err)
+	}
+	content, after, _ := readTestData(t)
+	if content != "secret text" || string(after) == string(before) {
+		t.Errorf("Expected the data to be encrypted again, got %q", content)
+	}
+
+	// 旧口令不再有效,旧数据密钥已删除
+	loadEncryption(dir)
+	if err := Unlock("old"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected the old passphrase to be rejected, got %v", err)
+	}
+	if err := Unlock("new"); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if vault.previous != nil || len(vault.state.Previous) != 0 {
+		t.Error("Expected the previous key to be removed")
+	}
+	if content, _, _ := readTestData(t); content != "secret text" {
+		t.Errorf("Unexpected content after rotation: %q", content)
+	}
+}
+
+// failCommit 让 exclusive 的事务提交失败
+func failCommit(t *testing.T) {
+	commitTx = func(tx *sql.Tx) error { return errors.New("disk full") }
+	t.Cleanup(func() { commitTx = (*sql.Tx).Commit })
+}
+
+func TestCommitFailure(t *testing.T) {
+	dir, _ := openEncryptionTestDB(t)
+	insertTestData(t)
+	if err := EnableEncryption(KeyOptions{Source: KeySourcePassphrase, Passphrase: "old"}); err != nil {
+		t.Fatalf("EnableEncryption failed: %v", err)
+	}
+
+	// 轮换的事务提交失败时保留旧密钥,SQLite 中旧密钥加密的数据仍可以读取
+	failCommit(t)
+	if err := RotateKey("old", KeyOptions{Source: KeySourcePassphrase, Passphrase: "new"}); err == nil {
+		t.Fatal("Expected RotateKey to fail")
+	}
+	loadEncryption(dir)
+	if err := Unlock("new"); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if len(vault.state.Previous) == 0 {
+		t.Fatal("Expected the previous key to be kept")
+	}
+	_, data, _ := readTestData(t)
+	if plain, err := Unseal(data); err != nil || !strings.Contains(string(plain), "secret text") {
+		t.Errorf("Expected the old data to stay readable, got %q %v", plain, err)
+	}
+
+	// 关闭加密的事务提交失败时保留加密状态
+	if err := DisableEncryption("new"); err == nil {
+		t.Fatal("Expected DisableEncryption to fail")
+	}
+	loadEncryption(dir)
+	if !Encrypted() {
+		t.Fatal("Expected encryption to stay enabled")
+	}
+	if err := Unlock("new"); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	_, data, _ = readTestData(t)
+	if plain, err := Unseal(data); err != nil || !strings.Contains(string(plain), "secret text") {
+		t.Errorf("Expected the data to stay readable, got %q %v", plain, err)
+	}
+
+	// 提交恢复后可以再次轮换和关闭
+	commitTx = (*sql.Tx).Commit
+	if err := RotateKey("new", KeyOptions{Source: KeySourcePassphrase, Passphrase: "newer"}); err != nil {
+		t.Fatalf("RotateKey failed: %v", err)
+	}
+	if vault.previous != nil || len(vault.state.Previous) != 0 {
+		t.Error("Expected the previous key to be removed")
+	}
+	if err := DisableEncryption("newer"); err != nil {
+		t.Fatalf("DisableEncryption failed: %v", err)
+	}
+	if content, data, _ := readTestData(t); content != "secret text" || IsSealed(data) {
+		t.Errorf("Expected plaintext data, got %q %q", content, data)
+	}
+}
+
+func TestKeyringAndDisable(t *testing.T) {
+	dir, ring := openEncryptionTestDB(t)
+	insertTestData(t)
+	if err := EnableEncryption(KeyOptions{Source: KeySourceKeyring}); err != nil {
+		t.Fatalf("EnableEncryption failed: %v", err)
+	}
+	if len(ring) != 1 {
+		t.Fatalf("Expected the key to be saved in the keyring, got %d keys", len(ring))
+	}
+
+	// 密钥来自钥匙串时打开后自动解锁
+	loadEncryption(dir)
+	if status := GetEncryptionStatus(); !status.Enabled || status.Locked || status.Source != KeySourceKeyring {
+		t.Errorf("Expected the storage to be unlocked, got %+v", status)
+	}
+
+	if err := RotateKey("", KeyOptions{Source: KeySourcePassphrase, Passphrase: "pass"}); err != nil {
+		t.Fatalf("RotateKey failed: %v", err)
+	}
+	if len(ring) != 0 {
+		t.Errorf("Expected the keyring entry to be deleted, got %d keys", len(ring))
+	}
+
+	if err := DisableEncryption("pass"); err != nil {
+		t.Fatalf("DisableEncryption failed: %v", err)
+	}
+	content, data, column := readTestData(t)
+	if content != "secret text" || IsSealed(data) || column != "secret text" {
+		t.Errorf("Expected plaintext data after disabling, got %q %q %q", content, data, column)
+	}
+	loadEncryption(dir)
+	if Encrypted() {
+		t.Error("Expected encryption to stay disabled after reopening")
+	}
+}
+
+func TestSecrets(t *testing.T) {
+	dir, _ := openEncryptionTestDB(t)
+	if err := EnableEncryption(KeyOptions{Source: KeySourcePassphrase, Passphrase: "pass"}); err != nil {
+		t.Fatalf("EnableEncryption failed: %v", err)
+	}
+
+	cfg, err := config.Init(filepath.Join(t.TempDir(), "app.json"))
+	if err != nil {
+		t.Fatalf("config.Init failed: %v", err)
+	}
+	if err := cfg.Update(func(c *config.Config) {
+		c.Models.Providers[0].APIKey = "sk-provider"
+		c.Gateway.APIKey = "sk-gateway"
+	}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	provider := cfg.GetModels().Providers[0].Name
+
+	if n, err := MoveSecrets(cfg); err != nil || n != 2 {
+		t.Fatalf("Expected two keys to be moved, got %d %v", n, err)
+	}
+	if cfg.GetModels().Providers[0].APIKey != "" || cfg.GetGateway().APIKey != "" {
+		t.Error("Expected the keys to be removed from the config")
+	}
+	if key, ok, err := GetSecret(ProviderSecret(provider)); err != nil || !ok || key != "sk-provider" {
+		t.Errorf("Unexpected provider key %q %v %v", key, ok, err)
+	}
+	doc, _ := DB.Query(Secret).FindById(secretDocId(GatewaySecret))
+	if doc == nil || doc.Has("value") {
+		t.Errorf("Expected the secret value to be encrypted, got %v", doc)
+	}
+
+	loadEncryption(dir)
+	if _, _, err := GetSecret(GatewaySecret); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected ErrLocked, got %v", err)
+	}
+	Unlock("pass")
+	if names, _ := SecretNames(); len(names) != 2 {
+		t.Errorf("Expected two secrets, got %v", names)
+	}
+	if err := SetSecret(GatewaySecret, ""); err != nil {
+		t.Fatalf("SetSecret failed: %v", err)
+	}
+	if _, ok, _ := GetSecret(GatewaySecret); ok {
+		t.Error("Expected the secret to be deleted")
+	}
+}
+
+func TestSealKnowledge(t *testing.T) {
+	openEncryptionTestDB(t)
+	// 旧版本以明文保存的片段
+	doc, _ := NewDocument(map[string]any{"document": "d1", "index": 0, "content": "secret chunk"})
+	doc.Set("_id", "0c1d2e3f-4a5b-4c6d-8e7f-9a0b1c2d3e4f")
+	if _, err := DB.InsertOne(KnowledgeChunk, doc); err != nil {
+		t.Fatalf("InsertOne failed: %v", err)
+	}
+	if err := sealKnowledge(DB); err != nil {
+		t.Fatalf("sealKnowledge failed: %v", err)
+	}
+	if doc, _ := DB.Query(KnowledgeChunk).FindById("0c1d2e3f-4a5b-4c6d-8e7f-9a0b1c2d3e4f"); doc.Has(sealedField) {
+		t.Error("Expected no change without encryption")
+	}
+
+	if err := EnableEncryption(KeyOptions{Source: KeySourcePassphrase, Passphrase: "pass"}); err != nil {
+		t.Fatalf("EnableEncryption failed: %v", err)
+	}
+	chunk, _ := DB.Query(KnowledgeChunk).Where(clover.Field("document").Eq("d1")).FindFirst()
+	if chunk == nil || chunk.Has("content") || !chunk.Has(sealedField) {
+		t.Fatalf("Expected the chunk to be encrypted with the document id in plaintext, got %v", chunk)
+	}
+
+	// 启用加密后仍以明文保存的片段在迁移时加密
+	DB.Query(KnowledgeChunk).ReplaceById(chunk.ObjectId(), doc)
+	if err := sealKnowledge(DB); err != nil {
+		t.Fatalf("sealKnowledge failed: %v", err)
+	}
+	chunk, _ = DB.Query(KnowledgeChunk).FindById(chunk.ObjectId())
+	var got map[string]any
+	if err := Unmarshal(chunk, &got); err != nil || chunk.Has("content") || got["content"] != "secret chunk" {
+		t.Errorf("Expected the plaintext chunk to be encrypted, got %v %v", got, err)
+	}
+}
@@ -0,0 +1,22 @@
+package persist
+
+import "errors"
+
+// keyringService 系统钥匙串中的服务名
+const keyringService = "Remo"
+
+// ErrKeyNotFound 系统钥匙串中没有对应的密钥
+var ErrKeyNotFound = errors.New("key not found in the system keyring")
+
+// Keyring 系统的凭据存储,保存密钥加密密钥
+type Keyring interface {
+	// Get 返回账户的密钥,不存在时返回 ErrKeyNotFound
+	Get(account string) ([]byte, error)
+	Set(account string, secret []byte) error
+	Delete(account string) error
+}
+
+// keyring 当前平台的系统钥匙串,测试时替换为内存实现
+var keyring Keyring = systemKeyring{}
+
+type systemKeyring struct{}
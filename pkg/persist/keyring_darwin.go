@@ -0,0 +1,52 @@
+package persist
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// errItemNotFound security 命令找不到钥匙串项目时的退出码
+const errItemNotFound = 44
+
+// systemKeyring 通过 security 命令读写登录钥匙串,密钥以十六进制保存
+func (systemKeyring) Get(account string) ([]byte, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", keyringService, "-a", account, "-w").Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == errItemNotFound {
+			return nil, ErrKeyNotFound
+		}
+		return nil, err
+	}
+	return hex.DecodeString(strings.TrimSpace(string(out)))
+}
+
+// Set 以交互模式从标准输入传入命令,密钥不出现在进程参数中,避免被其他进程通过 ps 看到
+func (systemKeyring) Set(account string, secret []byte) error {
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %q -a %q -w %s\n",
+		keyringService, account, hex.EncodeToString(secret)))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+	// 交互模式下命令失败时退出码仍为 0,只能根据错误输出判断
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return errors.New(msg)
+	}
+	return nil
+}
+
+func (systemKeyring) Delete(account string) error {
+	err := exec.Command("security", "delete-generic-password", "-s", keyringService, "-a", account).Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == errItemNotFound {
+		return nil
+	}
+	return err
+}
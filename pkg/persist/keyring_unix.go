@@ -0,0 +1,33 @@
+//go:build !windows && !darwin
+
+package persist
+
+import (
+	"encoding/hex"
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// systemKeyring 通过 secret-tool 命令读写 Secret Service,密钥以十六进制保存
+func (systemKeyring) Get(account string) ([]byte, error) {
+	out, err := exec.Command("secret-tool", "lookup", "service", keyringService, "account", account).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(out) == 0 {
+			return nil, ErrKeyNotFound
+		}
+		return nil, err
+	}
+	return hex.DecodeString(strings.TrimSpace(string(out)))
+}
+
+func (systemKeyring) Set(account string, secret []byte) error {
+	cmd := exec.Command("secret-tool", "store", "--label="+keyringService+" "+account, "service", keyringService, "account", account)
+	cmd.Stdin = strings.NewReader(hex.EncodeToString(secret))
+	return cmd.Run()
+}
+
+func (systemKeyring) Delete(account string) error {
+	return exec.Command("secret-tool", "clear", "service", keyringService, "account", account).Run()
+}
@@ -0,0 +1,98 @@
+package persist
+
+import (
+	"errors"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	advapi32        = syscall.NewLazyDLL("advapi32.dll")
+	procCredReadW   = advapi32.NewProc("CredReadW")
+	procCredWriteW  = advapi32.NewProc("CredWriteW")
+	procCredDeleteW = advapi32.NewProc("CredDeleteW")
+	procCredFree    = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+	// errNotFound ERROR_NOT_FOUND,凭据不存在
+	errNotFound syscall.Errno = 1168
+)
+
+// credential CREDENTIALW 结构
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// credTarget Windows 凭据管理器中的目标名称
+func credTarget(account string) (*uint16, error) {
+	return syscall.UTF16PtrFromString(keyringService + ":" + account)
+}
+
+func (systemKeyring) Get(account string) ([]byte, error) {
+	target, err := credTarget(account)
+	if err != nil {
+		return nil, err
+	}
+	var cred *credential
+	r, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if r == 0 {
+		if errors.Is(err, errNotFound) {
+			return nil, ErrKeyNotFound
+		}
+		return nil, err
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+	secret := make([]byte, cred.CredentialBlobSize)
+	copy(secret, unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize))
+	return secret, nil
+}
+
+func (systemKeyring) Set(account string, secret []byte) error {
+	target, err := credTarget(account)
+	if err != nil {
+		return err
+	}
+	user, err := syscall.UTF16PtrFromString(account)
+	if err != nil {
+		return err
+	}
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(secret)),
+		Persist:            credPersistLocalMachine,
+		UserName:           user,
+	}
+	if len(secret) > 0 {
+		cred.CredentialBlob = &secret[0]
+	}
+	if r, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0); r == 0 {
+		return err
+	}
+	return nil
+}
+
+func (systemKeyring) Delete(account string) error {
+	target, err := credTarget(account)
+	if err != nil {
+		return err
+	}
+	if r, _, err := procCredDeleteW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0); r == 0 && !errors.Is(err, errNotFound) {
+		return err
+	}
+	return nil
+}
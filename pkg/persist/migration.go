@@ -0,0 +1,172 @@
+package persist
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/AntNoHuabei/Remo/internal/log"
+	"github.com/ostafen/clover"
+)
+
+// settingSchemaVersion 记录已执行的最后一个迁移的版本
+const settingSchemaVersion = "schema_version"
+
+// BackupDir 数据目录中存放迁移前备份的目录名
+const BackupDir = "backups"
+
+// keepBackups 保留的备份数量,超过时删除最早的备份
+const keepBackups = 5
+
+// Migration 数据迁移,按 Version 从小到大执行。
+// clover 和 SQLite 分别记录已执行的版本,只需要迁移其中一个时另一个留空
+type Migration struct {
+	Version int
+	Name    string
+	// Clover 迁移 clover 中的数据,clover 不支持事务,迁移需要可以重复执行
+	Clover func(db *clover.DB) error
+	// SQLite 在事务中迁移 SQLite 中的数据,与版本记录在同一事务中提交
+	SQLite func(tx *sql.Tx) error
+}
+
+// LatestVersion 返回当前版本的数据结构对应的迁移版本
+func LatestVersion() int {
+	return latestVersion(migrations)
+}
+
+func latestVersion(migrations []Migration) int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// SchemaVersion 返回设置中记录的迁移版本,没有记录时为 0
+func SchemaVersion(settings Settings) (int, error) {
+	value, ok, err := settings.Get(settingSchemaVersion)
+	if err != nil || !ok {
+		return 0, err
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid schema version %q: %w", value, err)
+	}
+	return version, nil
+}
+
+func setSchemaVersion(settings Settings, version int) error {
+	return settings.Set(settingSchemaVersion, strconv.Itoa(version))
+}
+
+// migrate 执行 DB 和 SQL 中未执行的迁移,执行前将数据备份到 dir 下的 BackupDir。
+// 记录的版本比 migrations 新时说明数据来自更新的版本,返回错误而不是按旧的结构读写
+func migrate(dir string, migrations []Migration) error {
+	latest := latestVersion(migrations)
+
+	cloverVersion, err := SchemaVersion(NewCloverSettings(DB))
+	if err != nil {
+		return err
+	}
+	if cloverVersion > latest {
+		return fmt.Errorf("clover schema version %d is newer than supported version %d", cloverVersion, latest)
+	}
+	sqliteVersion := latest
+	if SQL != nil {
+		if sqliteVersion, err = SchemaVersion(NewSQLiteSettings(SQL)); err != nil {
+			return err
+		}
+		if sqliteVersion > latest {
+			return fmt.Errorf("sqlite schema version %d is newer than supported version %d", sqliteVersion, latest)
+		}
+	}
+	if cloverVersion == latest && sqliteVersion == latest {
+		return nil
+	}
+
+	backup, err := backupDB(dir, min(cloverVersion, sqliteVersion))
+	if err != nil {
+		return fmt.Errorf("failed to back up before migration: %w", err)
+	}
+	log.Info("backed up database before migration", "path", backup)
+
+	for _, m := range migrations {
+		if m.Version > cloverVersion {
+			if m.Clover != nil {
+				if err := m.Clover(DB); err != nil {
+					return fmt.Errorf("clover migration %d (%s) failed, backup is in %s: %w", m.Version, m.Name, backup, err)
+				}
+			}
+			if err := setSchemaVersion(NewCloverSettings(DB), m.Version); err != nil {
+				return err
+			}
+		}
+		if m.Version > sqliteVersion {
+			err := Tx(SQL, func(tx *sql.Tx) error {
+				if m.SQLite != nil {
+					if err := m.SQLite(tx); err != nil {
+						return err
+					}
+				}
+				_, err := tx.Exec(upsertSetting, settingSchemaVersion, strconv.Itoa(m.Version))
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("sqlite migration %d (%s) failed, backup is in %s: %w", m.Version, m.Name, backup, err)
+			}
+		}
+		log.Info("applied migration", "version", m.Version, "name", m.Name)
+	}
+	return nil
+}
+
+// backupDB 将 clover 的全部集合导出为 json,SQLite 复制为新的数据库文件,返回备份所在的目录
+func backupDB(dir string, version int) (string, error) {
+	root := filepath.Join(dir, BackupDir)
+	path := filepath.Join(root, fmt.Sprintf("%s-v%d", time.Now().Format("20060102-150405.000"), version))
+	if err := os.MkdirAll(filepath.Join(path, CloverDir), 0755); err != nil {
+		return "", err
+	}
+
+	names, err := DB.ListCollections()
+	if err != nil {
+		return "", err
+	}
+	for _, name := range names {
+		if err := DB.ExportCollection(name, filepath.Join(path, CloverDir, name+".json")); err != nil {
+			return "", fmt.Errorf("failed to export collection %s: %w", name, err)
+		}
+	}
+	if SQL != nil {
+		if _, err := SQL.Exec(`VACUUM INTO ?`, filepath.Join(path, SQLiteFile)); err != nil {
+			return "", fmt.Errorf("failed to copy sqlite database: %w", err)
+		}
+	}
+
+	pruneBackups(root)
+	return path, nil
+}
+
+// pruneBackups 备份目录名以时间开头,按名称排序后删除最早的备份
+func pruneBackups(root string) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+	var backups []string
+	for _, e := range entries {
+		if e.IsDir() {
+			backups = append(backups, e.Name())
+		}
+	}
+	sort.Strings(backups)
+	for len(backups) > keepBackups {
+		if err := os.RemoveAll(filepath.Join(root, backups[0])); err != nil {
+			log.Warn("failed to remove old backup", "path", backups[0], "error", err)
+		}
+		backups = backups[1:]
+	}
+}
@@ -0,0 +1,210 @@
+package persist
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AntNoHuabei/Remo/internal/log"
+	"github.com/ostafen/clover"
+)
+
+func TestMain(m *testing.M) {
+	// 只输出到控制台,避免在包目录下生成日志文件
+	if err := log.Init(&log.Config{Level: "error"}); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// openTestDB 在临时目录中打开 clover 和 SQLite
+func openTestDB(t *testing.T) string {
+	dir := t.TempDir()
+	if err := Open(filepath.Join(dir, CloverDir)); err != nil {
+		t.Fatalf("Failed to open clover: %v", err)
+	}
+	db, err := OpenSQLite(dir)
+	if err != nil {
+		t.Fatalf("Failed to open sqlite: %v", err)
+	}
+	SQL = db
+	t.Cleanup(func() {
+		DB.Close()
+		SQL.Close()
+		SQL = nil
+	})
+	return dir
+}
+
+func versions(t *testing.T) (int, int) {
+	cloverVersion, err := SchemaVersion(NewCloverSettings(DB))
+	if err != nil {
+		t.Fatalf("Failed to get clover schema version: %v", err)
+	}
+	sqliteVersion, err := SchemaVersion(NewSQLiteSettings(SQL))
+	if err != nil {
+		t.Fatalf("Failed to get sqlite schema version: %v", err)
+	}
+	return cloverVersion, sqliteVersion
+}
+
+func backups(t *testing.T, dir string) []string {
+	entries, _ := os.ReadDir(filepath.Join(dir, BackupDir))
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestMigrationsOrdered(t *testing.T) {
+	for i, m := range migrations {
+		if m.Version != i+1 || m.Name == "" {
+			t.Errorf("Expected migration %d to have version %d and a name, got %d %q", i, i+1, m.Version, m.Name)
+		}
+	}
+}
+
+func TestNewDatabaseIsLatest(t *testing.T) {
+	dir := openTestDB(t)
+	if c, s := versions(t); c != LatestVersion() || s != LatestVersion() {
+		t.Errorf("Expected new databases at version %d, got %d %d", LatestVersion(), c, s)
+	}
+	if err := migrate(dir, migrations); err != nil {
+		t.Fatalf("migrate failed: %v", err)
+	}
+	if names := backups(t, dir); len(names) != 0 {
+		t.Errorf("Expected no backup without pending migrations, got %v", names)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	dir := openTestDB(t)
+	setSchemaVersion(NewCloverSettings(DB), 0)
+	setSchemaVersion(NewSQLiteSettings(SQL), 0)
+
+	var applied []int
+	list := []Migration{
+		{Version: 1, Name: "clover", Clover: func(db *clover.DB) error {
+			applied = append(applied, 1)
+			return nil
+		}},
+		{Version: 2, Name: "sqlite", SQLite: func(tx *sql.Tx) error {
+			applied = append(applied, 2)
+			_, err := tx.Exec(`ALTER TABLE sessions ADD COLUMN pinned INTEGER NOT NULL DEFAULT 0`)
+			return err
+		}},
+	}
+	if err := migrate(dir, list); err != nil {
+		t.Fatalf("migrate failed: %v", err)
+	}
+	if len(applied) != 2 || applied[0] != 1 || applied[1] != 2 {
+		t.Errorf("Expected migrations to run in order, got %v", applied)
+	}
+	if c, s := versions(t); c != 2 || s != 2 {
+		t.Errorf("Expected version 2, got %d %d", c, s)
+	}
+	if _, err := SQL.Exec(`UPDATE sessions SET pinned = 1`); err != nil {
+		t.Errorf("Expected the sqlite migration to be applied: %v", err)
+	}
+
+	names := backups(t, dir)
+	if len(names) != 1 || !strings.HasSuffix(names[0], "-v0") {
+		t.Fatalf("Expected one backup of version 0, got %v", names)
+	}
+	for _, name := range []string{filepath.Join(CloverDir, Message+".json"), SQLiteFile} {
+		if _, err := os.Stat(filepath.Join(dir, BackupDir, names[0], name)); err != nil {
+			t.Errorf("Expected %s in the backup: %v", name, err)
+		}
+	}
+
+	// 已执行的迁移不再执行
+	applied = nil
+	if err := migrate(dir, list); err != nil || len(applied) != 0 {
+		t.Errorf("Expected nothing to run, got %v %v", applied, err)
+	}
+}
+
+func TestMigrateFailure(t *testing.T) {
+	dir := openTestDB(t)
+	latest := LatestVersion()
+	list := append(append([]Migration{}, migrations...),
+		Migration{Version: latest + 1, Name: "add_column", SQLite: func(tx *sql.Tx) error {
+			_, err := tx.Exec(`ALTER TABLE sessions ADD COLUMN pinned INTEGER`)
+			return err
+		}},
+		Migration{Version: latest + 2, Name: "broken", SQLite: func(tx *sql.Tx) error {
+			return errors.New("boom")
+		}},
+	)
+
+	err := migrate(dir, list)
+	if err == nil || !strings.Contains(err.Error(), "broken") || !strings.Contains(err.Error(), BackupDir) {
+		t.Fatalf("Expected an error naming the migration and the backup, got %v", err)
+	}
+	// 失败的迁移及其版本记录一起回滚,之前的迁移保留
+	if _, s := versions(t); s != latest+1 {
+		t.Errorf("Expected sqlite version %d, got %d", latest+1, s)
+	}
+
+	// 数据来自更新的版本时拒绝打开
+	if err := migrate(dir, migrations); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("Expected an error for a newer schema, got %v", err)
+	}
+}
+
+func TestPruneBackups(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"1", "2", "3", "4", "5", "6", "7"} {
+		os.Mkdir(filepath.Join(root, name), 0755)
+	}
+	pruneBackups(root)
+	entries, _ := os.ReadDir(root)
+	if len(entries) != keepBackups || entries[0].Name() != "3" {
+		t.Errorf("Expected the %d newest backups to be kept, got %v", keepBackups, entries)
+	}
+}
+
+func TestNumberLegacyMessages(t *testing.T) {
+	openTestDB(t)
+	insert := func(id, session string, seq, created int64, parent, typ string) {
+		doc := clover.NewDocument()
+		doc.SetAll(map[string]any{"id": id, "session": session, "seq": seq, "created_time": created, "parent_id": parent, "type": typ})
+		doc.Set("_id", id)
+		if _, err := DB.InsertOne(Message, doc); err != nil {
+			t.Fatalf("Failed to insert message: %v", err)
+		}
+	}
+	const (
+		s1 = "00000000-0000-0000-0000-0000000000a1"
+		s2 = "00000000-0000-0000-0000-0000000000a2"
+	)
+	// s1 有旧消息、摘要和升级后追加的消息,s2 只有新消息
+	insert("00000000-0000-0000-0000-000000000002", s1, 0, 2, "", "")
+	insert("00000000-0000-0000-0000-000000000001", s1, 0, 1, "", "")
+	insert("00000000-0000-0000-0000-000000000003", s1, 0, 3, "", "summary")
+	insert("00000000-0000-0000-0000-000000000004", s1, 1, 4, "00000000-0000-0000-0000-000000000002", "")
+	insert("00000000-0000-0000-0000-000000000005", s2, 7, 1, "", "")
+
+	if err := numberLegacyMessages(DB); err != nil {
+		t.Fatalf("numberLegacyMessages failed: %v", err)
+	}
+	want := map[string]legacyMessage{
+		"00000000-0000-0000-0000-000000000001": {Seq: 1},
+		"00000000-0000-0000-0000-000000000002": {Seq: 2, ParentId: "00000000-0000-0000-0000-000000000001"},
+		"00000000-0000-0000-0000-000000000003": {Seq: 3},
+		"00000000-0000-0000-0000-000000000004": {Seq: 4, ParentId: "00000000-0000-0000-0000-000000000002"},
+		"00000000-0000-0000-0000-000000000005": {Seq: 7},
+	}
+	for id, w := range want {
+		doc, _ := DB.Query(Message).FindById(id)
+		var m legacyMessage
+		Unmarshal(doc, &m)
+		if m.Seq != w.Seq || m.ParentId != w.ParentId {
+			t.Errorf("Unexpected message %s: seq %d parent %q", id, m.Seq, m.ParentId)
+		}
+	}
+}
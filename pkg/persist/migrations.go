@@ -0,0 +1,126 @@
+package persist
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/ostafen/clover"
+)
+
+// migrations 按版本排列的全部迁移,修改已保存数据的结构时在末尾追加,已发布的迁移不能修改
+var migrations = []Migration{
+	{Version: 1, Name: "number_legacy_messages", Clover: numberLegacyMessages},
+	{Version: 2, Name: "seal_knowledge", Clover: sealKnowledge},
+}
+
+// legacyMessage 迁移消息序号用到的字段
+type legacyMessage struct {
+	Id          string `json:"id"`
+	Session     string `json:"session"`
+	Seq         int64  `json:"seq"`
+	CreatedTime int64  `json:"created_time"`
+	ParentId    string `json:"parent_id"`
+	Type        string `json:"type"`
+}
+
+// numberLegacyMessages 旧版本保存的消息没有序号和父消息。
+// 按创建时间为有旧消息的会话重新编号,并将旧消息按顺序连接为一条分支,摘要消息不参与连接
+func numberLegacyMessages(db *clover.DB) error {
+	sessions := make(map[string][]*legacyMessage)
+	legacy := make(map[string]bool)
+	var parseErr error
+	err := db.Query(Message).ForEach(func(doc *clover.Document) bool {
+		var m legacyMessage
+		if parseErr = Unmarshal(doc, &m); parseErr != nil {
+			return false
+		}
+		m.Id = doc.ObjectId()
+		sessions[m.Session] = append(sessions[m.Session], &m)
+		if m.Seq == 0 {
+			legacy[m.Session] = true
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if parseErr != nil {
+		return parseErr
+	}
+
+	for session := range legacy {
+		messages := sessions[session]
+		sort.SliceStable(messages, func(i, j int) bool {
+			a, b := messages[i], messages[j]
+			if a.CreatedTime != b.CreatedTime {
+				return a.CreatedTime < b.CreatedTime
+			}
+			return a.Seq < b.Seq
+		})
+
+		var previous string
+		for i, m := range messages {
+			fields := map[string]any{"seq": int64(i + 1)}
+			if m.Seq == 0 && m.Type != "summary" {
+				if m.ParentId == "" {
+					fields["parent_id"] = previous
+				}
+				previous = m.Id
+			}
+			if err := db.Query(Message).UpdateById(m.Id, fields); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sealKnowledge 旧版本以明文保存知识库的文件和片段,已启用加密时按 sealedCollections 加密,没有启用加密时不做处理
+func sealKnowledge(db *clover.DB) error {
+	if !Encrypted() {
+		return nil
+	}
+	for _, collection := range []string{KnowledgeDocument, KnowledgeChunk} {
+		if err := sealPlainDocuments(db, collection); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sealPlainDocuments 加密集合中的明文文档,已加密的文档跳过
+func sealPlainDocuments(db *clover.DB, collection string) error {
+	var ids []string
+	err := db.Query(collection).ForEach(func(doc *clover.Document) bool {
+		if !doc.Has(sealedField) {
+			ids = append(ids, doc.ObjectId())
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		doc, err := db.Query(collection).FindById(id)
+		if err != nil {
+			return err
+		}
+		if doc == nil {
+			continue
+		}
+		plain, err := documentJSON(doc, Unseal)
+		if err != nil {
+			return err
+		}
+		out, err := NewSealedDocument(collection, json.RawMessage(plain))
+		if err != nil {
+			return err
+		}
+		out.Set("_id", id)
+		if err := db.Query(collection).ReplaceById(id, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
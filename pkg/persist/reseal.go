@@ -0,0 +1,143 @@
+package persist
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ostafen/clover"
+)
+
+// resealBatch 重新加密 SQLite 数据时每次读取的行数
+const resealBatch = 100
+
+// sqliteSealedTables SQLite 中 data 列需要加密的表,messages 的 content 列加密时清空
+var sqliteSealedTables = []string{"sessions", "messages", "attachments", "checkpoints"}
+
+// reseal 用 to 重新加密 clover 和 tx 中全部需要加密的数据,to 为 nil 时解密为明文。
+// 读取时依次尝试当前和旧的数据密钥,调用时需要持有 vault 的写锁
+func reseal(tx *sql.Tx, to *dataKey) error {
+	for collection, fields := range sealedCollections {
+		if err := resealCollection(collection, fields, to); err != nil {
+			return fmt.Errorf("failed to reseal collection %s: %w", collection, err)
+		}
+	}
+	if tx == nil {
+		return nil
+	}
+	for _, table := range sqliteSealedTables {
+		if err := resealTable(tx, table, to); err != nil {
+			return fmt.Errorf("failed to reseal table %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// resealBytes 解密 data 后用 to 加密,返回明文和重新加密后的数据
+func resealBytes(data []byte, to *dataKey) (plain []byte, sealed []byte, err error) {
+	plain = data
+	if IsSealed(data) {
+		if plain, err = openSealed(data); err != nil {
+			return nil, nil, err
+		}
+	}
+	if to == nil {
+		return plain, plain, nil
+	}
+	return plain, to.seal(plain), nil
+}
+
+func resealCollection(collection string, fields []string, to *dataKey) error {
+	var ids []string
+	err := DB.Query(collection).ForEach(func(doc *clover.Document) bool {
+		ids = append(ids, doc.ObjectId())
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		doc, err := DB.Query(collection).FindById(id)
+		if err != nil {
+			return err
+		}
+		if doc == nil {
+			continue
+		}
+		plain, err := documentJSON(doc, openSealed)
+		if err != nil {
+			return fmt.Errorf("document %s: %w", id, err)
+		}
+		var out *clover.Document
+		if to == nil {
+			out, err = plainDocument(plain)
+		} else {
+			out, err = sealedDocument(plain, to.seal(plain), fields)
+		}
+		if err != nil {
+			return err
+		}
+		out.Set("_id", id)
+		if err := DB.Query(collection).ReplaceById(id, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resealTable 按 rowid 分批重新加密表的 data 列,messages 表同时更新用于搜索的 content 列
+func resealTable(tx *sql.Tx, table string, to *dataKey) error {
+	type row struct {
+		id   int64
+		data []byte
+	}
+	var last int64
+	for {
+		rows, err := tx.Query(`SELECT rowid, data FROM `+table+` WHERE rowid > ? ORDER BY rowid LIMIT ?`, last, resealBatch)
+		if err != nil {
+			return err
+		}
+		batch := make([]row, 0, resealBatch)
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.id, &r.data); err != nil {
+				rows.Close()
+				return err
+			}
+			batch = append(batch, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, r := range batch {
+			plain, sealed, err := resealBytes(r.data, to)
+			if err != nil {
+				return fmt.Errorf("row %d: %w", r.id, err)
+			}
+			if table != "messages" {
+				if _, err := tx.Exec(`UPDATE `+table+` SET data = ? WHERE rowid = ?`, sealed, r.id); err != nil {
+					return err
+				}
+				continue
+			}
+			var message struct {
+				Content string `json:"content"`
+			}
+			if to == nil {
+				if err := json.Unmarshal(plain, &message); err != nil {
+					return fmt.Errorf("row %d: %w", r.id, err)
+				}
+			}
+			if _, err := tx.Exec(`UPDATE messages SET data = ?, content = ? WHERE rowid = ?`, sealed, message.Content, r.id); err != nil {
+				return err
+			}
+		}
+		if len(batch) < resealBatch {
+			return nil
+		}
+		last = batch[len(batch)-1].id
+	}
+}
@@ -0,0 +1,121 @@
+package persist
+
+import (
+	"sort"
+
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/google/uuid"
+	"github.com/ostafen/clover"
+)
+
+// GatewaySecret OpenAI 兼容接口的 API Key 在密钥存储中的名称
+const GatewaySecret = "gateway"
+
+// ProviderSecret 返回供应商的 API Key 在密钥存储中的名称
+func ProviderSecret(provider string) string {
+	return "provider:" + provider
+}
+
+// secret 密钥存储中的文档,启用加密时只有名称是明文
+type secret struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// secretDocId clover 要求文档 ID 为 uuid,由名称生成确定的 ID
+func secretDocId(name string) string {
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte("secret:"+name)).String()
+}
+
+// GetSecret 返回密钥存储中的值,不存在或数据库没有打开时 ok 为 false,没有解锁时返回 ErrLocked
+func GetSecret(name string) (string, bool, error) {
+	if DB == nil {
+		return "", false, nil
+	}
+	doc, err := DB.Query(Secret).FindById(secretDocId(name))
+	if err != nil || doc == nil {
+		return "", false, err
+	}
+	var s secret
+	if err := Unmarshal(doc, &s); err != nil {
+		return "", false, err
+	}
+	return s.Value, true, nil
+}
+
+// SetSecret 保存密钥,value 为空时删除
+func SetSecret(name string, value string) error {
+	id := secretDocId(name)
+	if value == "" {
+		return DB.Query(Secret).DeleteById(id)
+	}
+	doc, err := NewSealedDocument(Secret, &secret{Name: name, Value: value})
+	if err != nil {
+		return err
+	}
+	doc.Set("_id", id)
+
+	old, err := DB.Query(Secret).FindById(id)
+	if err != nil {
+		return err
+	}
+	if old == nil {
+		_, err = DB.InsertOne(Secret, doc)
+		return err
+	}
+	return DB.Query(Secret).ReplaceById(id, doc)
+}
+
+// SecretNames 返回密钥存储中全部密钥的名称
+func SecretNames() ([]string, error) {
+	names := make([]string, 0)
+	err := DB.Query(Secret).ForEach(func(doc *clover.Document) bool {
+		if name, ok := doc.Get("name").(string); ok {
+			names = append(names, name)
+		}
+		return true
+	})
+	sort.Strings(names)
+	return names, err
+}
+
+// MoveSecrets 将配置文件中的供应商和 OpenAI 兼容接口的 API Key 移到密钥存储,并从配置文件中删除,
+// 返回移动的数量。需要在存储解锁后调用
+func MoveSecrets(c *config.Config) (int, error) {
+	moved := make(map[string]string)
+	models := c.GetModels()
+	for _, p := range models.Providers {
+		if p.APIKey == "" {
+			continue
+		}
+		if err := SetSecret(ProviderSecret(p.Name), p.APIKey); err != nil {
+			return 0, err
+		}
+		moved[p.Name] = p.APIKey
+	}
+	gateway := c.GetGateway()
+	if gateway.APIKey != "" {
+		if err := SetSecret(GatewaySecret, gateway.APIKey); err != nil {
+			return 0, err
+		}
+	}
+	if len(moved) == 0 && gateway.APIKey == "" {
+		return 0, nil
+	}
+
+	// 只删除已经保存的值,期间配置文件中修改过的 API Key 留到下次移动
+	n := 0
+	err := c.Update(func(c *config.Config) {
+		for i, p := range c.Models.Providers {
+			if key, ok := moved[p.Name]; ok && p.APIKey == key {
+				c.Models.Providers[i].APIKey = ""
+				n++
+			}
+		}
+		if gateway.APIKey != "" && c.Gateway.APIKey == gateway.APIKey {
+			c.Gateway.APIKey = ""
+			n++
+		}
+	})
+	return n, err
+}
@@ -0,0 +1,100 @@
+package persist
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/ostafen/clover"
+)
+
+// ErrNotFound 要更新的记录不存在
+var ErrNotFound = errors.New("record not found")
+
+// Settings 键值形式的设置,用于保存迁移状态等不属于配置文件的数据
+type Settings interface {
+	// Get 返回设置的值,不存在时 ok 为 false
+	Get(key string) (value string, ok bool, err error)
+	Set(key string, value string) error
+}
+
+// setting clover 中的设置文档
+type setting struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// NewCloverSettings 创建保存在 clover setting 集合中的设置
+func NewCloverSettings(db *clover.DB) Settings {
+	return &cloverSettings{db: db}
+}
+
+type cloverSettings struct {
+	db *clover.DB
+}
+
+// settingDocId clover 要求文档 ID 为 uuid,由键生成确定的 ID
+func settingDocId(key string) string {
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte("setting:"+key)).String()
+}
+
+func (s *cloverSettings) Get(key string) (string, bool, error) {
+	doc, err := s.db.Query(Setting).FindById(settingDocId(key))
+	if err != nil || doc == nil {
+		return "", false, err
+	}
+	var v setting
+	if err := Unmarshal(doc, &v); err != nil {
+		return "", false, err
+	}
+	return v.Value, true, nil
+}
+
+func (s *cloverSettings) Set(key string, value string) error {
+	doc, err := NewDocument(&setting{Key: key, Value: value})
+	if err != nil {
+		return err
+	}
+	id := settingDocId(key)
+	doc.Set("_id", id)
+
+	old, err := s.db.Query(Setting).FindById(id)
+	if err != nil {
+		return err
+	}
+	if old == nil {
+		_, err = s.db.InsertOne(Setting, doc)
+		return err
+	}
+	return s.db.Query(Setting).ReplaceById(id, doc)
+}
+
+// upsertSetting 以 key、value 为参数保存设置
+const upsertSetting = `INSERT INTO settings (key, value) VALUES (?, ?)
+	ON CONFLICT (key) DO UPDATE SET value = excluded.value`
+
+// NewSQLiteSettings 创建保存在 SQLite settings 表中的设置
+func NewSQLiteSettings(db *sql.DB) Settings {
+	return &sqliteSettings{db: db}
+}
+
+type sqliteSettings struct {
+	db *sql.DB
+}
+
+func (s *sqliteSettings) Get(key string) (string, bool, error) {
+	var value string
+	err := s.db.QueryRow(`SELECT value FROM settings WHERE key = ?`, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+func (s *sqliteSettings) Set(key string, value string) error {
+	_, err := s.db.Exec(upsertSetting, key, value)
+	return err
+}
@@ -0,0 +1,102 @@
+package persist
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"path/filepath"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteFile SQLite 数据库的文件名
+const SQLiteFile = "remo.db"
+
+// schema 会话、消息、附件、断点和设置的表结构,完整的对象以 json 保存在 data 列,查询用到的字段单独建列和索引。
+// 这里始终是最新的结构,已有数据库的表结构变更通过 migrations 完成
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS sessions (
+		id         TEXT PRIMARY KEY,
+		updated_at INTEGER NOT NULL DEFAULT 0,
+		deleted_at INTEGER NOT NULL DEFAULT 0,
+		data       TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_sessions_deleted_at ON sessions (deleted_at)`,
+	`CREATE TABLE IF NOT EXISTS messages (
+		id           TEXT PRIMARY KEY,
+		session      TEXT NOT NULL,
+		seq          INTEGER NOT NULL DEFAULT 0,
+		created_time INTEGER NOT NULL DEFAULT 0,
+		content      TEXT NOT NULL DEFAULT '',
+		data         TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_messages_session ON messages (session, seq)`,
+	`CREATE TABLE IF NOT EXISTS attachments (
+		id        TEXT PRIMARY KEY,
+		session   TEXT NOT NULL,
+		message   TEXT NOT NULL,
+		kind      TEXT NOT NULL,
+		name      TEXT NOT NULL,
+		mime_type TEXT NOT NULL,
+		data      BLOB NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_attachments_session ON attachments (session)`,
+	`CREATE TABLE IF NOT EXISTS checkpoints (
+		session       TEXT NOT NULL,
+		checkpoint_id TEXT NOT NULL,
+		data          BLOB NOT NULL,
+		updated_time  INTEGER NOT NULL,
+		PRIMARY KEY (session, checkpoint_id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_checkpoints_updated_time ON checkpoints (updated_time)`,
+	`CREATE TABLE IF NOT EXISTS settings (
+		key   TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	)`,
+}
+
+// OpenSQLite 打开目录中的 SQLite 数据库并创建缺失的表,新建的数据库记录为最新的迁移版本。
+// 使用 WAL 模式,写事务开始时即获取写锁,避免并发写入时事务中途升级锁失败
+func OpenSQLite(dir string) (*sql.DB, error) {
+	dsn := (&url.URL{
+		Scheme:   "file",
+		Path:     filepath.ToSlash(filepath.Join(dir, SQLiteFile)),
+		RawQuery: "_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_txlock=immediate",
+	}).String()
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	var tables int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'settings'`).Scan(&tables); err != nil {
+		db.Close()
+		return nil, err
+	}
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to create sqlite schema: %w", err)
+		}
+	}
+	if tables == 0 {
+		if err := setSchemaVersion(NewSQLiteSettings(db), LatestVersion()); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// Tx 在事务中执行 f,f 返回错误时回滚
+func Tx(db *sql.DB, f func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,128 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/cloudwego/eino-ext/libs/acl/openai"
+	"github.com/cloudwego/eino/components/embedding"
+)
+
+// EmbeddingModel 注册表中的一个向量模型
+type EmbeddingModel struct {
+	Name     string                      `json:"name"` // 完整名称 provider/model
+	Provider config.ProviderConfig       `json:"-"`
+	Define   config.EmbeddingModelDefine `json:"define"`
+}
+
+// ListEmbeddings 返回所有可用的向量模型
+func (r *Registry) ListEmbeddings() []*EmbeddingModel {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	output := make([]*EmbeddingModel, len(r.embeddings))
+	copy(output, r.embeddings)
+	return output
+}
+
+// ResolveEmbedding 解析向量模型名称,为空时使用默认向量模型,未配置默认值时使用第一个可用的向量模型
+func (r *Registry) ResolveEmbedding(name string) (*EmbeddingModel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if name == "" {
+		name = r.defEmbedding
+	}
+	if name == "" {
+		if len(r.embeddings) == 0 {
+			return nil, fmt.Errorf("no embedding model available")
+		}
+		return r.embeddings[0], nil
+	}
+	if m, ok := r.embeddingByName[name]; ok {
+		return m, nil
+	}
+	if m, ok := r.embeddingByModel[name]; ok {
+		return m, nil
+	}
+	return nil, fmt.Errorf("embedding model not found: %s", name)
+}
+
+// NewEmbedder 根据向量模型名称创建 Embedder
+func (r *Registry) NewEmbedder(ctx context.Context, name string) (embedding.Embedder, *EmbeddingModel, error) {
+	m, err := r.ResolveEmbedding(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch m.Provider.Provider {
+	case config.Qwen, config.OpenAI, config.Ollama:
+	default:
+		return nil, nil, fmt.Errorf("provider %s does not support embeddings", m.Provider.Name)
+	}
+
+	baseURL, err := BaseURL(m.Provider)
+	if err != nil {
+		return nil, nil, err
+	}
+	apiKey, err := APIKey(m.Provider)
+	if err != nil {
+		return nil, nil, err
+	}
+	if apiKey == "" {
+		if m.Provider.Provider != config.Ollama {
+			return nil, nil, fmt.Errorf("api key of provider %s is not configured", m.Provider.Name)
+		}
+		apiKey = "ollama"
+	}
+
+	cfg := &openai.EmbeddingConfig{
+		// 客户端内部将 HTTPClient 作为接口保存,为空时不会使用默认值
+		HTTPClient: http.DefaultClient,
+		APIKey:     apiKey,
+		BaseURL:    baseURL,
+		Model:      m.Define.Model,
+	}
+	if m.Define.Dimensions > 0 {
+		dimensions := m.Define.Dimensions
+		cfg.Dimensions = &dimensions
+	}
+	e, err := openai.NewEmbeddingClient(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return e, m, nil
+}
+
+// EmbedderFunc 返回向量模型及其名称,知识库和长期记忆通过它获取向量模型,测试时可以替换
+type EmbedderFunc func(ctx context.Context) (embedding.Embedder, string, error)
+
+// RegistryEmbedder 从全局注册表创建向量模型,name 为空时使用默认向量模型
+func RegistryEmbedder(name string) EmbedderFunc {
+	return func(ctx context.Context) (embedding.Embedder, string, error) {
+		e, m, err := Get().NewEmbedder(ctx, name)
+		if err != nil {
+			return nil, "", err
+		}
+		return e, m.Name, nil
+	}
+}
+
+// Cosine 计算两个向量的余弦相似度,长度不同或为零向量时返回 0
+func Cosine(a, b []float64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
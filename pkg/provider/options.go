@@ -0,0 +1,40 @@
+package provider
+
+import (
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/cloudwego/eino-ext/components/model/openai"
+	"github.com/cloudwego/eino/components/model"
+)
+
+// GenerateOptions 生成参数,为空的字段使用模型默认值
+type GenerateOptions struct {
+	Temperature     *float32 `json:"temperature,omitempty"`
+	TopP            *float32 `json:"top_p,omitempty"`
+	MaxTokens       *int     `json:"max_tokens,omitempty"`
+	EnableReasoning *bool    `json:"enable_reasoning,omitempty"` // 是否开启思考,仅对支持思考的模型生效
+}
+
+// ModelOptions 将生成参数转换为该模型的调用选项
+func (m *Model) ModelOptions(o GenerateOptions) []model.Option {
+	opts := make([]model.Option, 0)
+	if o.Temperature != nil {
+		opts = append(opts, model.WithTemperature(*o.Temperature))
+	}
+	if o.TopP != nil {
+		opts = append(opts, model.WithTopP(*o.TopP))
+	}
+	if o.MaxTokens != nil && *o.MaxTokens > 0 {
+		opts = append(opts, model.WithMaxTokens(*o.MaxTokens))
+	}
+
+	if o.EnableReasoning != nil && m.Define.SupportThinking {
+		// DeepSeek 由模型本身决定是否思考(deepseek-chat / deepseek-reasoner),不支持开关
+		switch m.Provider.Provider {
+		case config.Qwen:
+			opts = append(opts, openai.WithExtraFields(map[string]any{"enable_thinking": *o.EnableReasoning}))
+		case config.Ollama:
+			opts = append(opts, openai.WithExtraFields(map[string]any{"think": *o.EnableReasoning}))
+		}
+	}
+	return opts
+}
@@ -0,0 +1,225 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/cloudwego/eino-ext/components/model/deepseek"
+	"github.com/cloudwego/eino-ext/components/model/openai"
+	"github.com/cloudwego/eino/components/model"
+)
+
+// Model 注册表中的一个可用模型
+type Model struct {
+	Name     string                 `json:"name"` // 完整名称 provider/model
+	Provider config.ProviderConfig  `json:"-"`
+	Define   config.ChatModelDefine `json:"define"`
+}
+
+// Registry 模型注册表,根据 config.ModelConfig 解析模型名称并创建对应的 ChatModel
+type Registry struct {
+	mu      sync.RWMutex
+	def     string
+	models  []*Model
+	byName  map[string]*Model // provider/model -> Model
+	byModel map[string]*Model // model -> Model,同名模型以先出现的供应商为准
+
+	defEmbedding     string
+	embeddings       []*EmbeddingModel
+	embeddingByName  map[string]*EmbeddingModel
+	embeddingByModel map[string]*EmbeddingModel
+}
+
+var (
+	globalRegistry *Registry
+	registryMu     sync.Mutex
+)
+
+// NewRegistry 根据模型配置创建注册表,未启用的供应商会被忽略
+func NewRegistry(cfg config.ModelConfig) *Registry {
+	r := &Registry{
+		def:              cfg.Default,
+		models:           make([]*Model, 0),
+		byName:           make(map[string]*Model),
+		byModel:          make(map[string]*Model),
+		defEmbedding:     cfg.DefaultEmbedding,
+		embeddings:       make([]*EmbeddingModel, 0),
+		embeddingByName:  make(map[string]*EmbeddingModel),
+		embeddingByModel: make(map[string]*EmbeddingModel),
+	}
+
+	for _, p := range cfg.Providers {
+		if !p.Enabled {
+			continue
+		}
+		for _, d := range p.Models {
+			d.Provider = p.Provider
+			m := &Model{
+				Name:     p.Name + "/" + d.Model,
+				Provider: p,
+				Define:   d,
+			}
+			r.models = append(r.models, m)
+			r.byName[m.Name] = m
+			if _, ok := r.byModel[d.Model]; !ok {
+				r.byModel[d.Model] = m
+			}
+		}
+		for _, d := range p.Embeddings {
+			m := &EmbeddingModel{
+				Name:     p.Name + "/" + d.Model,
+				Provider: p,
+				Define:   d,
+			}
+			r.embeddings = append(r.embeddings, m)
+			r.embeddingByName[m.Name] = m
+			if _, ok := r.embeddingByModel[d.Model]; !ok {
+				r.embeddingByModel[d.Model] = m
+			}
+		}
+	}
+	return r
+}
+
+// Get 获取全局注册表,首次调用时根据全局配置创建
+func Get() *Registry {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if globalRegistry == nil {
+		globalRegistry = NewRegistry(config.Get().GetModels())
+	}
+	return globalRegistry
+}
+
+// Reload 配置变更后重新创建全局注册表
+func Reload() {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	globalRegistry = NewRegistry(config.Get().GetModels())
+}
+
+// List 返回所有可用模型
+func (r *Registry) List() []*Model {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	output := make([]*Model, len(r.models))
+	copy(output, r.models)
+	return output
+}
+
+// Resolve 解析模型名称,支持 "model" 和 "provider/model" 两种形式,为空时使用默认模型
+func (r *Registry) Resolve(name string) (*Model, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if name == "" {
+		name = r.def
+	}
+	if name == "" {
+		return nil, fmt.Errorf("no model specified and no default model configured")
+	}
+	if m, ok := r.byName[name]; ok {
+		return m, nil
+	}
+	if m, ok := r.byModel[name]; ok {
+		return m, nil
+	}
+	return nil, fmt.Errorf("model not found: %s", name)
+}
+
+// NewChatModel 根据模型名称创建 ChatModel
+func (r *Registry) NewChatModel(ctx context.Context, name string) (model.ToolCallingChatModel, *Model, error) {
+	m, err := r.Resolve(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	baseURL, err := BaseURL(m.Provider)
+	if err != nil {
+		return nil, nil, err
+	}
+	apiKey, err := APIKey(m.Provider)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch m.Provider.Provider {
+	case config.DeepSeek:
+		if apiKey == "" {
+			return nil, nil, fmt.Errorf("api key of provider %s is not configured", m.Provider.Name)
+		}
+		cm, err := deepseek.NewChatModel(ctx, &deepseek.ChatModelConfig{
+			APIKey:  apiKey,
+			Model:   m.Define.Model,
+			BaseURL: baseURL,
+		})
+		return cm, m, err
+
+	case config.Qwen, config.OpenAI, config.Ollama:
+		if apiKey == "" {
+			if m.Provider.Provider != config.Ollama {
+				return nil, nil, fmt.Errorf("api key of provider %s is not configured", m.Provider.Name)
+			}
+			// Ollama 的兼容接口不校验 API Key,但客户端要求非空
+			apiKey = "ollama"
+		}
+		cm, err := openai.NewChatModel(ctx, &openai.ChatModelConfig{
+			APIKey:  apiKey,
+			Model:   m.Define.Model,
+			BaseURL: baseURL,
+		})
+		return cm, m, err
+	}
+
+	return nil, nil, fmt.Errorf("unknown provider: %s", m.Provider.Provider)
+}
+
+// BaseURL 获取供应商的接口地址,配置中未填写时使用默认地址
+func BaseURL(p config.ProviderConfig) (string, error) {
+	if p.BaseURL != "" {
+		return p.BaseURL, nil
+	}
+	return config.GetDefaultEndpoint(p.Provider)
+}
+
+// SecretLookup 按供应商名称从密钥存储读取 API Key,ok 为 false 表示没有保存
+type SecretLookup func(provider string) (key string, ok bool, err error)
+
+var (
+	secretLookup SecretLookup
+	secretMu     sync.RWMutex
+)
+
+// UseSecrets 设置保存 API Key 的密钥存储,未设置时只从配置和环境变量读取
+func UseSecrets(lookup SecretLookup) {
+	secretMu.Lock()
+	defer secretMu.Unlock()
+	secretLookup = lookup
+}
+
+// APIKey 获取供应商的 API Key,依次从配置、密钥存储和环境变量读取,密钥存储无法读取时返回错误
+func APIKey(p config.ProviderConfig) (string, error) {
+	if p.APIKey != "" {
+		return p.APIKey, nil
+	}
+	secretMu.RLock()
+	lookup := secretLookup
+	secretMu.RUnlock()
+	if lookup != nil {
+		key, ok, err := lookup(p.Name)
+		if err != nil {
+			return "", fmt.Errorf("failed to read api key of provider %s: %w", p.Name, err)
+		}
+		if ok {
+			return key, nil
+		}
+	}
+	if p.APIKeyEnv != "" {
+		return strings.TrimSpace(os.Getenv(p.APIKeyEnv)), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,149 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/AntNoHuabei/Remo/internal/config"
+)
+
+func testModelConfig() config.ModelConfig {
+	return config.ModelConfig{
+		Default: "deepseek-chat",
+		Providers: []config.ProviderConfig{
+			{
+				Name:     "deepseek",
+				Provider: config.DeepSeek,
+				APIKey:   "sk-test",
+				Enabled:  true,
+				Models: []config.ChatModelDefine{
+					{Model: "deepseek-chat"},
+					{Model: "deepseek-reasoner", SupportThinking: true},
+				},
+				Embeddings: []config.EmbeddingModelDefine{
+					{Model: "deepseek-embedding"},
+				},
+			},
+			{
+				Name:     "local",
+				Provider: config.Ollama,
+				Enabled:  true,
+				Models: []config.ChatModelDefine{
+					{Model: "deepseek-chat"},
+					{Model: "qwen3"},
+				},
+				Embeddings: []config.EmbeddingModelDefine{
+					{Model: "nomic-embed-text", Dimensions: 768},
+				},
+			},
+			{
+				Name:     "disabled",
+				Provider: config.OpenAI,
+				Enabled:  false,
+				Models: []config.ChatModelDefine{
+					{Model: "gpt-4o"},
+				},
+			},
+		},
+	}
+}
+
+func TestRegistryResolve(t *testing.T) {
+	r := NewRegistry(testModelConfig())
+
+	if len(r.List()) != 4 {
+		t.Fatalf("Expected 4 models, got %d", len(r.List()))
+	}
+
+	m, err := r.Resolve("")
+	if err != nil {
+		t.Fatalf("Failed to resolve default model: %v", err)
+	}
+	if m.Name != "deepseek/deepseek-chat" {
+		t.Errorf("Expected default model 'deepseek/deepseek-chat', got '%s'", m.Name)
+	}
+
+	m, err = r.Resolve("local/deepseek-chat")
+	if err != nil {
+		t.Fatalf("Failed to resolve qualified model: %v", err)
+	}
+	if m.Define.Provider != config.Ollama {
+		t.Errorf("Expected provider 'ollama', got '%s'", m.Define.Provider)
+	}
+
+	if _, err := r.Resolve("gpt-4o"); err == nil {
+		t.Error("Expected error for model of disabled provider")
+	}
+}
+
+func TestRegistryNewChatModel(t *testing.T) {
+	r := NewRegistry(testModelConfig())
+
+	if _, _, err := r.NewChatModel(context.Background(), "deepseek-reasoner"); err != nil {
+		t.Fatalf("Failed to create deepseek model: %v", err)
+	}
+	if _, _, err := r.NewChatModel(context.Background(), "qwen3"); err != nil {
+		t.Fatalf("Failed to create ollama model: %v", err)
+	}
+}
+
+func TestRegistryEmbedding(t *testing.T) {
+	r := NewRegistry(testModelConfig())
+
+	if len(r.ListEmbeddings()) != 2 {
+		t.Fatalf("Expected 2 embedding models, got %d", len(r.ListEmbeddings()))
+	}
+
+	m, err := r.ResolveEmbedding("")
+	if err != nil {
+		t.Fatalf("Failed to resolve default embedding model: %v", err)
+	}
+	if m.Name != "deepseek/deepseek-embedding" {
+		t.Errorf("Expected first embedding model as default, got '%s'", m.Name)
+	}
+
+	if _, _, err := r.NewEmbedder(context.Background(), "local/nomic-embed-text"); err != nil {
+		t.Fatalf("Failed to create ollama embedder: %v", err)
+	}
+	if _, _, err := r.NewEmbedder(context.Background(), "deepseek-embedding"); err == nil {
+		t.Error("Expected error for provider without embedding support")
+	}
+	if _, err := r.ResolveEmbedding("missing"); err == nil {
+		t.Error("Expected error for unknown embedding model")
+	}
+}
+
+func TestCosine(t *testing.T) {
+	if s := Cosine([]float64{1, 0}, []float64{2, 0}); s < 0.9999 || s > 1.0001 {
+		t.Errorf("Expected 1 for parallel vectors, got %f", s)
+	}
+	if s := Cosine([]float64{1, 0}, []float64{0, 1}); s != 0 {
+		t.Errorf("Expected 0 for orthogonal vectors, got %f", s)
+	}
+	if Cosine([]float64{1}, []float64{1, 2}) != 0 || Cosine([]float64{0, 0}, []float64{1, 2}) != 0 {
+		t.Error("Expected 0 for mismatched or zero vectors")
+	}
+}
+
+func TestAPIKeyFromSecrets(t *testing.T) {
+	t.Cleanup(func() { UseSecrets(nil) })
+	p := config.ProviderConfig{Name: "deepseek", APIKeyEnv: "REMO_TEST_API_KEY"}
+	t.Setenv("REMO_TEST_API_KEY", "sk-env")
+
+	if key, err := APIKey(p); err != nil || key != "sk-env" {
+		t.Errorf("Expected the key from the environment, got %q %v", key, err)
+	}
+	UseSecrets(func(name string) (string, bool, error) {
+		return "sk-" + name, true, nil
+	})
+	if key, err := APIKey(p); err != nil || key != "sk-deepseek" {
+		t.Errorf("Expected the key from the secret store, got %q %v", key, err)
+	}
+	UseSecrets(func(name string) (string, bool, error) {
+		return "", false, errors.New("storage is locked")
+	})
+	if _, err := APIKey(p); err == nil {
+		t.Error("Expected an error when the secret store cannot be read")
+	}
+}
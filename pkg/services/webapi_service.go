@@ -2,16 +2,35 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/AntNoHuabei/Remo/internal/log"
 	"github.com/AntNoHuabei/Remo/pkg/api"
+	"github.com/AntNoHuabei/Remo/pkg/chat"
+	"github.com/AntNoHuabei/Remo/pkg/knowledge"
+	"github.com/AntNoHuabei/Remo/pkg/mcp"
+	"github.com/AntNoHuabei/Remo/pkg/memory"
 	"github.com/AntNoHuabei/Remo/pkg/persist"
+	"github.com/AntNoHuabei/Remo/pkg/provider"
+	"github.com/AntNoHuabei/Remo/pkg/tools"
+	"github.com/AntNoHuabei/Remo/pkg/usage"
+	"github.com/cloudwego/eino/components/tool"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/wailsapp/wails/v3/pkg/application"
 	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
 	"time"
 )
 
+// EventSessionTitle 会话标题变更时发送给前端的事件,数据为 {id, title}
+const EventSessionTitle = "session:title"
+
 // GinService implements a Wails service that uses Gin for HTTP handling
 type GinService struct {
 	ginEngine   *gin.Engine
@@ -19,18 +38,98 @@ type GinService struct {
 	netListener net.Listener
 }
 
-// NewGinService creates a new GinService instance
-func NewGinService() *GinService {
+// NewGinService creates a new GinService instance.
+// 配置、日志和数据库都保存在 dataDir 中,调用前需要先锁定 dataDir
+func NewGinService(dataDir string) *GinService {
 
-	persist.InitDB()
+	// 旧版本的配置和数据库保存在工作目录中,第一次使用数据目录时复制过来
+	workDir, _ := os.Getwd()
+	adopted, err := config.AdoptLegacyData(dataDir, workDir)
+	if err != nil {
+		panic(err)
+	}
+	if _, err := config.Init(config.ResolvePath(dataDir, config.ConfigFile)); err != nil {
+		panic(err)
+	}
+	logCfg := config.Get().GetLog()
+	if err := log.Init(&log.Config{
+		Level:      logCfg.Level,
+		OutputFile: config.ResolvePath(dataDir, logCfg.OutputFile),
+		MaxSize:    logCfg.MaxSize,
+		MaxBackups: logCfg.MaxBackups,
+		MaxAge:     logCfg.MaxAge,
+		Compress:   logCfg.Compress,
+	}); err != nil {
+		panic(err)
+	}
+	log.Info("using data directory", "path", dataDir)
+	if len(adopted) > 0 {
+		log.Info("copied legacy data into the data directory", "from", workDir, "files", adopted)
+	}
+
+	// 打开数据库并执行数据迁移,迁移失败时不启动,存储加密且锁定时迁移在解锁时执行;使用 SQLite 时第一次启动复制 clover 中的会话
+	storage := config.Get().GetStorage()
+	storage.Dir = config.ResolvePath(dataDir, storage.Dir)
+	if err := persist.InitDB(storage); err != nil {
+		panic(err)
+	}
+	if err := chat.InitRepository(storage); err != nil {
+		panic(err)
+	}
+	// 供应商的 API Key 保存在密钥存储中
+	provider.UseSecrets(func(name string) (string, bool, error) {
+		return persist.GetSecret(persist.ProviderSecret(name))
+	})
+	// API Key 从配置文件移到密钥存储,存储加密时在解锁后进行
+	persist.AfterUnlock(func() {
+		if n, err := persist.MoveSecrets(config.Get()); err != nil {
+			log.Error("failed to move api keys to the secret store", "error", err)
+		} else if n > 0 {
+			log.Info("moved api keys from the config file to the secret store", "count", n)
+		}
+	})
+	// 清理过期的断点、回收站和孤儿数据
+	chat.StartMaintenance(func() time.Duration {
+		return time.Duration(config.Get().GetTrash().RetentionDays) * 24 * time.Hour
+	})
+	// 注册内置工具
+	chat.RegisterTool(tools.Builtin(config.Get().GetTools().Builtin)...)
+	// 连接 MCP 服务器,连接过程不阻塞启动
+	mcpManager := mcp.Init(config.Get().GetMCP())
+	go mcpManager.Start(context.Background())
+	chat.RegisterToolSource(func(ctx context.Context) []tool.InvokableTool {
+		return mcp.Get().Tools(ctx)
+	})
+	// 长期记忆
+	memory.Init(config.Get().GetMemory())
+	// 本地知识库
+	knowledge.Init(config.Get().GetKnowledge())
+	// 用量统计和预算
+	usage.Init(config.Get().GetUsage())
+	// 会话标题变更时通知前端
+	chat.OnTitleChanged(func(session *chat.Session) {
+		if app := application.Get(); app != nil {
+			app.Event.EmitEvent(&application.CustomEvent{
+				Name: EventSessionTitle,
+				Data: map[string]string{"id": session.Id, "title": session.Title},
+			})
+		}
+	})
 
 	// Create a new Gin router
 	ginEngine := gin.New()
 
 	// Add middlewares
 	ginEngine.Use(gin.Recovery())
-	ginEngine.Use(cors.Default())
+	// 只允许应用内的页面跨域访问 TCP 监听,本机浏览器中的其他网页无法读取响应
+	ginEngine.Use(cors.New(cors.Config{
+		AllowOriginFunc: appOrigin,
+		AllowMethods:    []string{http.MethodGet, http.MethodPost, http.MethodOptions},
+		AllowHeaders:    []string{"Origin", "Content-Length", "Content-Type", "Authorization", api.LaunchTokenHeader},
+		MaxAge:          12 * time.Hour,
+	}))
 	ginEngine.Use(LoggingMiddleware())
+	ginEngine.Use(api.StorageGuard())
 
 	service := &GinService{
 		ginEngine: ginEngine,
@@ -52,8 +151,8 @@ func (s *GinService) ServiceStartup(ctx context.Context, options application.Ser
 	// You can access the application instance via ctx
 	s.app = application.Get()
 
-	s.setupHttpServe()
-	return nil
+	// 前端通过固定端口接收流式输出,端口被占用时无法正常对话,直接启动失败
+	return s.setupHttpServe()
 }
 
 // ServiceShutdown is called when the service shuts down
@@ -61,6 +160,8 @@ func (s *GinService) ServiceShutdown() error {
 	if s.netListener != nil {
 		s.netListener.Close()
 	}
+	// 关闭 MCP 服务器进程
+	mcp.Get().Close()
 	// Clean up event handler to prevent memory leaks
 	return nil
 }
@@ -78,22 +179,119 @@ func (s *GinService) setupRoutes() {
 	sessionGroup.POST("/delete", api.SessionDelete)
 	sessionGroup.POST("/list", api.SessionList)
 	sessionGroup.POST("/messages", api.SessionMessages)
+	sessionGroup.POST("/update", api.SessionUpdate)
+	sessionGroup.POST("/rename", api.SessionRename)
+	sessionGroup.POST("/trash", api.SessionTrash)
+	sessionGroup.POST("/restore", api.SessionRestore)
+	sessionGroup.POST("/purge", api.SessionPurge)
+	sessionGroup.POST("/export", api.SessionExport)
+	sessionGroup.POST("/import", api.SessionImport)
+	messageGroup := s.ginEngine.Group("/message")
+	messageGroup.POST("/edit", api.MessageEdit)
+	messageGroup.POST("/regenerate", api.MessageRegenerate)
+	messageGroup.POST("/switch", api.MessageSwitch)
+	s.ginEngine.GET("/attachment/:id", api.AttachmentGet)
+	modelGroup := s.ginEngine.Group("/model")
+	modelGroup.POST("/list", api.ModelList)
 	s.ginEngine.POST("/chat", api.Chat)
+	s.ginEngine.POST("/chat/abort", api.ChatAbort)
+	s.ginEngine.POST("/chat/confirm", api.ChatConfirm)
+	s.ginEngine.GET("/chat/stream/:request_id", api.ChatStream)
+	mcpGroup := s.ginEngine.Group("/mcp")
+	mcpGroup.POST("/servers", api.MCPServers)
+	memoryGroup := s.ginEngine.Group("/memory")
+	memoryGroup.POST("/list", api.MemoryList)
+	memoryGroup.POST("/delete", api.MemoryDelete)
+	memoryGroup.POST("/edit", api.MemoryEdit)
+	knowledgeGroup := s.ginEngine.Group("/knowledge")
+	knowledgeGroup.POST("/upload", api.KnowledgeUpload)
+	knowledgeGroup.POST("/list", api.KnowledgeList)
+	knowledgeGroup.POST("/delete", api.KnowledgeDelete)
+	knowledgeGroup.POST("/search", api.KnowledgeSearch)
+	usageGroup := s.ginEngine.Group("/usage")
+	usageGroup.POST("/summary", api.UsageSummary)
+	usageGroup.POST("/budget", api.UsageBudget)
+	vaultGroup := s.ginEngine.Group("/vault")
+	vaultGroup.POST("/status", api.VaultStatus)
+	vaultGroup.POST("/unlock", api.VaultUnlock)
+	vaultGroup.POST("/lock", api.VaultLock)
+	vaultGroup.POST("/enable", api.VaultEnable)
+	vaultGroup.POST("/rotate", api.VaultRotate)
+	vaultGroup.POST("/disable", api.VaultDisable)
+	secretGroup := s.ginEngine.Group("/secret")
+	secretGroup.POST("/list", api.SecretList)
+	secretGroup.POST("/set", api.SecretSet)
+	// 启动令牌只通过 Wails 提供,不在 TCP 监听上
+	s.ginEngine.POST("/app/token", api.LaunchToken)
+	// OpenAI 兼容接口
+	v1Group := s.ginEngine.Group("/v1", api.GatewayAuth())
+	v1Group.POST("/chat/completions", api.OpenAIChatCompletions)
+	v1Group.GET("/models", api.OpenAIModels)
+	v1Group.POST("/embeddings", api.OpenAIEmbeddings)
 }
 
 // setupHttpServe 由于wails里面无法正常使用sse
-func (s *GinService) setupHttpServe() {
+func (s *GinService) setupHttpServe() error {
 
-	// 创建 TCP listener
-	listener, err := net.Listen("tcp", ":9980")
+	// 创建 TCP listener,只监听本机地址,OpenAI 兼容接口也通过这里提供
+	listener, err := net.Listen("tcp", "127.0.0.1:9980")
 	if err != nil {
-		s.app.Logger.Error("Error creating listener", "error", err)
-		return
+		return fmt.Errorf("failed to listen on 127.0.0.1:9980, is another instance running: %w", err)
 	}
 	s.netListener = listener
 	go func() {
-		s.ginEngine.RunListener(listener)
+		if err := http.Serve(listener, s.listenerHandler()); err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Error("http server stopped", "error", err)
+		}
 	}()
+	return nil
+}
+
+// listenerRoutes TCP 监听上提供的接口,只有流式对话和 OpenAI 兼容接口。
+// 本机的其他进程和浏览器中的网页也能访问这个端口,加密、密钥等管理接口只能在应用内通过 Wails 访问
+var listenerRoutes = []string{"/chat", "/message/edit", "/message/regenerate", "/v1"}
+
+// gatewayRoute OpenAI 兼容接口,由 GatewayAuth 校验 API Key,不需要启动令牌
+const gatewayRoute = "/v1"
+
+// listenerHandler 只将 listenerRoutes 中的请求交给 gin,其他请求返回 404。
+// 流式对话接口需要携带启动令牌,CORS 预检请求不带自定义请求头,交给 gin 处理
+func (s *GinService) listenerHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 不接受包含 . 或 .. 的路径,避免绕过前缀判断
+		if path.Clean(r.URL.Path) != r.URL.Path {
+			http.NotFound(w, r)
+			return
+		}
+		for _, route := range listenerRoutes {
+			if r.URL.Path != route && !strings.HasPrefix(r.URL.Path, route+"/") {
+				continue
+			}
+			if route != gatewayRoute && r.Method != http.MethodOptions && !api.ValidLaunchToken(r.Header.Get(api.LaunchTokenHeader)) {
+				http.Error(w, "invalid launch token", http.StatusUnauthorized)
+				return
+			}
+			s.ginEngine.ServeHTTP(w, r)
+			return
+		}
+		http.NotFound(w, r)
+	})
+}
+
+// appOrigin 判断请求是否来自应用内的页面:macOS 和 Linux 为 wails://localhost,Windows 为 http://wails.localhost,
+// 开发模式下带有端口
+func appOrigin(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "wails":
+		return u.Hostname() == "localhost"
+	case "http", "https":
+		return u.Hostname() == "wails.localhost"
+	}
+	return false
 }
 
 // LoggingMiddleware is a Gin middleware that logs request details
@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/AntNoHuabei/Remo/internal/log"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// maxOutputSize 工具返回内容的最大长度,避免占满模型上下文
+const maxOutputSize = 64 * 1024
+
+// Builtin 根据配置返回启用的内置工具
+func Builtin(cfg config.BuiltinToolsConfig) []tool.InvokableTool {
+	output := make([]tool.InvokableTool, 0)
+	if cfg.ReadFile {
+		output = append(output, newReadFileTool(cfg.AllowedDirs))
+	}
+	if cfg.ListDir {
+		output = append(output, newListDirTool(cfg.AllowedDirs))
+	}
+	// 没有可用的沙箱时不提供执行命令的工具
+	if cfg.Shell {
+		if err := checkSandbox(); err != nil {
+			log.Warn("run_shell is disabled", "error", err)
+		} else {
+			output = append(output, newShellTool(cfg.AllowedDirs, cfg.ShellTimeout))
+		}
+	}
+	if cfg.HTTPFetch {
+		output = append(output, newHTTPFetchTool(cfg.HTTPAllowlist))
+	}
+	if cfg.DateTime {
+		output = append(output, newDateTimeTool())
+	}
+	if cfg.Calculator {
+		output = append(output, newCalculatorTool())
+	}
+	return output
+}
+
+// funcTool 由工具描述和执行函数组成的工具
+type funcTool struct {
+	info *schema.ToolInfo
+	run  func(ctx context.Context, argumentsInJSON string) (string, error)
+}
+
+func (t *funcTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return t.info, nil
+}
+
+func (t *funcTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	return t.run(ctx, argumentsInJSON)
+}
+
+// newTool 创建工具,参数按 json 解析为 T 后传给 fn
+func newTool[T any](name, desc string, params map[string]*schema.ParameterInfo, fn func(ctx context.Context, args *T) (string, error)) tool.InvokableTool {
+	return &funcTool{
+		info: &schema.ToolInfo{
+			Name:        name,
+			Desc:        desc,
+			ParamsOneOf: schema.NewParamsOneOfByParams(params),
+		},
+		run: func(ctx context.Context, argumentsInJSON string) (string, error) {
+			var args T
+			if argumentsInJSON != "" {
+				if err := json.Unmarshal([]byte(argumentsInJSON), &args); err != nil {
+					return "", fmt.Errorf("invalid arguments: %w", err)
+				}
+			}
+			return fn(ctx, &args)
+		},
+	}
+}
+
+// truncate 截断过长的输出
+func truncate(s string) string {
+	if len(s) <= maxOutputSize {
+		return s
+	}
+	return s[:maxOutputSize] + "\n... (truncated)"
+}
@@ -0,0 +1,296 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+type calculatorArgs struct {
+	Expression string `json:"expression"`
+}
+
+func newCalculatorTool() tool.InvokableTool {
+	return newTool("calculator", "Evaluate a math expression. Supports + - * / %, ^ or ** for power (right-associative, binds tighter than * and unary minus), parentheses, "+
+		"the constants pi and e, and the functions sqrt, abs, pow, log, log10, log2, exp, sin, cos, tan, floor, ceil, round, min, max.",
+		map[string]*schema.ParameterInfo{
+			"expression": {Type: schema.String, Desc: "The expression, e.g. (1 + 2) * sqrt(16)", Required: true},
+		},
+		func(ctx context.Context, args *calculatorArgs) (string, error) {
+			v, err := Evaluate(args.Expression)
+			if err != nil {
+				return "", err
+			}
+			return strconv.FormatFloat(v, 'g', -1, 64), nil
+		})
+}
+
+// Evaluate 计算数学表达式,^ 和 ** 表示乘方,乘方优先于乘除和负号,且为右结合
+func Evaluate(expression string) (float64, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return 0, fmt.Errorf("invalid expression: %w", err)
+	}
+	p := &exprParser{tokens: tokens}
+	v, err := p.binary(0)
+	if err != nil {
+		return 0, err
+	}
+	if p.pos < len(p.tokens) {
+		return 0, fmt.Errorf("invalid expression: unexpected %q", p.tokens[p.pos].text)
+	}
+	return v, nil
+}
+
+// 表达式中的词法单元
+const (
+	tokenNumber = iota
+	tokenIdent
+	tokenOperator
+)
+
+type exprToken struct {
+	kind  int
+	text  string
+	value float64
+}
+
+// tokenize 将表达式拆分为数字、标识符和运算符,** 转换为 ^
+func tokenize(expression string) ([]exprToken, error) {
+	tokens := make([]exprToken, 0)
+	for i := 0; i < len(expression); {
+		c := expression[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case isDigit(c) || c == '.' && i+1 < len(expression) && isDigit(expression[i+1]):
+			j := i
+			for j < len(expression) && (isDigit(expression[j]) || expression[j] == '.') {
+				j++
+			}
+			// 科学计数法,如 1e3、2.5E-2
+			if j < len(expression) && (expression[j] == 'e' || expression[j] == 'E') {
+				k := j + 1
+				if k < len(expression) && (expression[k] == '+' || expression[k] == '-') {
+					k++
+				}
+				if k < len(expression) && isDigit(expression[k]) {
+					for k < len(expression) && isDigit(expression[k]) {
+						k++
+					}
+					j = k
+				}
+			}
+			v, err := strconv.ParseFloat(expression[i:j], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q", expression[i:j])
+			}
+			tokens = append(tokens, exprToken{kind: tokenNumber, text: expression[i:j], value: v})
+			i = j
+		case isLetter(c):
+			j := i
+			for j < len(expression) && (isLetter(expression[j]) || isDigit(expression[j])) {
+				j++
+			}
+			tokens = append(tokens, exprToken{kind: tokenIdent, text: expression[i:j]})
+			i = j
+		case strings.HasPrefix(expression[i:], "**"):
+			tokens = append(tokens, exprToken{kind: tokenOperator, text: "^"})
+			i += 2
+		case strings.IndexByte("+-*/%^(),", c) >= 0:
+			tokens = append(tokens, exprToken{kind: tokenOperator, text: string(c)})
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected character %q", c)
+		}
+	}
+	return tokens, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+// 二元运算符的优先级,负号的优先级介于乘除和乘方之间
+const (
+	precAdd   = 1
+	precMul   = 2
+	precUnary = 3
+	precPow   = 4
+)
+
+var binaryPrec = map[string]int{"+": precAdd, "-": precAdd, "*": precMul, "/": precMul, "%": precMul, "^": precPow}
+
+// exprParser 按优先级爬升法解析并计算表达式
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+func (p *exprParser) peek() *exprToken {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+// operator 下一个词法单元是运算符 op 时跳过并返回 true
+func (p *exprParser) operator(op string) bool {
+	if t := p.peek(); t != nil && t.kind == tokenOperator && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// binary 计算优先级不低于 minPrec 的二元运算,^ 为右结合,其他运算符为左结合
+func (p *exprParser) binary(minPrec int) (float64, error) {
+	x, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		t := p.peek()
+		if t == nil || t.kind != tokenOperator {
+			return x, nil
+		}
+		prec, ok := binaryPrec[t.text]
+		if !ok || prec < minPrec {
+			return x, nil
+		}
+		p.pos++
+		next := prec + 1
+		if t.text == "^" {
+			next = prec
+		}
+		y, err := p.binary(next)
+		if err != nil {
+			return 0, err
+		}
+		if x, err = apply(t.text, x, y); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// unary 负号作用于其后的乘方,-2^2 为 -4
+func (p *exprParser) unary() (float64, error) {
+	if p.operator("-") {
+		x, err := p.binary(precPow)
+		return -x, err
+	}
+	if p.operator("+") {
+		return p.binary(precPow)
+	}
+	return p.primary()
+}
+
+func (p *exprParser) primary() (float64, error) {
+	t := p.peek()
+	if t == nil {
+		return 0, fmt.Errorf("invalid expression: unexpected end")
+	}
+	p.pos++
+	switch {
+	case t.kind == tokenNumber:
+		return t.value, nil
+
+	case t.kind == tokenIdent:
+		name := strings.ToLower(t.text)
+		if !p.operator("(") {
+			switch name {
+			case "pi":
+				return math.Pi, nil
+			case "e":
+				return math.E, nil
+			}
+			return 0, fmt.Errorf("unknown identifier: %s", t.text)
+		}
+		args := make([]float64, 0)
+		if !p.operator(")") {
+			for {
+				v, err := p.binary(0)
+				if err != nil {
+					return 0, err
+				}
+				args = append(args, v)
+				if p.operator(")") {
+					break
+				}
+				if !p.operator(",") {
+					return 0, fmt.Errorf("invalid expression: expected , or ) in call to %s", t.text)
+				}
+			}
+		}
+		return call(name, args)
+
+	case t.text == "(":
+		v, err := p.binary(0)
+		if err != nil {
+			return 0, err
+		}
+		if !p.operator(")") {
+			return 0, fmt.Errorf("invalid expression: missing )")
+		}
+		return v, nil
+	}
+	return 0, fmt.Errorf("invalid expression: unexpected %q", t.text)
+}
+
+func apply(op string, x, y float64) (float64, error) {
+	switch op {
+	case "+":
+		return x + y, nil
+	case "-":
+		return x - y, nil
+	case "*":
+		return x * y, nil
+	case "/":
+		if y == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return x / y, nil
+	case "%":
+		if y == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return math.Mod(x, y), nil
+	case "^":
+		return math.Pow(x, y), nil
+	}
+	return 0, fmt.Errorf("unsupported operator: %s", op)
+}
+
+func call(name string, args []float64) (float64, error) {
+	unary := map[string]func(float64) float64{
+		"sqrt": math.Sqrt, "abs": math.Abs, "log": math.Log, "ln": math.Log, "log10": math.Log10,
+		"log2": math.Log2, "exp": math.Exp, "sin": math.Sin, "cos": math.Cos, "tan": math.Tan,
+		"floor": math.Floor, "ceil": math.Ceil, "round": math.Round,
+	}
+	if fn, ok := unary[name]; ok {
+		if len(args) != 1 {
+			return 0, fmt.Errorf("%s expects 1 argument", name)
+		}
+		return fn(args[0]), nil
+	}
+
+	binary := map[string]func(float64, float64) float64{
+		"pow": math.Pow, "min": math.Min, "max": math.Max,
+	}
+	if fn, ok := binary[name]; ok {
+		if len(args) != 2 {
+			return 0, fmt.Errorf("%s expects 2 arguments", name)
+		}
+		return fn(args[0], args[1]), nil
+	}
+	return 0, fmt.Errorf("unknown function: %s", name)
+}
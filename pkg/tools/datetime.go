@@ -0,0 +1,31 @@
+package tools
+
+import (
+	"context"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+type dateTimeArgs struct {
+	Timezone string `json:"timezone"`
+}
+
+func newDateTimeTool() tool.InvokableTool {
+	return newTool("current_time", "Get the current date and time.",
+		map[string]*schema.ParameterInfo{
+			"timezone": {Type: schema.String, Desc: "IANA timezone such as Asia/Shanghai, defaults to local time"},
+		},
+		func(ctx context.Context, args *dateTimeArgs) (string, error) {
+			now := time.Now()
+			if args.Timezone != "" {
+				loc, err := time.LoadLocation(args.Timezone)
+				if err != nil {
+					return "", err
+				}
+				now = now.In(loc)
+			}
+			return now.Format("2006-01-02 15:04:05 Monday MST (-07:00)"), nil
+		})
+}
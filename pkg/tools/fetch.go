@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+type httpFetchArgs struct {
+	URL string `json:"url"`
+}
+
+func newHTTPFetchTool(allowlist []string) tool.InvokableTool {
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return fmt.Errorf("too many redirects")
+			}
+			if !hostAllowed(allowlist, req.URL.Hostname()) {
+				return fmt.Errorf("redirect to %s is not allowed", req.URL.Hostname())
+			}
+			return nil
+		},
+	}
+
+	return newTool("http_fetch", "Fetch a web page or API with HTTP GET and return the response body. "+
+		"Only these hosts are allowed: "+strings.Join(allowlist, ", "),
+		map[string]*schema.ParameterInfo{
+			"url": {Type: schema.String, Desc: "The http or https URL to fetch", Required: true},
+		},
+		func(ctx context.Context, args *httpFetchArgs) (string, error) {
+			u, err := url.Parse(args.URL)
+			if err != nil {
+				return "", err
+			}
+			if u.Scheme != "http" && u.Scheme != "https" {
+				return "", fmt.Errorf("unsupported scheme: %s", u.Scheme)
+			}
+			if !hostAllowed(allowlist, u.Hostname()) {
+				return "", fmt.Errorf("host %s is not in the allowlist", u.Hostname())
+			}
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+			if err != nil {
+				return "", err
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				return "", err
+			}
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(io.LimitReader(resp.Body, maxOutputSize+1))
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("HTTP %d\n%s", resp.StatusCode, truncate(string(body))), nil
+		})
+}
+
+// hostAllowed 判断域名是否在白名单中,*.example.com 匹配 example.com 及其子域名
+func hostAllowed(allowlist []string, host string) bool {
+	host = strings.ToLower(host)
+	for _, pattern := range allowlist {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "*" || pattern == host {
+			return true
+		}
+		if strings.HasPrefix(pattern, "*.") {
+			suffix := pattern[2:]
+			if host == suffix || strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+type readFileArgs struct {
+	Path string `json:"path"`
+}
+
+type listDirArgs struct {
+	Path string `json:"path"`
+}
+
+func newReadFileTool(allowedDirs []string) tool.InvokableTool {
+	return newTool("read_file", "Read a text file from the user's allowed directories.",
+		map[string]*schema.ParameterInfo{
+			"path": {Type: schema.String, Desc: "Absolute path of the file", Required: true},
+		},
+		func(ctx context.Context, args *readFileArgs) (string, error) {
+			path, err := resolvePath(allowedDirs, args.Path)
+			if err != nil {
+				return "", err
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return "", err
+			}
+			return truncate(string(data)), nil
+		})
+}
+
+func newListDirTool(allowedDirs []string) tool.InvokableTool {
+	return newTool("list_dir", "List the entries of a directory in the user's allowed directories. "+
+		"Allowed directories: "+strings.Join(allowedDirs, ", "),
+		map[string]*schema.ParameterInfo{
+			"path": {Type: schema.String, Desc: "Absolute path of the directory", Required: true},
+		},
+		func(ctx context.Context, args *listDirArgs) (string, error) {
+			path, err := resolvePath(allowedDirs, args.Path)
+			if err != nil {
+				return "", err
+			}
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				return "", err
+			}
+			var sb strings.Builder
+			for _, e := range entries {
+				if e.IsDir() {
+					sb.WriteString(e.Name() + "/\n")
+					continue
+				}
+				size := int64(0)
+				if info, err := e.Info(); err == nil {
+					size = info.Size()
+				}
+				sb.WriteString(fmt.Sprintf("%s\t%d\n", e.Name(), size))
+			}
+			return truncate(sb.String()), nil
+		})
+}
+
+// resolvePath 返回绝对路径,路径不在允许的目录内时返回错误
+func resolvePath(allowedDirs []string, path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("path is required")
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = real
+	}
+
+	for _, dir := range allowedDirs {
+		base, err := filepath.Abs(dir)
+		if err != nil {
+			continue
+		}
+		if real, err := filepath.EvalSymlinks(base); err == nil {
+			base = real
+		}
+		rel, err := filepath.Rel(base, abs)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return abs, nil
+		}
+	}
+	return "", fmt.Errorf("access denied: %s is not in the allowed directories", path)
+}
@@ -0,0 +1,124 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// sandboxExec macOS 自带的沙箱命令
+const sandboxExec = "/usr/bin/sandbox-exec"
+
+// checkSandbox 当前平台没有可用的沙箱时返回错误,此时不提供执行命令的工具
+func checkSandbox() error {
+	switch runtime.GOOS {
+	case "linux":
+		if _, err := exec.LookPath("bwrap"); err != nil {
+			return fmt.Errorf("bubblewrap (bwrap) is required to run commands in a sandbox: %w", err)
+		}
+		return nil
+	case "darwin":
+		if _, err := os.Stat(sandboxExec); err != nil {
+			return fmt.Errorf("sandbox-exec is required to run commands in a sandbox: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("running commands is not supported on %s: no sandbox is available", runtime.GOOS)
+	}
+}
+
+// sandboxCommand 创建在沙箱中执行 command 的命令,工作目录为 dir。
+// 命令只能写入 allowedDirs 和临时目录,不能读取家目录中 allowedDirs 以外的文件,不能访问网络,
+// 环境变量只保留 PATH 和语言设置
+func sandboxCommand(ctx context.Context, command string, dir string, allowedDirs []string) (*exec.Cmd, error) {
+	if err := checkSandbox(); err != nil {
+		return nil, err
+	}
+	dirs := realDirs(allowedDirs)
+	home, _ := os.UserHomeDir()
+	if home != "" {
+		if real, err := filepath.EvalSymlinks(home); err == nil {
+			home = real
+		}
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.CommandContext(ctx, sandboxExec, seatbeltArgs(command, dirs, home)...)
+	} else {
+		cmd = exec.CommandContext(ctx, "bwrap", bwrapArgs(command, dir, dirs, home)...)
+	}
+	cmd.Dir = dir
+	cmd.Env = sandboxEnv(dir)
+	return cmd, nil
+}
+
+// realDirs 返回 allowedDirs 的绝对路径,符号链接解析为实际路径
+func realDirs(allowedDirs []string) []string {
+	dirs := make([]string, 0, len(allowedDirs))
+	for _, dir := range allowedDirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			continue
+		}
+		if real, err := filepath.EvalSymlinks(abs); err == nil {
+			abs = real
+		}
+		dirs = append(dirs, abs)
+	}
+	return dirs
+}
+
+// sandboxEnv 命令的环境变量,不传递 API Key、代理等其他变量,HOME 指向工作目录
+func sandboxEnv(dir string) []string {
+	env := []string{"HOME=" + dir, "TMPDIR=/tmp"}
+	for _, key := range []string{"PATH", "LANG", "LC_ALL"} {
+		if v, ok := os.LookupEnv(key); ok {
+			env = append(env, key+"="+v)
+		}
+	}
+	return env
+}
+
+// bwrapArgs Linux 上 bubblewrap 的参数:系统目录只读,家目录、/tmp 和 /run 替换为空目录,
+// 只有 dirs 可以读写,不共享网络等命名空间
+func bwrapArgs(command string, dir string, dirs []string, home string) []string {
+	args := []string{
+		"--die-with-parent", "--new-session", "--unshare-all",
+		"--ro-bind", "/", "/",
+		"--dev", "/dev",
+		"--proc", "/proc",
+		"--tmpfs", "/tmp",
+		"--tmpfs", "/run",
+	}
+	if home != "" && home != "/" {
+		args = append(args, "--tmpfs", home)
+	}
+	for _, d := range dirs {
+		args = append(args, "--bind", d, d)
+	}
+	return append(args, "--chdir", dir, "--", "sh", "-c", command)
+}
+
+// seatbeltArgs macOS 上 sandbox-exec 的参数:只能写入 dirs 和临时目录,不能读取家目录中 dirs 以外的文件,不能访问网络。
+// 路径通过 -D 参数传入,不需要在规则中转义
+func seatbeltArgs(command string, dirs []string, home string) []string {
+	var args []string
+	var profile strings.Builder
+	profile.WriteString("(version 1)\n(allow default)\n(deny network*)\n(deny file-write*)\n")
+	profile.WriteString(`(allow file-write* (subpath "/private/tmp") (subpath "/dev"))` + "\n")
+	if home != "" && home != "/" {
+		args = append(args, "-D", "HOME_DIR="+home)
+		profile.WriteString(`(deny file-read-data file-write* (subpath (param "HOME_DIR")))` + "\n")
+	}
+	for i, d := range dirs {
+		name := fmt.Sprintf("ALLOWED_DIR_%d", i)
+		args = append(args, "-D", name+"="+d)
+		fmt.Fprintf(&profile, "(allow file-read* file-write* (subpath (param %q)))\n", name)
+	}
+	return append(args, "-p", profile.String(), "sh", "-c", command)
+}
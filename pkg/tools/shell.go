@@ -0,0 +1,74 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+type shellArgs struct {
+	Command string `json:"command"`
+	Dir     string `json:"dir"`
+}
+
+// newShellTool 执行命令的工具
+//
+// 命令在沙箱中运行,见 sandboxCommand:只能写入 allowedDirs,不能读取家目录中的其他文件,不能访问网络。
+// 命令仍以当前用户身份运行并能读取系统文件,调用策略固定为需要用户确认,见 config.ShellToolName
+func newShellTool(allowedDirs []string, timeout int) tool.InvokableTool {
+	if timeout <= 0 {
+		timeout = 30
+	}
+	return newTool(config.ShellToolName, fmt.Sprintf("Run a shell command on the user's computer and return its output. "+
+		"The command runs in a sandbox without network access: it can only write inside the allowed directories "+
+		"and cannot read other files in the user's home directory, "+
+		"and every call must be approved by the user. "+
+		"It starts in one of the allowed directories and is killed after %d seconds.", timeout),
+		map[string]*schema.ParameterInfo{
+			"command": {Type: schema.String, Desc: "The command line to run", Required: true},
+			"dir":     {Type: schema.String, Desc: "Working directory, defaults to the first allowed directory"},
+		},
+		func(ctx context.Context, args *shellArgs) (string, error) {
+			if args.Command == "" {
+				return "", fmt.Errorf("command is required")
+			}
+			if len(allowedDirs) == 0 {
+				return "", fmt.Errorf("no allowed directories configured")
+			}
+			dir := args.Dir
+			if dir == "" {
+				dir = allowedDirs[0]
+			}
+			dir, err := resolvePath(allowedDirs, dir)
+			if err != nil {
+				return "", err
+			}
+
+			ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+			defer cancel()
+
+			cmd, err := sandboxCommand(ctx, args.Command, dir, allowedDirs)
+			if err != nil {
+				return "", err
+			}
+
+			var out bytes.Buffer
+			cmd.Stdout = &out
+			cmd.Stderr = &out
+			err = cmd.Run()
+
+			result := truncate(out.String())
+			if ctx.Err() == context.DeadlineExceeded {
+				return result + fmt.Sprintf("\n(command timed out after %d seconds)", timeout), nil
+			}
+			if err != nil {
+				return result + "\n(" + err.Error() + ")", nil
+			}
+			return result, nil
+		})
+}
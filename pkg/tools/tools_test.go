@@ -0,0 +1,121 @@
+package tools
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	cases := map[string]float64{
+		"1 + 2 * 3":         7,
+		"(1 + 2) * 3":       9,
+		"2 ^ 10":            1024,
+		"2 ** 3":            8,
+		"-4 + sqrt(16)":     0,
+		"10 % 3":            1,
+		"max(3, pow(2, 2))": 4,
+		"round(pi * 100)":   314,
+		"1 + 2 ^ 2":         5,
+		"2 * 3 ^ 2":         18,
+		"2 ^ 3 ^ 2":         512,
+		"2 ** 3 * 2":        16,
+		"-2 ^ 2":            -4,
+		"2 ^ -1":            0.5,
+		"1.5e2 + e - e":     150,
+	}
+	for expr, want := range cases {
+		got, err := Evaluate(expr)
+		if err != nil {
+			t.Errorf("Evaluate(%q) failed: %v", expr, err)
+			continue
+		}
+		if math.Abs(got-want) > 1e-9 {
+			t.Errorf("Evaluate(%q) = %v, want %v", expr, got, want)
+		}
+	}
+
+	for _, expr := range []string{"1 / 0", "os.Exit(1)", "\"a\" + 1", "foo(1)", "(1 + 2", "1 +", "sqrt(1, 2)"} {
+		if _, err := Evaluate(expr); err == nil {
+			t.Errorf("Expected error for %q", expr)
+		}
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	allowed := t.TempDir()
+	other := t.TempDir()
+	file := filepath.Join(allowed, "a.txt")
+	os.WriteFile(file, []byte("hello"), 0644)
+
+	if _, err := resolvePath([]string{allowed}, file); err != nil {
+		t.Errorf("Expected file in allowed dir to be accessible: %v", err)
+	}
+	if _, err := resolvePath([]string{allowed}, filepath.Join(allowed, "..", filepath.Base(other))); err == nil {
+		t.Error("Expected path outside allowed dirs to be denied")
+	}
+	if _, err := resolvePath(nil, file); err == nil {
+		t.Error("Expected access to be denied without allowed dirs")
+	}
+}
+
+func TestSandboxArgs(t *testing.T) {
+	args := strings.Join(bwrapArgs("ls", "/work/a", []string{"/work/a", "/home/u/notes"}, "/home/u"), " ")
+	for _, part := range []string{"--unshare-all", "--ro-bind / /", "--tmpfs /home/u --bind /work/a /work/a --bind /home/u/notes /home/u/notes", "--chdir /work/a -- sh -c ls"} {
+		if !strings.Contains(args, part) {
+			t.Errorf("Expected %q in bwrap args %q", part, args)
+		}
+	}
+
+	args = strings.Join(seatbeltArgs("ls", []string{"/Users/u/notes"}, "/Users/u"), " ")
+	for _, part := range []string{"-D HOME_DIR=/Users/u", "-D ALLOWED_DIR_0=/Users/u/notes", "(deny network*)", "(deny file-write*)", `(allow file-read* file-write* (subpath (param "ALLOWED_DIR_0")))`} {
+		if !strings.Contains(args, part) {
+			t.Errorf("Expected %q in sandbox-exec args %q", part, args)
+		}
+	}
+
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+	for _, kv := range sandboxEnv("/work/a") {
+		if strings.HasPrefix(kv, "OPENAI_API_KEY=") {
+			t.Error("Expected the environment not to be passed to commands")
+		}
+	}
+}
+
+func TestShellSandbox(t *testing.T) {
+	if err := checkSandbox(); err != nil {
+		t.Skipf("No sandbox available: %v", err)
+	}
+	allowed := t.TempDir()
+	shell := newShellTool([]string{allowed}, 10)
+
+	output, err := shell.InvokableRun(context.Background(), `{"command":"echo hi > a.txt && cat a.txt"}`)
+	if err != nil || !strings.Contains(output, "hi") {
+		t.Errorf("Expected writes in the allowed directory to work, got %q %v", output, err)
+	}
+	outside := filepath.Join(filepath.Dir(allowed), "outside.txt")
+	shell.InvokableRun(context.Background(), `{"command":"echo hi > `+outside+`"}`)
+	if _, err := os.Stat(outside); err == nil {
+		os.Remove(outside)
+		t.Error("Expected writes outside the allowed directories to fail")
+	}
+}
+
+func TestHostAllowed(t *testing.T) {
+	allowlist := []string{"example.com", "*.github.com"}
+
+	for host, want := range map[string]bool{
+		"example.com":     true,
+		"api.example.com": false,
+		"github.com":      true,
+		"api.github.com":  true,
+		"evilgithub.com":  false,
+	} {
+		if got := hostAllowed(allowlist, host); got != want {
+			t.Errorf("hostAllowed(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
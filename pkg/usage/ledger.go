@@ -0,0 +1,272 @@
+package usage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/AntNoHuabei/Remo/pkg/persist"
+	"github.com/google/uuid"
+	"github.com/ostafen/clover"
+)
+
+// 模型调用的用途
+const (
+	PurposeChat    = "chat"    // 对话回复
+	PurposeSummary = "summary" // 压缩较早的对话
+	PurposeTitle   = "title"   // 生成会话标题
+	PurposeMemory  = "memory"  // 提取长期记忆
+	PurposeGateway = "gateway" // OpenAI 兼容接口
+)
+
+// 统计的分组方式
+const (
+	GroupByDay     = "day"
+	GroupByModel   = "model"
+	GroupBySession = "session"
+)
+
+// ErrBudgetExceeded 超出预算且配置为拒绝对话
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+var (
+	globalLedger *Ledger
+	ledgerMu     sync.RWMutex
+)
+
+// Record 一次模型调用的用量
+type Record struct {
+	Id               string  `json:"id"`
+	Session          string  `json:"session"` // 不属于会话的调用为空
+	Provider         string  `json:"provider"`
+	Model            string  `json:"model"` // 完整名称 provider/model
+	Purpose          string  `json:"purpose"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	ReasoningTokens  int     `json:"reasoning_tokens"` // 包含在 CompletionTokens 中
+	TotalTokens      int     `json:"total_tokens"`
+	Cost             float64 `json:"cost"` // 按调用时的价格计算
+	CreatedTime      int64   `json:"created_time"`
+}
+
+// Group 一组调用的用量合计
+type Group struct {
+	Key              string  `json:"key"` // 日期 2006-01-02、模型名称或会话 id
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	ReasoningTokens  int     `json:"reasoning_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+func (g *Group) add(r *Record) {
+	g.Calls++
+	g.PromptTokens += r.PromptTokens
+	g.CompletionTokens += r.CompletionTokens
+	g.ReasoningTokens += r.ReasoningTokens
+	g.TotalTokens += r.TotalTokens
+	g.Cost += r.Cost
+}
+
+// Query 统计条件
+type Query struct {
+	GroupBy string // day, model, session
+	From    int64  // 毫秒时间戳,0 表示不限制
+	To      int64  // 毫秒时间戳(不含),0 表示不限制
+}
+
+// Summary 用量统计
+type Summary struct {
+	GroupBy  string   `json:"group_by"`
+	Currency string   `json:"currency"`
+	Groups   []*Group `json:"groups"`
+	Total    Group    `json:"total"`
+}
+
+// Budget 预算的使用情况
+type Budget struct {
+	Period   string  `json:"period"` // daily, monthly
+	Limit    float64 `json:"limit"`
+	Spent    float64 `json:"spent"`
+	Currency string  `json:"currency"`
+}
+
+func (b *Budget) Error() string {
+	return fmt.Sprintf("%s: spent %.4f of the %s budget %.4f %s", ErrBudgetExceeded, b.Spent, b.Period, b.Limit, b.Currency)
+}
+
+func (b *Budget) Unwrap() error {
+	return ErrBudgetExceeded
+}
+
+// Ledger 用量账本,每次模型调用一条记录,保存在 persist.Usage 集合中
+type Ledger struct {
+	cfg config.UsageConfig
+}
+
+// NewLedger 根据配置创建账本
+func NewLedger(cfg config.UsageConfig) *Ledger {
+	return &Ledger{cfg: cfg}
+}
+
+// Init 创建全局账本
+func Init(cfg config.UsageConfig) *Ledger {
+	l := NewLedger(cfg)
+
+	ledgerMu.Lock()
+	globalLedger = l
+	ledgerMu.Unlock()
+	return l
+}
+
+// Get 获取全局账本,未初始化时返回没有预算的账本
+func Get() *Ledger {
+	ledgerMu.RLock()
+	defer ledgerMu.RUnlock()
+	if globalLedger == nil {
+		return &Ledger{}
+	}
+	return globalLedger
+}
+
+// Cost 按模型价格计算费用,价格为每百万 token 的价格
+func Cost(define config.ChatModelDefine, promptTokens int, completionTokens int) float64 {
+	return (float64(promptTokens)*define.InputPrice + float64(completionTokens)*define.OutputPrice) / 1e6
+}
+
+// Add 保存一条用量记录
+func (l *Ledger) Add(r *Record) error {
+	if r.Id == "" {
+		r.Id = uuid.New().String()
+	}
+	if r.CreatedTime == 0 {
+		r.CreatedTime = time.Now().UnixMilli()
+	}
+	if r.TotalTokens == 0 {
+		r.TotalTokens = r.PromptTokens + r.CompletionTokens
+	}
+
+	doc, err := persist.NewDocument(r)
+	if err != nil {
+		return err
+	}
+	doc.Set("_id", r.Id)
+	_, err = persist.DB.InsertOne(persist.Usage, doc)
+	return err
+}
+
+// Summary 按日期、模型或会话统计用量,按日期分组时按日期排列,其它按费用和 token 数从高到低排列
+func (l *Ledger) Summary(q Query) (*Summary, error) {
+	var key func(r *Record) string
+	switch q.GroupBy {
+	case GroupByDay, "":
+		q.GroupBy = GroupByDay
+		key = func(r *Record) string {
+			return time.UnixMilli(r.CreatedTime).Format(time.DateOnly)
+		}
+	case GroupByModel:
+		key = func(r *Record) string { return r.Model }
+	case GroupBySession:
+		key = func(r *Record) string { return r.Session }
+	default:
+		return nil, fmt.Errorf("unsupported group_by: %s", q.GroupBy)
+	}
+
+	records, err := l.records(q.From, q.To)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &Summary{GroupBy: q.GroupBy, Currency: l.cfg.Currency, Groups: make([]*Group, 0)}
+	groups := make(map[string]*Group)
+	for _, r := range records {
+		k := key(r)
+		g, ok := groups[k]
+		if !ok {
+			g = &Group{Key: k}
+			groups[k] = g
+			summary.Groups = append(summary.Groups, g)
+		}
+		g.add(r)
+		summary.Total.add(r)
+	}
+
+	sort.SliceStable(summary.Groups, func(i, j int) bool {
+		a, b := summary.Groups[i], summary.Groups[j]
+		if q.GroupBy == GroupByDay {
+			return a.Key < b.Key
+		}
+		if a.Cost != b.Cost {
+			return a.Cost > b.Cost
+		}
+		if a.TotalTokens != b.TotalTokens {
+			return a.TotalTokens > b.TotalTokens
+		}
+		return a.Key < b.Key
+	})
+	return summary, nil
+}
+
+// CheckBudget 检查今天和本月的费用,超出预算时返回超出的预算,未配置预算时返回 nil
+func (l *Ledger) CheckBudget() (*Budget, error) {
+	now := time.Now()
+	periods := []struct {
+		name  string
+		limit float64
+		from  time.Time
+	}{
+		{"daily", l.cfg.DailyBudget, time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())},
+		{"monthly", l.cfg.MonthlyBudget, time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())},
+	}
+
+	for _, p := range periods {
+		if p.limit <= 0 {
+			continue
+		}
+		records, err := l.records(p.from.UnixMilli(), 0)
+		if err != nil {
+			return nil, err
+		}
+		spent := 0.0
+		for _, r := range records {
+			spent += r.Cost
+		}
+		if spent >= p.limit {
+			return &Budget{Period: p.name, Limit: p.limit, Spent: spent, Currency: l.cfg.Currency}, nil
+		}
+	}
+	return nil, nil
+}
+
+// Blocking 超出预算时是否拒绝对话
+func (l *Ledger) Blocking() bool {
+	return l.cfg.BudgetAction == config.BudgetActionBlock
+}
+
+func (l *Ledger) records(from int64, to int64) ([]*Record, error) {
+	q := persist.DB.Query(persist.Usage)
+	if from > 0 {
+		q = q.Where(clover.Field("created_time").GtEq(from))
+	}
+	if to > 0 {
+		q = q.Where(clover.Field("created_time").Lt(to))
+	}
+	docs, err := q.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	output := make([]*Record, 0, len(docs))
+	for _, doc := range docs {
+		var r Record
+		if err := persist.Unmarshal(doc, &r); err != nil {
+			return nil, err
+		}
+		output = append(output, &r)
+	}
+	return output, nil
+}
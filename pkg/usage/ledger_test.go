@@ -0,0 +1,102 @@
+package usage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/AntNoHuabei/Remo/internal/config"
+	"github.com/AntNoHuabei/Remo/pkg/persist"
+)
+
+func openTestDB(t *testing.T) {
+	if err := persist.Open(t.TempDir()); err != nil {
+		t.Fatalf("Failed to open db: %v", err)
+	}
+	t.Cleanup(func() {
+		persist.DB.Close()
+	})
+}
+
+func TestCost(t *testing.T) {
+	define := config.ChatModelDefine{InputPrice: 2, OutputPrice: 8}
+	if cost := Cost(define, 500_000, 250_000); cost != 3 {
+		t.Errorf("Expected cost 3, got %v", cost)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	openTestDB(t)
+	l := NewLedger(config.UsageConfig{Currency: "CNY"})
+
+	day1 := time.Date(2026, 3, 1, 10, 0, 0, 0, time.Local).UnixMilli()
+	day2 := time.Date(2026, 3, 2, 10, 0, 0, 0, time.Local).UnixMilli()
+	records := []*Record{
+		{Session: "s1", Model: "deepseek/deepseek-chat", PromptTokens: 100, CompletionTokens: 50, Cost: 0.5, CreatedTime: day1},
+		{Session: "s1", Model: "deepseek/deepseek-reasoner", PromptTokens: 10, CompletionTokens: 90, ReasoningTokens: 60, Cost: 2, CreatedTime: day1},
+		{Session: "s2", Model: "deepseek/deepseek-chat", PromptTokens: 20, CompletionTokens: 10, Cost: 0.1, CreatedTime: day2},
+	}
+	for _, r := range records {
+		if err := l.Add(r); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	summary, err := l.Summary(Query{})
+	if err != nil {
+		t.Fatalf("Summary failed: %v", err)
+	}
+	if summary.GroupBy != GroupByDay || len(summary.Groups) != 2 || summary.Groups[0].Key != "2026-03-01" {
+		t.Fatalf("Unexpected daily summary %+v", summary.Groups)
+	}
+	if g := summary.Groups[0]; g.Calls != 2 || g.TotalTokens != 250 || g.ReasoningTokens != 60 || g.Cost != 2.5 {
+		t.Errorf("Unexpected group %+v", g)
+	}
+	if summary.Total.Calls != 3 || summary.Total.TotalTokens != 280 || summary.Currency != "CNY" {
+		t.Errorf("Unexpected total %+v", summary.Total)
+	}
+
+	summary, _ = l.Summary(Query{GroupBy: GroupByModel})
+	if len(summary.Groups) != 2 || summary.Groups[0].Key != "deepseek/deepseek-reasoner" {
+		t.Errorf("Expected models ordered by cost, got %+v", summary.Groups)
+	}
+
+	summary, _ = l.Summary(Query{GroupBy: GroupBySession, From: day2})
+	if len(summary.Groups) != 1 || summary.Groups[0].Key != "s2" {
+		t.Errorf("Expected only the second day, got %+v", summary.Groups)
+	}
+
+	if _, err := l.Summary(Query{GroupBy: "week"}); err == nil {
+		t.Error("Expected an unsupported group_by to fail")
+	}
+}
+
+func TestCheckBudget(t *testing.T) {
+	openTestDB(t)
+	l := NewLedger(config.UsageConfig{Currency: "CNY", DailyBudget: 1, MonthlyBudget: 10, BudgetAction: config.BudgetActionBlock})
+
+	if budget, err := l.CheckBudget(); err != nil || budget != nil {
+		t.Fatalf("Expected no exceeded budget, got %+v %v", budget, err)
+	}
+
+	// 昨天的费用只计入月预算
+	yesterday := time.Now().AddDate(0, 0, -1)
+	if yesterday.Month() == time.Now().Month() {
+		l.Add(&Record{Cost: 20, CreatedTime: yesterday.UnixMilli()})
+		budget, _ := l.CheckBudget()
+		if budget == nil || budget.Period != "monthly" || budget.Spent != 20 {
+			t.Errorf("Expected the monthly budget to be exceeded, got %+v", budget)
+		}
+		persist.DB.Query(persist.Usage).Delete()
+	}
+
+	l.Add(&Record{Cost: 0.6})
+	l.Add(&Record{Cost: 0.6})
+	budget, _ := l.CheckBudget()
+	if budget == nil || budget.Period != "daily" || budget.Limit != 1 {
+		t.Fatalf("Expected the daily budget to be exceeded, got %+v", budget)
+	}
+	if !errors.Is(budget, ErrBudgetExceeded) || !l.Blocking() {
+		t.Errorf("Expected a blocking budget error, got %v", budget)
+	}
+}